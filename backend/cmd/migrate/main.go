@@ -1,41 +1,139 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/migrate"
 )
 
+const usage = `Usage: go run cmd/migrate/main.go [flags] <command>
+
+Commands:
+  up              Apply all pending migrations
+  down [N]        Roll back the last N migrations (default 1)
+  status          Show applied and pending migrations
+  redo            Roll back and re-apply the latest migration
+  goto VERSION    Migrate up or down to VERSION (0 rolls back everything)
+  create NAME     Create a new up/down migration pair`
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: go run cmd/migrate/main.go [up|down]")
+	dir := flag.String("dir", "migrations", "directory containing migration files")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	cfg, err := config.LoadLayered(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ Failed to load configuration: %v", err)
 	}
 
-	cfg := config.Load()
-	direction := os.Args[1]
+	args := flag.Args()
+	if len(args) < 1 {
+		log.Fatal(usage)
+	}
 
-	switch direction {
+	if args[0] == "create" {
+		if len(args) < 2 {
+			log.Fatal("Usage: go run cmd/migrate/main.go create NAME")
+		}
+		upPath, downPath, err := migrate.Create(*dir, strings.Join(args[1:], "_"))
+		if err != nil {
+			log.Fatalf("❌ Failed to create migration: %v", err)
+		}
+		fmt.Printf("✅ Created %s\n✅ Created %s\n", upPath, downPath)
+		return
+	}
+
+	db, err := database.Open(context.Background(), database.ConfigFrom(cfg))
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db.DB, *dir)
+	if err != nil {
+		log.Fatalf("❌ Failed to load migrations: %v", err)
+	}
+
+	if err := run(context.Background(), migrator, args); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+}
+
+func run(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+	switch args[0] {
 	case "up":
-		runMigrationsUp(cfg)
+		fmt.Println("🔄 Running migrations UP")
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Applied %d migration(s)\n", n)
 	case "down":
-		runMigrationsDown(cfg)
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("invalid number of migrations: %s", args[1])
+			}
+			steps = parsed
+		}
+		fmt.Printf("🔄 Rolling back %d migration(s)\n", steps)
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Rolled back %d migration(s)\n", n)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+	case "redo":
+		fmt.Println("🔄 Redoing latest migration")
+		if err := migrator.Redo(ctx); err != nil {
+			return err
+		}
+		fmt.Println("✅ Redo completed successfully")
+	case "goto":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: goto VERSION")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		fmt.Printf("🔄 Migrating to version %d\n", version)
+		if err := migrator.Goto(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Database is at version %d\n", version)
 	default:
-		log.Fatal("Invalid direction. Use 'up' or 'down'")
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
 	}
+	return nil
 }
 
-func runMigrationsUp(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations UP against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations completed successfully")
-}
-
-func runMigrationsDown(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations DOWN against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations rollback completed successfully")
+func printStatus(statuses []migrate.Status) {
+	if len(statuses) == 0 {
+		fmt.Println("No migrations found")
+		return
+	}
+	for _, s := range statuses {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%06d  %-40s %s\n", s.Version, s.Name, state)
+	}
 }
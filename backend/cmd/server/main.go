@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,46 +12,124 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/health"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/metrics"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/version"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/validation"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.Load()
+	// Load configuration: config file, environment variables, then flags
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	loader, err := config.NewLoader(fs, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+	if *printConfig {
+		os.Exit(printEffectiveConfig(loader))
+	}
+	watcher, err := config.NewWatcher(loader)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	cfg := watcher.Config()
+
+	// Structured logging; the standard log package is routed through it too
+	logger, logLevel, err := logging.New(os.Stdout, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// Connect to the database
+	db, err := database.Open(context.Background(), database.ConfigFrom(cfg))
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	h := handlers.NewHandler(db)
+
+	// Readiness checks for external dependencies
+	checks := health.NewRegistry()
+	checks.Register("database", 2*time.Second, func(ctx context.Context) error {
+		_, err := db.Check(ctx)
+		return err
+	})
+
+	// Prometheus metrics, including connection pool statistics
+	m := metrics.New()
+	if err := m.RegisterDB(db.DB, "main"); err != nil {
+		log.Fatalf("Failed to register database metrics: %v", err)
+	}
+
+	cors, err := middleware.NewCORS(middleware.DefaultCORSPolicy(cfg.CORSOrigins))
+	if err != nil {
+		log.Fatalf("Invalid CORS configuration: %v", err)
+	}
+
+	rateLimits, err := middleware.RateLimitsFrom(cfg)
+	if err != nil {
+		log.Fatalf("Invalid rate limit configuration: %v", err)
+	}
+	limiter := middleware.NewRateLimiter(middleware.NewMemoryStore(), rateLimits)
+
+	// Apply reloadable settings when the config file changes or on SIGHUP
+	watcher.Subscribe(func(old, new *config.Config) {
+		if err := logging.SetLevel(logLevel, new.LogLevel); err != nil {
+			log.Printf("Failed to apply reloaded log level: %v", err)
+		}
+		if err := cors.SetPolicy(middleware.DefaultCORSPolicy(new.CORSOrigins)); err != nil {
+			log.Printf("Failed to apply reloaded CORS origins: %v", err)
+		}
+		if limits, err := middleware.RateLimitsFrom(new); err != nil {
+			log.Printf("Failed to apply reloaded rate limits: %v", err)
+		} else {
+			limiter.SetLimits(limits)
+		}
+	})
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go watcher.Run(watchCtx)
 
 	// Initialize Gin router
 	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.New()
+	// Enforce validate struct tags when binding request bodies
+	binding.Validator = validation.GinValidator{}
 
-	// Add middleware
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
-	router.Use(middleware.CORS())
-
-	// Health check endpoint
-	router.GET("/health", handlers.HealthCheck)
-
-	// API routes
-	api := router.Group("/api/v1")
-	{
-		api.GET("/ping", handlers.Ping)
-		// Add more routes as needed
+	router, err := newRouter(routerDeps{
+		logger:         logger,
+		handler:        h,
+		checks:         checks,
+		metrics:        m,
+		cors:           cors,
+		limiter:        limiter,
+		auth:           middleware.NewAuth(cfg.JWTSecret),
+		trustedProxies: cfg.TrustedProxies,
+	})
+	if err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
 	}
 
 	// Create HTTP server
 	server := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: router,
+		Addr:         ":" + cfg.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("🚀 Server starting on port %s", cfg.Port)
+		log.Printf("🚀 Server %s (%s) starting on port %s", version.Version, version.Commit, cfg.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
@@ -61,13 +141,38 @@ func main() {
 	<-quit
 	log.Println("🛑 Shutting down server...")
 
-	// Give outstanding requests 10 seconds to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Give outstanding requests time to complete
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Close the database only after in-flight requests have finished
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+
 	log.Println("✅ Server exited")
 }
+
+// printEffectiveConfig writes the configuration with secrets redacted before
+// validating it, so that an invalid setup can be inspected, and returns the
+// exit code
+func printEffectiveConfig(loader *config.Loader) int {
+	cfg, err := loader.Merge()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 1
+	}
+	if err := cfg.Redacted().WriteYAML(os.Stdout); err != nil {
+		log.Printf("Failed to print configuration: %v", err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Printf("Invalid configuration:\n%v", err)
+		return 1
+	}
+	return 0
+}
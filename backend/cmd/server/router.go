@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/health"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/metrics"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/openapi"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/version"
+)
+
+// routerDeps holds everything the router needs
+type routerDeps struct {
+	logger  *slog.Logger
+	handler *handlers.Handler
+	checks  *health.Registry
+	metrics *metrics.Metrics
+	cors    *middleware.CORS
+	limiter *middleware.RateLimiter
+	auth    *middleware.Auth
+	// trustedProxies may set X-Forwarded-For; empty trusts none
+	trustedProxies []string
+}
+
+// newRouter registers middleware and routes. Every route needs a matching
+// entry in apiSpec; TestSpecCoversRoutes enforces this.
+func newRouter(d routerDeps) (*gin.Engine, error) {
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	// Gin trusts every proxy by default, which would let any client pick
+	// the IP that rate limits and logs see
+	if err := router.SetTrustedProxies(d.trustedProxies); err != nil {
+		return nil, err
+	}
+
+	// Add middleware
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestLogger(d.logger))
+	router.Use(d.metrics.Middleware())
+	router.Use(middleware.Recovery())
+	router.Use(middleware.ErrorHandler())
+	router.Use(d.cors.Handler())
+
+	// Unmatched routes and methods get problem+json responses too
+	router.NoRoute(middleware.NotFound())
+	router.NoMethod(middleware.MethodNotAllowed())
+
+	// Health check endpoints; /health is kept as an alias of /readyz
+	router.GET("/livez", d.checks.LiveHandler())
+	router.GET("/readyz", d.checks.ReadyHandler())
+	router.GET("/health", d.checks.ReadyHandler())
+
+	// Prometheus scrape endpoint
+	router.GET("/metrics", d.metrics.Handler())
+
+	// API documentation
+	spec := apiSpec()
+	router.GET("/openapi.json", spec.Handler())
+	router.GET("/docs", openapi.DocsHandler("/openapi.json"))
+
+	// API routes
+	api := router.Group("/api/v1", d.limiter.Limit("api"))
+	{
+		api.GET("/ping", d.handler.Ping)
+
+		// Everything beyond /ping requires a valid Bearer token and is
+		// additionally limited per user
+		protected := api.Group("", d.auth.RequireAuth(), d.limiter.Limit("user"))
+		protected.GET("/me", middleware.Handle(d.handler.Me))
+	}
+
+	return router, nil
+}
+
+// apiSpec describes every route registered by newRouter
+func apiSpec() *openapi.Spec {
+	spec := openapi.New("sum25-go-flutter-course backend", version.Version,
+		"REST API of the course backend. Errors are returned as application/problem+json.")
+
+	spec.Ignore(http.MethodGet, "/openapi.json")
+	spec.Ignore(http.MethodGet, "/docs")
+
+	spec.Add(openapi.Operation{
+		Method:    http.MethodGet,
+		Path:      "/livez",
+		Summary:   "Liveness probe",
+		Tags:      []string{"health"},
+		Responses: map[int]interface{}{http.StatusOK: health.Liveness{}},
+	})
+	spec.Add(openapi.Operation{
+		Method:  http.MethodGet,
+		Path:    "/readyz",
+		Summary: "Readiness probe with per-dependency checks",
+		Tags:    []string{"health"},
+		Responses: map[int]interface{}{
+			http.StatusOK:                 health.Report{},
+			http.StatusServiceUnavailable: health.Report{},
+		},
+	})
+	spec.Add(openapi.Operation{
+		Method:      http.MethodGet,
+		Path:        "/health",
+		Summary:     "Readiness probe (deprecated alias of /readyz)",
+		Tags:        []string{"health"},
+		Description: "Kept for existing health checks; use /readyz instead.",
+		Responses: map[int]interface{}{
+			http.StatusOK:                 health.Report{},
+			http.StatusServiceUnavailable: health.Report{},
+		},
+	})
+	spec.Add(openapi.Operation{
+		Method:      http.MethodGet,
+		Path:        "/metrics",
+		Summary:     "Prometheus metrics",
+		Tags:        []string{"monitoring"},
+		ContentType: "text/plain",
+		Responses:   map[int]interface{}{http.StatusOK: ""},
+	})
+	spec.Add(openapi.Operation{
+		Method:    http.MethodGet,
+		Path:      "/api/v1/ping",
+		Summary:   "Connectivity check",
+		Tags:      []string{"api"},
+		Responses: map[int]interface{}{http.StatusOK: handlers.PingResponse{}},
+		Errors:    []int{http.StatusTooManyRequests},
+	})
+	spec.Add(openapi.Operation{
+		Method:    http.MethodGet,
+		Path:      "/api/v1/me",
+		Summary:   "Current user",
+		Tags:      []string{"api"},
+		Secured:   true,
+		Responses: map[int]interface{}{http.StatusOK: handlers.MeResponse{}},
+		Errors:    []int{http.StatusTooManyRequests},
+	})
+
+	return spec
+}
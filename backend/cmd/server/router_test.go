@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/health"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/metrics"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+)
+
+func setupTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	return setupTestRouterWith(t, routerDeps{
+		limiter: middleware.NewRateLimiter(middleware.NewMemoryStore(), nil),
+	})
+}
+
+// setupTestRouterWith fills in the dependencies d leaves empty
+func setupTestRouterWith(t *testing.T, d routerDeps) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := database.Open(context.Background(), database.Config{URL: "sqlite::memory:"})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cors, err := middleware.NewCORS(middleware.DefaultCORSPolicy([]string{"http://localhost:3000"}))
+	if err != nil {
+		t.Fatalf("NewCORS() failed: %v", err)
+	}
+
+	d.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	d.handler = handlers.NewHandler(db)
+	d.checks = health.NewRegistry()
+	d.metrics = metrics.New()
+	d.cors = cors
+	d.auth = middleware.NewAuth("test-secret")
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatalf("newRouter() failed: %v", err)
+	}
+	return router
+}
+
+// TestSpecCoversRoutes fails when a route is added without describing it in
+// apiSpec, or when a spec entry outlives its route
+func TestSpecCoversRoutes(t *testing.T) {
+	router := setupTestRouter(t)
+
+	if err := apiSpec().Check(router.Routes()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenAPIEndpoints(t *testing.T) {
+	router := setupTestRouter(t)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatalf("Could not decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("Expected OpenAPI 3.1.0, got %q", doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/api/v1/me"]["get"]; !ok {
+		t.Error("Expected GET /api/v1/me in the document")
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"/openapi.json"`) {
+		t.Errorf("Expected docs page pointing at /openapi.json, got %d", rr.Code)
+	}
+}
+
+// TestForwardedForNeedsTrustedProxy checks that clients cannot dodge the
+// per-IP limit by sending a new X-Forwarded-For with every request
+func TestForwardedForNeedsTrustedProxy(t *testing.T) {
+	limits := map[string]config.RateLimit{"api": {Requests: 1, Period: time.Minute, Burst: 1}}
+	router := setupTestRouterWith(t, routerDeps{
+		limiter:        middleware.NewRateLimiter(middleware.NewMemoryStore(), limits),
+		trustedProxies: []string{"10.0.0.1"},
+	})
+
+	ping := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := ping("192.0.2.1:1234", "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("Expected the first request to pass, got %d", code)
+	}
+	if code := ping("192.0.2.1:1234", "198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Errorf("A spoofed X-Forwarded-For must not reset the bucket, got %d", code)
+	}
+
+	// Behind the trusted proxy, clients are told apart by the header
+	if code := ping("10.0.0.1:1234", "198.51.100.3"); code != http.StatusOK {
+		t.Errorf("Expected a request forwarded for a new client to pass, got %d", code)
+	}
+	if code := ping("10.0.0.1:1234", "198.51.100.3"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the forwarded client to be limited, got %d", code)
+	}
+}
@@ -0,0 +1,164 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ContentType is the media type of RFC 7807 problem details
+const ContentType = "application/problem+json"
+
+// Code is a stable, machine-readable error identifier clients can switch on
+type Code string
+
+// Error codes returned by the API
+const (
+	CodeBadRequest       Code = "bad_request"
+	CodeValidation       Code = "validation_failed"
+	CodeMissingToken     Code = "missing_token"
+	CodeInvalidToken     Code = "invalid_token"
+	CodeTokenExpired     Code = "token_expired"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeConflict         Code = "conflict"
+	CodeCORSRejected     Code = "cors_rejected"
+	CodeRateLimited      Code = "rate_limited"
+	CodeInternal         Code = "internal_error"
+	CodeUnavailable      Code = "service_unavailable"
+)
+
+// FieldError describes why a single input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an API error carrying its HTTP status and client-facing detail.
+// The wrapped cause is logged but never sent to clients.
+type Error struct {
+	Status int
+	Code   Code
+	Detail string
+	Fields []FieldError
+	cause  error
+}
+
+// New creates an error with the given status, code and client-facing detail
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Wrap returns a copy of e that records cause for logging
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.cause = cause
+	return &wrapped
+}
+
+// WithField returns a copy of e with an additional field error
+func (e *Error) WithField(field, code, message string) *Error {
+	withField := *e
+	withField.Fields = append(append([]FieldError(nil), e.Fields...), FieldError{Field: field, Code: code, Message: message})
+	return &withField
+}
+
+// BadRequest reports a malformed request
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+// Validation reports input that failed validation, one entry per field
+func Validation(fields ...FieldError) *Error {
+	return &Error{
+		Status: http.StatusUnprocessableEntity,
+		Code:   CodeValidation,
+		Detail: "The request contains invalid fields",
+		Fields: fields,
+	}
+}
+
+// Unauthorized reports missing or unusable credentials
+func Unauthorized(code Code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+// Forbidden reports an authenticated caller lacking permission
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+// NotFound reports a missing resource
+func NotFound(detail string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+// Conflict reports a request that clashes with the current state
+func Conflict(detail string) *Error {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+// Internal hides cause from the client behind a generic 500
+func Internal(cause error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "An unexpected error occurred").Wrap(cause)
+}
+
+// From converts any error into an *Error; errors that are not API errors
+// become internal errors so their messages do not leak to clients
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return Internal(err)
+}
+
+// Problem is the RFC 7807 problem details document, extended with the error
+// code, field errors and the request ID for correlation with server logs
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Problem renders e for the request path instance
+func (e *Error) Problem(instance, requestID string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  instance,
+		Code:      e.Code,
+		RequestID: requestID,
+		Errors:    e.Fields,
+	}
+}
+
+// Write serializes err as problem+json for r
+func Write(w http.ResponseWriter, r *http.Request, requestID string, err error) {
+	apiErr := From(err)
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(apiErr.Problem(r.URL.Path, requestID))
+}
@@ -0,0 +1,84 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", nil)
+	err := Validation().WithField("email", "required", "Email is required")
+
+	Write(rr, req, "req-1", err)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected content type %q, got %q", ContentType, ct)
+	}
+
+	var problem Problem
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+		t.Fatalf("Could not decode problem: %v", err)
+	}
+	expected := Problem{
+		Type:      "about:blank",
+		Title:     "Unprocessable Entity",
+		Status:    http.StatusUnprocessableEntity,
+		Detail:    "The request contains invalid fields",
+		Instance:  "/api/v1/users",
+		Code:      CodeValidation,
+		RequestID: "req-1",
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "email" || problem.Errors[0].Code != "required" {
+		t.Errorf("Expected email field error, got %v", problem.Errors)
+	}
+	problem.Errors = nil
+	if !reflect.DeepEqual(problem, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, problem)
+	}
+}
+
+func TestFromHidesInternalErrors(t *testing.T) {
+	cause := errors.New("pq: password authentication failed")
+	rr := httptest.NewRecorder()
+	Write(rr, httptest.NewRequest(http.MethodGet, "/", nil), "", cause)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "password") {
+		t.Errorf("Internal error details leaked: %s", rr.Body.String())
+	}
+
+	apiErr := From(cause)
+	if apiErr.Code != CodeInternal || !errors.Is(apiErr, cause) {
+		t.Errorf("Expected internal error wrapping the cause, got %v", apiErr)
+	}
+}
+
+func TestFromKeepsAPIErrors(t *testing.T) {
+	notFound := NotFound("User not found")
+	wrapped := errors.Join(errors.New("lookup failed"), notFound)
+
+	if got := From(wrapped); got != notFound {
+		t.Errorf("Expected the wrapped API error, got %v", got)
+	}
+}
+
+func TestWithFieldDoesNotMutate(t *testing.T) {
+	base := Validation()
+	first := base.WithField("a", "required", "A is required")
+	first.WithField("b", "required", "B is required")
+
+	if len(base.Fields) != 0 || len(first.Fields) != 1 {
+		t.Errorf("WithField should copy, got base=%v first=%v", base.Fields, first.Fields)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -83,3 +84,31 @@ func TestGetEnvAsBool(t *testing.T) {
 		t.Errorf("Expected fallback value false, got %v", result)
 	}
 }
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits([]string{"api=20/s:40", " user = 100/m ", "auth=5/10s:1"})
+	if err != nil {
+		t.Fatalf("ParseRateLimits() failed: %v", err)
+	}
+
+	expected := map[string]RateLimit{
+		"api":  {Requests: 20, Period: time.Second, Burst: 40},
+		"user": {Requests: 100, Period: time.Minute, Burst: 100},
+		"auth": {Requests: 5, Period: 10 * time.Second, Burst: 1},
+	}
+	for group, want := range expected {
+		if got := limits[group]; got != want {
+			t.Errorf("Expected %s limit %+v, got %+v", group, want, got)
+		}
+	}
+
+	invalid := []string{"api", "=10/s", "api=10", "api=0/s", "api=10/week", "api=10/s:0", "api=x/s"}
+	for _, spec := range invalid {
+		if _, err := ParseRateLimits([]string{spec}); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+	if _, err := ParseRateLimits([]string{"api=1/s", "api=2/s"}); err == nil {
+		t.Error("Expected duplicate groups to be rejected")
+	}
+}
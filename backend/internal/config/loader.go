@@ -0,0 +1,336 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// profilesKey is the config file section holding per-environment overrides
+const profilesKey = "profiles"
+
+// Loader builds the configuration from, in increasing precedence:
+// defaults, the config file, the file's profile for the selected
+// environment, environment variables and command-line flags.
+//
+// A Loader remembers its file path and flags, so Load can be called again
+// to pick up changes to the file.
+type Loader struct {
+	// Path is the config file, empty when no file is used
+	Path  string
+	flags map[string]string
+}
+
+// NewLoader registers the config flags on fs and parses args. Callers can
+// add their own flags (for example --print-config) to fs beforehand.
+func NewLoader(fs *flag.FlagSet, args []string) (*Loader, error) {
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	forEachField(Default(), func(key string, field reflect.Value, sf reflect.StructField) {
+		fs.String(flagName(key), formatValue(field), sf.Tag.Get("usage"))
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Collect the flags that were set explicitly; unset flags keep lower layers
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+
+	return &Loader{Path: *configPath, flags: flags}, nil
+}
+
+// LoadLayered parses args with NewLoader and loads the configuration once
+func LoadLayered(fs *flag.FlagSet, args []string) (*Config, error) {
+	loader, err := NewLoader(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	return loader.Load()
+}
+
+// Load reads every layer and validates the result
+func (l *Loader) Load() (*Config, error) {
+	cfg, err := l.Merge()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// Merge reads every layer without validating the result, so that an
+// invalid configuration can still be inspected
+func (l *Loader) Merge() (*Config, error) {
+	file := map[string]interface{}{}
+	if l.Path != "" {
+		var err error
+		if file, err = ReadFile(l.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	profiles, err := splitProfiles(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if err := applyMap(cfg, file); err != nil {
+		return nil, fmt.Errorf("%s: %w", l.Path, err)
+	}
+
+	// Pick the profile for the environment chosen by the higher layers
+	env := cfg.Env
+	if value, ok := os.LookupEnv(envName("env")); ok {
+		env = value
+	}
+	if value, ok := l.flags[flagName("env")]; ok {
+		env = value
+	}
+	if profile, ok := profiles[env]; ok {
+		if err := applyMap(cfg, profile); err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %w", l.Path, env, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := applyFlags(cfg, l.flags); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ReadFile decodes a YAML (.yaml, .yml) or TOML (.toml) config file
+func ReadFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q (use .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return values, nil
+}
+
+// WriteYAML writes the configuration as YAML in field order
+func (c *Config) WriteYAML(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	var encodeErr error
+	forEachField(c, func(key string, field reflect.Value, _ reflect.StructField) {
+		var value yaml.Node
+		var raw interface{} = field.Interface()
+		if d, ok := raw.(time.Duration); ok {
+			raw = d.String()
+		}
+		if err := value.Encode(raw); err != nil && encodeErr == nil {
+			encodeErr = err
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &value)
+	})
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// splitProfiles removes the profiles section from a decoded file
+func splitProfiles(file map[string]interface{}) (map[string]map[string]interface{}, error) {
+	raw, ok := file[profilesKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(file, profilesKey)
+
+	section, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a mapping of environment names to settings", profilesKey)
+	}
+
+	profiles := make(map[string]map[string]interface{}, len(section))
+	for env, values := range section {
+		profile, ok := values.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profile %q must be a mapping", env)
+		}
+		profiles[env] = profile
+	}
+	return profiles, nil
+}
+
+// applyMap applies decoded file values, rejecting unknown keys
+func applyMap(cfg *Config, values map[string]interface{}) error {
+	known := make(map[string]bool)
+	var errs []error
+	forEachField(cfg, func(key string, field reflect.Value, _ reflect.StructField) {
+		known[key] = true
+		value, ok := values[key]
+		if !ok {
+			return
+		}
+		if err := setValue(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", key, err))
+		}
+	})
+
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("unknown setting %q", key))
+	}
+	return errors.Join(errs...)
+}
+
+// applyEnv applies environment variables, failing on values that do not parse
+func applyEnv(cfg *Config) error {
+	var errs []error
+	forEachField(cfg, func(key string, field reflect.Value, _ reflect.StructField) {
+		value, ok := os.LookupEnv(envName(key))
+		if !ok {
+			return
+		}
+		if err := setString(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", envName(key), err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// applyFlags applies explicitly set flags
+func applyFlags(cfg *Config, flags map[string]string) error {
+	var errs []error
+	forEachField(cfg, func(key string, field reflect.Value, _ reflect.StructField) {
+		value, ok := flags[flagName(key)]
+		if !ok {
+			return
+		}
+		if err := setString(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %v", flagName(key), err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// setValue assigns a value decoded from YAML or TOML to field
+func setValue(field reflect.Value, value interface{}) error {
+	if list, ok := value.([]interface{}); ok {
+		if field.Type() != reflect.TypeOf([]string(nil)) {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		field.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		// Bare numbers in files are seconds; strings use Go duration syntax
+		switch n := value.(type) {
+		case int:
+			field.SetInt(int64(time.Duration(n) * time.Second))
+			return nil
+		case int64:
+			field.SetInt(int64(time.Duration(n) * time.Second))
+			return nil
+		}
+	}
+
+	return setString(field, fmt.Sprint(value))
+}
+
+// setString parses s into field according to the field's type
+func setString(field reflect.Value, s string) error {
+	switch v := field.Addr().Interface().(type) {
+	case *string:
+		*v = s
+	case *int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		*v = n
+	case *bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		*v = b
+	case *time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (use values like 30s or 5m)", s)
+		}
+		*v = d
+	case *[]string:
+		*v = parseList(s)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// parseList splits a comma-separated list, dropping empty items
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// formatValue renders a field the way it would be written on the command line
+func formatValue(field reflect.Value) string {
+	if list, ok := field.Interface().([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// flagName maps a config key to its command-line flag name
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
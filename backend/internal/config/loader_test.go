@@ -0,0 +1,231 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `
+port: "9000"
+cors_origins:
+  - http://localhost:3000
+  - https://*.example.com
+read_timeout: 20s
+db_max_open_conns: 50
+profiles:
+  production:
+    port: "80"
+    jwt_secret: production-secret-that-is-long-enough
+    db_conn_max_lifetime: 600
+`
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadArgs(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	return LoadLayered(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func TestLoadLayeredDefaults(t *testing.T) {
+	cfg, err := loadArgs(t)
+	if err != nil {
+		t.Fatalf("LoadLayered() failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Expected defaults, got %+v", cfg)
+	}
+}
+
+func TestLoadLayeredFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", testYAML)
+
+	cfg, err := loadArgs(t, "--config", path)
+	if err != nil {
+		t.Fatalf("LoadLayered() failed: %v", err)
+	}
+
+	if cfg.Port != "9000" {
+		t.Errorf("Expected port '9000' from file, got '%s'", cfg.Port)
+	}
+	if want := []string{"http://localhost:3000", "https://*.example.com"}; !reflect.DeepEqual(cfg.CORSOrigins, want) {
+		t.Errorf("Expected origins %v, got %v", want, cfg.CORSOrigins)
+	}
+	if cfg.ReadTimeout != 20*time.Second {
+		t.Errorf("Expected read timeout 20s, got %s", cfg.ReadTimeout)
+	}
+	if cfg.DBMaxOpenConns != 50 {
+		t.Errorf("Expected 50 open conns, got %d", cfg.DBMaxOpenConns)
+	}
+	if cfg.JWTSecret != DefaultJWTSecret {
+		t.Error("Production profile should not apply in development")
+	}
+}
+
+func TestLoadLayeredProfileAndPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", testYAML)
+	t.Setenv("ENV", "production")
+	t.Setenv("DB_MAX_OPEN_CONNS", "75")
+
+	cfg, err := loadArgs(t, "--config", path, "--db-max-open-conns", "100")
+	if err != nil {
+		t.Fatalf("LoadLayered() failed: %v", err)
+	}
+
+	if cfg.Port != "80" {
+		t.Errorf("Expected production profile port '80', got '%s'", cfg.Port)
+	}
+	if cfg.DBConnMaxLifetime != 10*time.Minute {
+		t.Errorf("Expected bare number to be read as seconds, got %s", cfg.DBConnMaxLifetime)
+	}
+	if cfg.DBMaxOpenConns != 100 {
+		t.Errorf("Expected flag to override env and file, got %d", cfg.DBMaxOpenConns)
+	}
+	if cfg.ReadTimeout != 20*time.Second {
+		t.Errorf("Expected base file value to survive profile, got %s", cfg.ReadTimeout)
+	}
+}
+
+func TestLoadLayeredTOML(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+port = "7000"
+cors_origins = ["http://a.test", "http://b.test"]
+write_timeout = "1m"
+
+[profiles.test]
+port = "7001"
+`)
+
+	cfg, err := loadArgs(t, "--config", path, "--env", "test")
+	if err != nil {
+		t.Fatalf("LoadLayered() failed: %v", err)
+	}
+	if cfg.Port != "7001" {
+		t.Errorf("Expected test profile port '7001', got '%s'", cfg.Port)
+	}
+	if len(cfg.CORSOrigins) != 2 {
+		t.Errorf("Expected 2 origins, got %v", cfg.CORSOrigins)
+	}
+	if cfg.WriteTimeout != time.Minute {
+		t.Errorf("Expected write timeout 1m, got %s", cfg.WriteTimeout)
+	}
+}
+
+func TestLoadLayeredErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{"unknown file key", "prot: 8080\n", nil, nil, `unknown setting "prot"`},
+		{"bad file duration", "read_timeout: soon\n", nil, nil, "invalid duration"},
+		{"bad env int", "", map[string]string{"DB_MAX_OPEN_CONNS": "many"}, nil, "DB_MAX_OPEN_CONNS"},
+		{"bad flag", "", nil, []string{"--write-timeout", "forever"}, "--write-timeout"},
+		{"default secret in production", "", map[string]string{"ENV": "production"}, nil, "changed from the default"},
+		{"short secret in production", "", map[string]string{"ENV": "production", "JWT_SECRET": "short"}, nil, "at least 32 characters"},
+		{"invalid port", "", nil, []string{"--port", "http"}, "port must be"},
+		{"unknown env", "", nil, []string{"--env", "staging"}, "env must be one of"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"--config", writeConfigFile(t, "config.yaml", tt.file)}, args...)
+			}
+
+			_, err := loadArgs(t, args...)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMergeSkipsValidation(t *testing.T) {
+	loader, err := NewLoader(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--env", "staging"})
+	if err != nil {
+		t.Fatalf("NewLoader() failed: %v", err)
+	}
+
+	cfg, err := loader.Merge()
+	if err != nil {
+		t.Fatalf("Merge() failed: %v", err)
+	}
+	if cfg.Env != "staging" {
+		t.Errorf("Expected env staging, got %q", cfg.Env)
+	}
+	if _, err := loader.Load(); err == nil {
+		t.Error("Expected Load() to reject the merged configuration")
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Port = "0"
+	cfg.DatabaseURL = ""
+	cfg.ShutdownTimeout = 0
+	cfg.TrustedProxies = []string{"10.0.0.0/8", "not-an-ip"}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation to fail")
+	}
+	for _, want := range []string{"port", "database_url", "shutdown_timeout", `trusted_proxies must list IPs or CIDRs; got "not-an-ip"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestRedactedWriteYAML(t *testing.T) {
+	cfg := Default()
+	cfg.JWTSecret = "super-secret-value"
+
+	var buf bytes.Buffer
+	if err := cfg.Redacted().WriteYAML(&buf); err != nil {
+		t.Fatalf("WriteYAML() failed: %v", err)
+	}
+	out := buf.String()
+
+	if strings.Contains(out, "super-secret-value") || strings.Contains(out, "coursepass") {
+		t.Errorf("Secrets should be redacted, got:\n%s", out)
+	}
+	for _, want := range []string{"jwt_secret: '[REDACTED]'", "courseuser:xxxxx@", "read_timeout: 15s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if cfg.JWTSecret != "super-secret-value" {
+		t.Error("Redacted() should not modify the original config")
+	}
+
+	// Printed config must load back into the same values
+	reloaded, err := loadArgs(t, "--config", writeConfigFile(t, "printed.yaml", strings.ReplaceAll(out, "'[REDACTED]'", "super-secret-value")))
+	if err != nil {
+		t.Fatalf("Printed config should be loadable: %v", err)
+	}
+	if reloaded.ReadTimeout != cfg.ReadTimeout || !reflect.DeepEqual(reloaded.CORSOrigins, cfg.CORSOrigins) {
+		t.Errorf("Round trip changed values: %+v", reloaded)
+	}
+}
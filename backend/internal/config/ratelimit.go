@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a token-bucket limit of Requests per Period that allows
+// bursts of up to Burst requests
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Rate returns the refill rate in tokens per second
+func (l RateLimit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// periodUnits are the shorthand periods accepted after the slash
+var periodUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParseRateLimits parses entries of the form group=requests/period[:burst],
+// where period is s, m, h or a Go duration such as 10s. The burst defaults
+// to the number of requests.
+func ParseRateLimits(specs []string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit, len(specs))
+	var errs []error
+	for _, spec := range specs {
+		group, limit, err := parseRateLimit(spec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, dup := limits[group]; dup {
+			errs = append(errs, fmt.Errorf("duplicate limit for group %q", group))
+			continue
+		}
+		limits[group] = limit
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func parseRateLimit(spec string) (string, RateLimit, error) {
+	invalid := fmt.Errorf("invalid limit %q (use group=requests/period[:burst], e.g. api=100/m:20)", spec)
+
+	group, rest, ok := strings.Cut(strings.TrimSpace(spec), "=")
+	group = strings.TrimSpace(group)
+	if !ok || group == "" {
+		return "", RateLimit{}, invalid
+	}
+
+	rest, burstStr, hasBurst := strings.Cut(rest, ":")
+	requestsStr, periodStr, ok := strings.Cut(rest, "/")
+	if !ok {
+		return "", RateLimit{}, invalid
+	}
+
+	requests, err := strconv.Atoi(strings.TrimSpace(requestsStr))
+	if err != nil || requests <= 0 {
+		return "", RateLimit{}, invalid
+	}
+
+	periodStr = strings.TrimSpace(periodStr)
+	period, ok := periodUnits[periodStr]
+	if !ok {
+		if period, err = time.ParseDuration(periodStr); err != nil || period <= 0 {
+			return "", RateLimit{}, invalid
+		}
+	}
+
+	burst := requests
+	if hasBurst {
+		if burst, err = strconv.Atoi(strings.TrimSpace(burstStr)); err != nil || burst <= 0 {
+			return "", RateLimit{}, invalid
+		}
+	}
+
+	return group, RateLimit{Requests: requests, Period: period, Burst: burst}, nil
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// DefaultPollInterval is how often the watcher checks the config file
+const DefaultPollInterval = 2 * time.Second
+
+// Subscriber is notified after a new configuration has been swapped in
+type Subscriber func(old, new *Config)
+
+// Watcher keeps the current configuration and reloads it when the config
+// file changes on disk or the process receives SIGHUP. A reloaded config is
+// validated before it replaces the current one; invalid reloads are logged
+// and the previous config stays active.
+//
+// Only settings read through Config() or a subscriber change at runtime;
+// values captured at startup (port, database URL) still need a restart.
+type Watcher struct {
+	loader       *Loader
+	current      atomic.Pointer[Config]
+	PollInterval time.Duration
+
+	mu          sync.Mutex
+	subscribers []Subscriber
+	fileSum     [sha256.Size]byte
+}
+
+// NewWatcher loads the initial configuration with loader
+func NewWatcher(loader *Loader) (*Watcher, error) {
+	cfg, err := loader.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{loader: loader, PollInterval: DefaultPollInterval}
+	w.current.Store(cfg)
+	w.fileSum, _ = w.checksum()
+	return w, nil
+}
+
+// Config returns the current configuration. The returned value must not be modified.
+func (w *Watcher) Config() *Config {
+	return w.current.Load()
+}
+
+// Subscribe registers fn to be called after every successful reload
+func (w *Watcher) Subscribe(fn Subscriber) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Reload loads and validates the configuration, swaps it in and notifies
+// subscribers. On error the current configuration is kept.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	cfg, err := w.loader.Load()
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	w.fileSum, _ = w.checksum()
+
+	old := w.current.Swap(cfg)
+	subscribers := append([]Subscriber(nil), w.subscribers...)
+	w.mu.Unlock()
+
+	// Subscribers run unlocked so they may call Subscribe or Config
+	for _, fn := range subscribers {
+		fn(old, cfg)
+	}
+	return nil
+}
+
+// Run watches for file changes and SIGHUP until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	if w.loader.Path != "" && w.PollInterval > 0 {
+		ticker := time.NewTicker(w.PollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.reloadAndLog("SIGHUP")
+		case <-poll:
+			if w.fileChanged() {
+				w.reloadAndLog(w.loader.Path + " changed")
+			}
+		}
+	}
+}
+
+func (w *Watcher) reloadAndLog(reason string) {
+	if err := w.Reload(); err != nil {
+		log.Printf("⚠️ Config reload (%s) rejected, keeping current config: %v", reason, err)
+		return
+	}
+	log.Printf("🔄 Config reloaded (%s)", reason)
+}
+
+func (w *Watcher) fileChanged() bool {
+	sum, err := w.checksum()
+	if err != nil {
+		// The file may be mid-rewrite; try again on the next tick
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sum == w.fileSum {
+		return false
+	}
+	// Remember the contents so an invalid file is reported only once
+	w.fileSum = sum
+	return true
+}
+
+func (w *Watcher) checksum() ([sha256.Size]byte, error) {
+	if w.loader.Path == "" {
+		return [sha256.Size]byte{}, nil
+	}
+	data, err := os.ReadFile(w.loader.Path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestWatcher(t *testing.T, content string) (*Watcher, string) {
+	t.Helper()
+	path := writeConfigFile(t, "config.yaml", content)
+
+	loader, err := NewLoader(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--config", path})
+	if err != nil {
+		t.Fatalf("NewLoader() failed: %v", err)
+	}
+	w, err := NewWatcher(loader)
+	if err != nil {
+		t.Fatalf("NewWatcher() failed: %v", err)
+	}
+	return w, path
+}
+
+func TestWatcherReload(t *testing.T) {
+	w, path := newTestWatcher(t, "cors_origins: [http://a.test]\n")
+
+	var notified []string
+	w.Subscribe(func(old, new *Config) {
+		notified = append(notified, old.CORSOrigins[0]+" -> "+new.CORSOrigins[0])
+	})
+
+	if err := os.WriteFile(path, []byte("cors_origins: [http://b.test]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+
+	if got := w.Config().CORSOrigins[0]; got != "http://b.test" {
+		t.Errorf("Expected reloaded origin, got %s", got)
+	}
+	if len(notified) != 1 || notified[0] != "http://a.test -> http://b.test" {
+		t.Errorf("Unexpected notifications %v", notified)
+	}
+}
+
+func TestWatcherSubscriberMaySubscribe(t *testing.T) {
+	w, _ := newTestWatcher(t, "port: \"9000\"\n")
+
+	w.Subscribe(func(old, new *Config) {
+		w.Subscribe(func(old, new *Config) {})
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- w.Reload() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Reload() failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Reload() deadlocked calling a subscriber")
+	}
+}
+
+func TestWatcherRejectsInvalidReload(t *testing.T) {
+	w, path := newTestWatcher(t, "port: \"9000\"\n")
+
+	called := false
+	w.Subscribe(func(old, new *Config) { called = true })
+
+	if err := os.WriteFile(path, []byte("port: \"not-a-port\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reload(); err == nil {
+		t.Fatal("Expected Reload() to reject an invalid config")
+	}
+
+	if w.Config().Port != "9000" {
+		t.Errorf("Invalid reload should keep the previous config, got port %s", w.Config().Port)
+	}
+	if called {
+		t.Error("Subscribers should not be notified of a rejected reload")
+	}
+}
+
+func TestWatcherPollsFile(t *testing.T) {
+	w, path := newTestWatcher(t, "cors_origins: [http://a.test]\n")
+	w.PollInterval = 10 * time.Millisecond
+
+	reloaded := make(chan *Config, 1)
+	w.Subscribe(func(old, new *Config) { reloaded <- new })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	if err := os.WriteFile(path, []byte("cors_origins: [http://c.test]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case cfg := <-reloaded:
+		if cfg.CORSOrigins[0] != "http://c.test" {
+			t.Errorf("Expected new origin, got %v", cfg.CORSOrigins)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watcher did not pick up the file change")
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+// Supported driver names
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Config holds database connection and pool configuration
+type Config struct {
+	URL             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// ConfigFrom builds a database configuration from the application config
+func ConfigFrom(cfg *config.Config) Config {
+	return Config{
+		URL:             cfg.DatabaseURL,
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	}
+}
+
+// DB wraps a connection pool together with the driver it was opened with
+type DB struct {
+	*sql.DB
+	Driver string
+}
+
+// Open opens a connection pool, applies pool settings and verifies the
+// connection. Postgres URLs use the postgres driver; URLs starting with
+// "sqlite:" open an SQLite database (e.g. sqlite://app.db or sqlite::memory:).
+func Open(ctx context.Context, cfg Config) (*DB, error) {
+	driver, dsn, err := ParseURL(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	maxOpen := cfg.MaxOpenConns
+	if driver == DriverSQLite && dsn == ":memory:" {
+		// Every connection to :memory: gets its own empty database
+		maxOpen = 1
+	}
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	return &DB{DB: sqlDB, Driver: driver}, nil
+}
+
+// ParseURL maps a database URL to a driver name and data source name
+func ParseURL(url string) (string, string, error) {
+	switch {
+	case url == "":
+		return "", "", fmt.Errorf("database URL cannot be empty")
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return DriverPostgres, url, nil
+	case strings.HasPrefix(url, "sqlite://"):
+		return DriverSQLite, strings.TrimPrefix(url, "sqlite://"), nil
+	case strings.HasPrefix(url, "sqlite:"):
+		return DriverSQLite, strings.TrimPrefix(url, "sqlite:"), nil
+	default:
+		return "", "", fmt.Errorf("unsupported database URL scheme: %s", url)
+	}
+}
+
+// Check verifies that the database is reachable and reports the round-trip latency
+func (db *DB) Check(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	err := db.DB.PingContext(ctx)
+	return time.Since(start), err
+}
+
+// Close closes the connection pool. It is safe to call on a nil DB.
+func (db *DB) Close() error {
+	if db == nil || db.DB == nil {
+		return nil
+	}
+	return db.DB.Close()
+}
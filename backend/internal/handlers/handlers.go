@@ -4,20 +4,50 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
 )
 
-// HealthCheck returns server health status
-func HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"service": "sum25-go-flutter-course-backend",
-		"version": "1.0.0",
-	})
+// Handler holds the dependencies shared by HTTP handlers
+type Handler struct {
+	// db is the shared connection pool for handlers that query the database
+	db *database.DB
+}
+
+// NewHandler creates a new handler instance
+func NewHandler(db *database.DB) *Handler {
+	return &Handler{db: db}
+}
+
+// PingResponse is returned by Ping
+type PingResponse struct {
+	Message string `json:"message"`
+}
+
+// MeResponse describes the authenticated user
+type MeResponse struct {
+	UserID int      `json:"user_id"`
+	Email  string   `json:"email"`
+	Roles  []string `json:"roles"`
 }
 
 // Ping returns a simple pong response
-func Ping(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "pong",
+func (h *Handler) Ping(c *gin.Context) {
+	c.JSON(http.StatusOK, PingResponse{Message: "pong"})
+}
+
+// Me returns the claims of the authenticated user
+func (h *Handler) Me(c *gin.Context) error {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		return apierror.Unauthorized(apierror.CodeMissingToken, "Authentication is required")
+	}
+
+	c.JSON(http.StatusOK, MeResponse{
+		UserID: claims.UserID,
+		Email:  claims.Email,
+		Roles:  claims.Roles,
 	})
+	return nil
 }
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+)
+
+func setupTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := database.Open(context.Background(), database.Config{URL: "sqlite::memory:"})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	h := NewHandler(db)
+	router := gin.New()
+	router.GET("/ping", h.Ping)
+	return router
+}
+
+func TestPing(t *testing.T) {
+	router := setupTestRouter(t)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestMeRequiresClaims(t *testing.T) {
+	router := setupTestRouter(t)
+	router.GET("/me", middleware.Handle(NewHandler(nil).Me))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/me", nil))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != apierror.ContentType {
+		t.Errorf("Expected problem content type, got %q", ct)
+	}
+}
@@ -0,0 +1,205 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/version"
+)
+
+// DefaultTimeout is used for checks registered without a timeout
+const DefaultTimeout = 2 * time.Second
+
+// Check statuses reported per probe and overall
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Errors reported for failed checks; the underlying error is only logged so
+// that public probes do not leak driver messages or addresses
+const (
+	ErrorUnavailable = "unavailable"
+	ErrorTimeout     = "timeout"
+)
+
+// CheckFunc reports whether a dependency is usable; it should honour ctx
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of a single registered check
+type CheckResult struct {
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+
+	// err is the failure behind Error, kept for the log only
+	err error
+}
+
+// Report is the aggregated result served by the readiness endpoint
+type Report struct {
+	Status  string                 `json:"status"`
+	Version string                 `json:"version"`
+	Commit  string                 `json:"commit"`
+	Checks  map[string]CheckResult `json:"checks"`
+}
+
+// Liveness is the body served by the liveness endpoint
+type Liveness struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// Registry holds the readiness checks registered by components
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]check
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]check)}
+}
+
+// Register adds a named check, replacing any check with the same name
+func (r *Registry) Register(name string, timeout time.Duration, fn CheckFunc) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check{name: name, timeout: timeout, fn: fn}
+}
+
+// Names returns the registered check names in sorted order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run executes all checks concurrently and aggregates their results
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make([]check, 0, len(r.checks))
+	for _, c := range r.checks {
+		checks = append(checks, c)
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:  StatusOK,
+		Version: version.Version,
+		Commit:  version.Commit,
+		Checks:  make(map[string]CheckResult, len(checks)),
+	}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+// run executes the check, giving up once its timeout expires even if the
+// check itself ignores the context
+func (c check) run(ctx context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+	reason := ErrorUnavailable
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", c.timeout)
+		reason = ErrorTimeout
+	}
+
+	result := CheckResult{
+		Status:     StatusUp,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = reason
+		result.err = err
+	}
+	return result
+}
+
+// LiveHandler reports that the process is running; it never consults
+// dependencies so a failing database does not get the container restarted
+func (r *Registry) LiveHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, Liveness{
+			Status:  StatusOK,
+			Version: version.Version,
+			Commit:  version.Commit,
+		})
+	}
+}
+
+// ReadyHandler runs every check and responds 503 if any of them fails
+func (r *Registry) ReadyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := r.Run(c.Request.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+			for name, result := range report.Checks {
+				if result.Status != StatusUp {
+					logging.FromContext(c.Request.Context()).Warn("readiness check failed", "check", name, "error", result.err)
+				}
+			}
+		}
+		c.JSON(status, report)
+	}
+}
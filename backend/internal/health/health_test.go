@@ -0,0 +1,145 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/version"
+)
+
+func setupHealthRouter(r *Registry) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/livez", r.LiveHandler())
+	router.GET("/readyz", r.ReadyHandler())
+	return router
+}
+
+func getReport(t *testing.T, router *gin.Engine, path string) (int, Report) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report Report
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	return rr.Code, report
+}
+
+func TestReadyAllUp(t *testing.T) {
+	r := NewRegistry()
+	r.Register("database", time.Second, func(ctx context.Context) error { return nil })
+	r.Register("cache", time.Second, func(ctx context.Context) error { return nil })
+
+	code, report := getReport(t, setupHealthRouter(r), "/readyz")
+	if code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if report.Status != StatusOK {
+		t.Errorf("Expected status %q, got %q", StatusOK, report.Status)
+	}
+	if len(report.Checks) != 2 || report.Checks["database"].Status != StatusUp {
+		t.Errorf("Expected both checks up, got %v", report.Checks)
+	}
+	if report.Version != version.Version || report.Commit != version.Commit {
+		t.Errorf("Expected build info %s/%s, got %s/%s", version.Version, version.Commit, report.Version, report.Commit)
+	}
+}
+
+func TestReadyFailures(t *testing.T) {
+	r := NewRegistry()
+	r.Register("database", time.Second, func(ctx context.Context) error { return nil })
+	r.Register("broker", time.Second, func(ctx context.Context) error { return errors.New("connection refused") })
+	r.Register("cache", 20*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	r.Register("flaky", time.Second, func(ctx context.Context) error { panic("boom") })
+
+	start := time.Now()
+	code, report := getReport(t, setupHealthRouter(r), "/readyz")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Slow check should be cut off by its timeout, took %s", elapsed)
+	}
+
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if report.Status != StatusUnavailable {
+		t.Errorf("Expected status %q, got %q", StatusUnavailable, report.Status)
+	}
+
+	tests := []struct {
+		name   string
+		status string
+		error  string
+	}{
+		{"database", StatusUp, ""},
+		{"broker", StatusDown, ErrorUnavailable},
+		{"cache", StatusDown, ErrorTimeout},
+		{"flaky", StatusDown, ErrorUnavailable},
+	}
+	for _, tt := range tests {
+		got := report.Checks[tt.name]
+		if got.Status != tt.status || got.Error != tt.error {
+			t.Errorf("Check %s: expected %s %q, got %s %q", tt.name, tt.status, tt.error, got.Status, got.Error)
+		}
+	}
+}
+
+func TestLiveIgnoresChecks(t *testing.T) {
+	r := NewRegistry()
+	r.Register("database", time.Second, func(ctx context.Context) error { return errors.New("down") })
+
+	code, report := getReport(t, setupHealthRouter(r), "/livez")
+	if code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if report.Status != StatusOK {
+		t.Errorf("Expected status %q, got %q", StatusOK, report.Status)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := NewRegistry()
+	r.Register("b", 0, func(ctx context.Context) error { return nil })
+	r.Register("a", time.Second, func(ctx context.Context) error { return nil })
+	r.Register("a", time.Minute, func(ctx context.Context) error { return nil })
+
+	names := r.Names()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("Expected [a b], got %v", names)
+	}
+	if r.checks["b"].timeout != DefaultTimeout {
+		t.Errorf("Expected default timeout, got %s", r.checks["b"].timeout)
+	}
+	if r.checks["a"].timeout != time.Minute {
+		t.Errorf("Expected re-registration to replace the check, got %s", r.checks["a"].timeout)
+	}
+}
+
+func TestFailureCauseNotServed(t *testing.T) {
+	r := NewRegistry()
+	r.Register("database", time.Second, func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	})
+
+	report := r.Run(context.Background())
+	if err := report.Checks["database"].err; err == nil || !strings.Contains(err.Error(), "10.0.0.5") {
+		t.Errorf("Expected the cause to be kept for logging, got %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	setupHealthRouter(r).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if strings.Contains(rr.Body.String(), "10.0.0.5") {
+		t.Errorf("Expected the cause to stay out of the response, got %s", rr.Body.String())
+	}
+}
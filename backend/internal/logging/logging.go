@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// contextKey is the type of context keys defined by this package
+type contextKey struct{}
+
+// New creates a logger writing to w in the given format ("json" or "text").
+// The returned LevelVar controls the minimum level and can be changed at
+// runtime, for example when the config is reloaded.
+func New(w io.Writer, format, level string) (*slog.Logger, *slog.LevelVar, error) {
+	levelVar := new(slog.LevelVar)
+	if err := SetLevel(levelVar, level); err != nil {
+		return nil, nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: levelVar}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	return slog.New(handler), levelVar, nil
+}
+
+// SetLevel parses level (debug, info, warn, error) and applies it to levelVar
+func SetLevel(levelVar *slog.LevelVar, level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	levelVar.Set(l)
+	return nil
+}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger, or the default logger when
+// ctx carries none
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _, err := New(&buf, "json", "info")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	logger.Info("hello", "key", "value")
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "hello" || entry["key"] != "value" {
+		t.Errorf("Unexpected entry %v", entry)
+	}
+
+	buf.Reset()
+	textLogger, _, err := New(&buf, "text", "info")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	textLogger.Info("hello")
+	if !strings.Contains(buf.String(), "msg=hello") {
+		t.Errorf("Expected text output, got %q", buf.String())
+	}
+
+	if _, _, err := New(&buf, "xml", "info"); err == nil {
+		t.Error("Unknown format should fail")
+	}
+	if _, _, err := New(&buf, "json", "loud"); err == nil {
+		t.Error("Unknown level should fail")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, level, err := New(&buf, "text", "warn")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("Info should be filtered at warn level, got %q", buf.String())
+	}
+
+	if err := SetLevel(level, "debug"); err != nil {
+		t.Fatalf("SetLevel() failed: %v", err)
+	}
+	logger.Debug("shown")
+	if !strings.Contains(buf.String(), "shown") {
+		t.Error("Debug should be logged after lowering the level")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("Expected default logger for a bare context")
+	}
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	ctx := WithLogger(context.Background(), logger)
+	if FromContext(ctx) != logger {
+		t.Error("Expected logger stored in context")
+	}
+}
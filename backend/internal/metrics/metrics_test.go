@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/database"
+)
+
+func scrape(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d from /metrics, got %d", http.StatusOK, rr.Code)
+	}
+	body, _ := io.ReadAll(rr.Body)
+	return string(body)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+
+	db, err := database.Open(context.Background(), database.Config{URL: "sqlite::memory:"})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := m.RegisterDB(db.DB, "main"); err != nil {
+		t.Fatalf("RegisterDB() failed: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/metrics", m.Handler())
+	router.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for _, path := range []string{"/users/1", "/users/2", "/nope"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, router)
+	expected := []string{
+		`backend_http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`backend_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`backend_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="+Inf"} 2`,
+		`backend_http_requests_in_flight{route="/metrics"} 1`,
+		`go_goroutines`,
+		`go_sql_max_open_connections{db_name="main"} 1`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+	if strings.Contains(body, `route="/nope"`) {
+		t.Error("Unmatched paths should not become route labels")
+	}
+	if !strings.Contains(body, `backend_http_requests_in_flight{route="/users/:id"} 0`) {
+		t.Error("In-flight gauge should return to zero after requests finish")
+	}
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/authz"
+)
+
+// claimsKey is the gin.Context key under which authenticated claims are stored
+const claimsKey = "auth.claims"
+
+// errTokenExpired lets the middleware distinguish expired tokens from invalid ones
+var errTokenExpired = errors.New("token expired")
+
+// Claims represents the JWT claims attached to an authenticated request
+type Claims struct {
+	UserID int      `json:"user_id"`
+	Email  string   `json:"email"`
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Subject returns the claims as an authorization subject
+func (c *Claims) Subject() authz.Subject {
+	return authz.Subject{UserID: c.UserID, Roles: c.Roles, Scopes: c.Scopes}
+}
+
+// HasRole reports whether the claims carry the given role
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Auth verifies Bearer tokens signed with a shared HMAC secret
+type Auth struct {
+	secret []byte
+}
+
+// NewAuth creates an authenticator for tokens signed with secret
+func NewAuth(secret string) *Auth {
+	return &Auth{secret: []byte(secret)}
+}
+
+// RequireAuth rejects requests without a valid Bearer token
+func (a *Auth) RequireAuth() gin.HandlerFunc {
+	return a.handler(true)
+}
+
+// OptionalAuth attaches claims when a valid Bearer token is present and lets
+// anonymous requests through. A malformed or invalid token is still rejected.
+func (a *Auth) OptionalAuth() gin.HandlerFunc {
+	return a.handler(false)
+}
+
+func (a *Auth) handler(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			if required {
+				abortUnauthorized(c, apierror.CodeMissingToken, "Authorization header with Bearer token is required")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			abortUnauthorized(c, apierror.CodeInvalidToken, "Authorization header must use the Bearer scheme")
+			return
+		}
+
+		claims, err := a.ParseToken(strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, errTokenExpired) {
+				abortUnauthorized(c, apierror.CodeTokenExpired, "Token has expired")
+				return
+			}
+			abortUnauthorized(c, apierror.CodeInvalidToken, "Token is invalid")
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// ParseToken verifies the token signature and expiry and returns its claims
+func (a *Auth) ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return a.secret, nil
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, errTokenExpired
+		}
+		return nil, err
+	}
+	// jwt only checks exp when it is present; a token without one would
+	// never expire
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	if claims.UserID <= 0 {
+		return nil, errors.New("token has no user")
+	}
+	return claims, nil
+}
+
+// RequireRole rejects authenticated requests whose claims carry none of roles.
+// It must run after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			abortUnauthorized(c, apierror.CodeMissingToken, "Authentication is required")
+			return
+		}
+		for _, role := range roles {
+			if claims.HasRole(role) {
+				c.Next()
+				return
+			}
+		}
+		AbortWithError(c, apierror.Forbidden("Insufficient permissions"))
+	}
+}
+
+// RequirePermission rejects authenticated requests unless policy grants
+// every one of permissions to the claims' roles and scopes. It must run after
+// RequireAuth.
+func RequirePermission(policy *authz.Policy, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			abortUnauthorized(c, apierror.CodeMissingToken, "Authentication is required")
+			return
+		}
+		for _, permission := range permissions {
+			if err := policy.Authorize(claims.Subject(), permission); err != nil {
+				AbortWithError(c, apierror.Forbidden("Missing permission "+permission).Wrap(err))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// GetClaims returns the claims of the authenticated user, if any
+func GetClaims(c *gin.Context) (*Claims, bool) {
+	value, exists := c.Get(claimsKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
+
+func abortUnauthorized(c *gin.Context, code apierror.Code, message string) {
+	if code == apierror.CodeMissingToken {
+		c.Header("WWW-Authenticate", "Bearer")
+	} else {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	AbortWithError(c, apierror.Unauthorized(code, message))
+}
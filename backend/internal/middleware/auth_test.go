@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/authz"
+)
+
+const testSecret = "test-secret"
+
+func signTestToken(t *testing.T, secret string, claims Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func validClaims(roles ...string) Claims {
+	return Claims{
+		UserID: 42,
+		Email:  "user@example.com",
+		Roles:  roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func setupAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuth(testSecret)
+
+	router := gin.New()
+	whoami := func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"user_id": 0})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user_id": claims.UserID})
+	}
+	router.GET("/required", auth.RequireAuth(), whoami)
+	router.GET("/optional", auth.OptionalAuth(), whoami)
+	router.GET("/admin", auth.RequireAuth(), RequireRole("admin"), whoami)
+	router.GET("/categories", auth.RequireAuth(), RequirePermission(authz.DefaultPolicy(), authz.CategoriesWrite), whoami)
+	return router
+}
+
+func TestRequireAuth(t *testing.T) {
+	router := setupAuthRouter()
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantError  string
+	}{
+		{"valid token", "Bearer " + signTestToken(t, testSecret, validClaims()), http.StatusOK, ""},
+		{"lowercase scheme", "bearer " + signTestToken(t, testSecret, validClaims()), http.StatusOK, ""},
+		{"missing header", "", http.StatusUnauthorized, "missing_token"},
+		{"wrong scheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "invalid_token"},
+		{"garbage token", "Bearer not-a-jwt", http.StatusUnauthorized, "invalid_token"},
+		{"wrong secret", "Bearer " + signTestToken(t, "other-secret", validClaims()), http.StatusUnauthorized, "invalid_token"},
+		{"expired token", "Bearer " + signTestToken(t, testSecret, expired), http.StatusUnauthorized, "token_expired"},
+		{"token without expiry", "Bearer " + signTestToken(t, testSecret, noExpiry), http.StatusUnauthorized, "invalid_token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/required", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantError == "" {
+				return
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			if body["code"] != tt.wantError {
+				t.Errorf("Expected error '%s', got '%v'", tt.wantError, body["code"])
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != apierror.ContentType {
+				t.Errorf("Expected problem content type, got %q", ct)
+			}
+			if rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	router := setupAuthRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/optional", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Anonymous request should pass, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/optional", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testSecret, validClaims()))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var body map[string]int
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if body["user_id"] != 42 {
+		t.Errorf("Expected claims to be attached, got user_id %d", body["user_id"])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/optional", nil)
+	req.Header.Set("Authorization", "Bearer not-a-jwt")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Invalid token should be rejected, got %d", rr.Code)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	router := setupAuthRouter()
+
+	tests := []struct {
+		name       string
+		roles      []string
+		wantStatus int
+	}{
+		{"admin", []string{"user", "admin"}, http.StatusOK},
+		{"no roles", nil, http.StatusForbidden},
+		{"other role", []string{"user"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, testSecret, validClaims(tt.roles...)))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	router := setupAuthRouter()
+
+	tests := []struct {
+		name       string
+		roles      []string
+		scopes     []string
+		wantStatus int
+	}{
+		{"editor", []string{authz.RoleEditor}, nil, http.StatusOK},
+		{"admin", []string{authz.RoleAdmin}, nil, http.StatusOK},
+		{"user", []string{authz.RoleUser}, nil, http.StatusForbidden},
+		{"admin with narrower scopes", []string{authz.RoleAdmin}, []string{authz.PostsRead}, http.StatusForbidden},
+		{"editor with matching scope", []string{authz.RoleEditor}, []string{"categories:*"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims(tt.roles...)
+			claims.Scopes = tt.scopes
+			req := httptest.NewRequest(http.MethodGet, "/categories", nil)
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, testSecret, claims))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusForbidden && rr.Header().Get("Content-Type") != apierror.ContentType {
+				t.Errorf("Expected problem response, got %q", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/categories", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", rr.Code)
+	}
+}
+
+func TestRejectsNoneAlgorithm(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	if _, err := NewAuth(testSecret).ParseToken(token); err == nil {
+		t.Error("Token with alg none should be rejected")
+	}
+}
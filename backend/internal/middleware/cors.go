@@ -1,22 +1,276 @@
 package middleware
 
 import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
 )
 
-// CORS middleware to handle Cross-Origin Resource Sharing
-func CORS() gin.HandlerFunc {
-	return gin.HandlerFunc(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+// CORSPolicy describes which cross-origin requests are allowed
+type CORSPolicy struct {
+	// AllowedOrigins lists exact origins ("https://app.example.com"),
+	// wildcard subdomains ("https://*.example.com") or "*" for any origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+// DefaultCORSPolicy returns the policy used for the API with the given origins
+func DefaultCORSPolicy(origins []string) CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Cache-Control", "Content-Type", "X-Requested-With"},
+		ExposedHeaders: []string{"Content-Length", RequestIDHeader, "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+// compiledPolicy is a CORSPolicy with pre-computed header values
+type compiledPolicy struct {
+	policy         CORSPolicy
+	anyOrigin      bool
+	exact          map[string]bool
+	wildcards      []wildcardOrigin
+	methods        map[string]bool
+	headers        map[string]bool
+	allowMethods   string
+	allowHeaders   string
+	exposedHeaders string
+	maxAge         string
+}
+
+// wildcardOrigin matches origins like https://*.example.com
+type wildcardOrigin struct {
+	prefix string
+	suffix string
+}
+
+func (w wildcardOrigin) match(origin string) bool {
+	if len(origin) <= len(w.prefix)+len(w.suffix) {
+		return false
+	}
+	if !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+		return false
+	}
+	sub := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+	return !strings.ContainsAny(sub, "/:@")
+}
+
+func compilePolicy(p CORSPolicy) (*compiledPolicy, error) {
+	cp := &compiledPolicy{
+		policy:  p,
+		exact:   make(map[string]bool),
+		methods: make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+
+	for _, origin := range p.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "*":
+			cp.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			scheme, host, ok := strings.Cut(origin, "://")
+			if !ok || !strings.HasPrefix(host, "*.") || strings.Count(origin, "*") != 1 {
+				return nil, fmt.Errorf("invalid wildcard origin %q: use scheme://*.domain", origin)
+			}
+			cp.wildcards = append(cp.wildcards, wildcardOrigin{prefix: scheme + "://", suffix: host[1:]})
+		case origin != "":
+			cp.exact[origin] = true
+		}
+	}
+
+	if cp.anyOrigin && p.AllowCredentials {
+		return nil, fmt.Errorf("CORS policy cannot allow credentials for any origin")
+	}
+
+	methods := make([]string, 0, len(p.AllowedMethods))
+	for _, m := range p.AllowedMethods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m != "" && !cp.methods[m] {
+			cp.methods[m] = true
+			methods = append(methods, m)
+		}
+	}
+	headers := make([]string, 0, len(p.AllowedHeaders))
+	for _, h := range p.AllowedHeaders {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		if h != "" && !cp.headers[h] {
+			cp.headers[h] = true
+			headers = append(headers, h)
+		}
+	}
+	sort.Strings(headers)
+
+	cp.allowMethods = strings.Join(methods, ", ")
+	cp.allowHeaders = strings.Join(headers, ", ")
+	cp.exposedHeaders = strings.Join(p.ExposedHeaders, ", ")
+	if p.MaxAge > 0 {
+		cp.maxAge = strconv.Itoa(int(p.MaxAge.Seconds()))
+	}
+	return cp, nil
+}
+
+func (cp *compiledPolicy) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if cp.anyOrigin || cp.exact[origin] {
+		return true
+	}
+	for _, w := range cp.wildcards {
+		if w.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cp *compiledPolicy) allowsHeaders(requested string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		if h != "" && !cp.headers[h] {
+			return false
+		}
+	}
+	return true
+}
+
+// routePolicy applies a policy to every path under prefix
+type routePolicy struct {
+	prefix string
+	policy *compiledPolicy
+}
+
+// corsState is the immutable set of policies used to serve a request
+type corsState struct {
+	base   *compiledPolicy
+	routes []routePolicy
+}
+
+func (s *corsState) policyFor(path string) *compiledPolicy {
+	for _, r := range s.routes {
+		if strings.HasPrefix(path, r.prefix) {
+			return r.policy
 		}
+	}
+	return s.base
+}
+
+// CORS handles Cross-Origin Resource Sharing from a default policy plus
+// optional per-route overrides. Policies can be replaced at runtime.
+type CORS struct {
+	mu    sync.Mutex
+	state atomic.Pointer[corsState]
+}
+
+// NewCORS creates a CORS middleware from policy
+func NewCORS(policy CORSPolicy) (*CORS, error) {
+	base, err := compilePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	c := &CORS{}
+	c.state.Store(&corsState{base: base})
+	return c, nil
+}
+
+// SetPolicy replaces the default policy; per-route overrides are kept.
+// In-flight requests finish with the policy they started with.
+func (c *CORS) SetPolicy(policy CORSPolicy) error {
+	base, err := compilePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.state.Load()
+	c.state.Store(&corsState{base: base, routes: old.routes})
+	return nil
+}
 
-		c.Next()
+// Override applies policy instead of the default to paths starting with prefix.
+// The longest matching prefix wins.
+func (c *CORS) Override(prefix string, policy CORSPolicy) error {
+	compiled, err := compilePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.state.Load()
+	routes := append([]routePolicy{{prefix: prefix, policy: compiled}}, old.routes...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
 	})
+	c.state.Store(&corsState{base: old.base, routes: routes})
+	return nil
+}
+
+// Handler returns the gin middleware
+func (c *CORS) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		header.Add("Vary", "Origin")
+
+		origin := ctx.GetHeader("Origin")
+		if origin == "" {
+			ctx.Next()
+			return
+		}
+
+		policy := c.state.Load().policyFor(ctx.Request.URL.Path)
+		allowed := policy.allowsOrigin(origin)
+		preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+
+			method := strings.ToUpper(ctx.GetHeader("Access-Control-Request-Method"))
+			if !allowed || !policy.methods[method] || !policy.allowsHeaders(ctx.GetHeader("Access-Control-Request-Headers")) {
+				AbortWithError(ctx, apierror.New(http.StatusForbidden, apierror.CodeCORSRejected, "Cross-origin request is not allowed"))
+				return
+			}
+
+			setAllowOrigin(header, origin, policy)
+			header.Set("Access-Control-Allow-Methods", policy.allowMethods)
+			if policy.allowHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", policy.allowHeaders)
+			}
+			if policy.maxAge != "" {
+				header.Set("Access-Control-Max-Age", policy.maxAge)
+			}
+			ctx.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		// Disallowed origins get no CORS headers, so the browser blocks the response
+		if allowed {
+			setAllowOrigin(header, origin, policy)
+			if policy.exposedHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", policy.exposedHeaders)
+			}
+		}
+		ctx.Next()
+	}
+}
+
+func setAllowOrigin(header http.Header, origin string, policy *compiledPolicy) {
+	header.Set("Access-Control-Allow-Origin", origin)
+	if policy.policy.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
 }
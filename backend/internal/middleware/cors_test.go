@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupCORSRouter(t *testing.T, policy CORSPolicy) (*gin.Engine, *CORS) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cors, err := NewCORS(policy)
+	if err != nil {
+		t.Fatalf("NewCORS() failed: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(cors.Handler())
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	router.GET("/api/v1/ping", ok)
+	router.GET("/public/info", ok)
+	return router, cors
+}
+
+func corsRequest(router *gin.Engine, method, path, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCORSOriginMatching(t *testing.T) {
+	router, _ := setupCORSRouter(t, DefaultCORSPolicy([]string{"http://localhost:3000", "https://*.example.com"}))
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"exact match", "http://localhost:3000", true},
+		{"wildcard subdomain", "https://app.example.com", true},
+		{"nested subdomain", "https://a.b.example.com", true},
+		{"bare domain not matched by wildcard", "https://example.com", false},
+		{"wrong scheme", "http://app.example.com", false},
+		{"suffix attack", "https://app.example.com.evil.io", false},
+		{"unknown origin", "http://evil.io", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := corsRequest(router, http.MethodGet, "/api/v1/ping", tt.origin, nil)
+
+			got := rr.Header().Get("Access-Control-Allow-Origin")
+			if tt.allowed && got != tt.origin {
+				t.Errorf("Expected Allow-Origin '%s', got '%s'", tt.origin, got)
+			}
+			if !tt.allowed && got != "" {
+				t.Errorf("Expected no Allow-Origin, got '%s'", got)
+			}
+			if rr.Header().Get("Vary") != "Origin" {
+				t.Errorf("Expected 'Vary: Origin', got '%s'", rr.Header().Get("Vary"))
+			}
+			if rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+				t.Error("Default policy should not allow credentials")
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	policy := DefaultCORSPolicy([]string{"http://localhost:3000"})
+	policy.MaxAge = time.Hour
+	router, _ := setupCORSRouter(t, policy)
+
+	rr := corsRequest(router, http.MethodOptions, "/api/v1/ping", "http://localhost:3000", map[string]string{
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "authorization, content-type",
+	})
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Errorf("Unexpected Allow-Origin '%s'", got)
+	}
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "3600" {
+		t.Errorf("Expected Max-Age 3600, got '%s'", got)
+	}
+	if rr.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Error("Expected Allow-Methods header")
+	}
+
+	rejected := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+	}{
+		{"disallowed origin", "http://evil.io", "GET", ""},
+		{"disallowed method", "http://localhost:3000", "TRACE", ""},
+		{"disallowed header", "http://localhost:3000", "GET", "X-Secret"},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := corsRequest(router, http.MethodOptions, "/api/v1/ping", tt.origin, map[string]string{
+				"Access-Control-Request-Method":  tt.method,
+				"Access-Control-Request-Headers": tt.headers,
+			})
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+			}
+			if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Error("Rejected preflight should not carry Allow-Origin")
+			}
+		})
+	}
+}
+
+func TestCORSOverride(t *testing.T) {
+	router, cors := setupCORSRouter(t, DefaultCORSPolicy([]string{"http://localhost:3000"}))
+
+	public := DefaultCORSPolicy([]string{"*"})
+	public.ExposedHeaders = []string{"X-Total-Count"}
+	if err := cors.Override("/public", public); err != nil {
+		t.Fatalf("Override() failed: %v", err)
+	}
+
+	rr := corsRequest(router, http.MethodGet, "/public/info", "http://anywhere.io", nil)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://anywhere.io" {
+		t.Errorf("Override should allow any origin, got '%s'", got)
+	}
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "X-Total-Count" {
+		t.Errorf("Expected exposed headers from override, got '%s'", got)
+	}
+
+	rr = corsRequest(router, http.MethodGet, "/api/v1/ping", "http://anywhere.io", nil)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Default policy should still apply outside the override prefix")
+	}
+}
+
+func TestCORSPolicyValidation(t *testing.T) {
+	withCredentials := DefaultCORSPolicy([]string{"*"})
+	withCredentials.AllowCredentials = true
+	if _, err := NewCORS(withCredentials); err == nil {
+		t.Error("Wildcard origin with credentials should be rejected")
+	}
+
+	if _, err := NewCORS(DefaultCORSPolicy([]string{"https://app.*.com"})); err == nil {
+		t.Error("Wildcard outside the subdomain position should be rejected")
+	}
+
+	credentials := DefaultCORSPolicy([]string{"http://localhost:3000"})
+	credentials.AllowCredentials = true
+	router, _ := setupCORSRouter(t, credentials)
+	rr := corsRequest(router, http.MethodGet, "/api/v1/ping", "http://localhost:3000", nil)
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("Expected Allow-Credentials for explicit origins")
+	}
+}
+
+func TestCORSSetPolicy(t *testing.T) {
+	router, cors := setupCORSRouter(t, DefaultCORSPolicy([]string{"http://localhost:3000"}))
+
+	if err := cors.Override("/public", DefaultCORSPolicy([]string{"*"})); err != nil {
+		t.Fatalf("Override() failed: %v", err)
+	}
+	if err := cors.SetPolicy(DefaultCORSPolicy([]string{"https://app.example.com"})); err != nil {
+		t.Fatalf("SetPolicy() failed: %v", err)
+	}
+
+	rr := corsRequest(router, http.MethodGet, "/api/v1/ping", "http://localhost:3000", nil)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Old origin should no longer be allowed")
+	}
+	rr = corsRequest(router, http.MethodGet, "/api/v1/ping", "https://app.example.com", nil)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Error("New origin should be allowed")
+	}
+	rr = corsRequest(router, http.MethodGet, "/public/info", "http://anywhere.io", nil)
+	if rr.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Error("Overrides should survive SetPolicy()")
+	}
+
+	invalid := DefaultCORSPolicy([]string{"*"})
+	invalid.AllowCredentials = true
+	if err := cors.SetPolicy(invalid); err == nil {
+		t.Error("SetPolicy() should reject an invalid policy")
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/validation"
+)
+
+// HandlerFunc is a Gin handler that returns its error instead of writing it
+type HandlerFunc func(c *gin.Context) error
+
+// Handle adapts fn to a gin.HandlerFunc, rendering a returned error as
+// problem+json
+func Handle(fn HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := fn(c); err != nil {
+			AbortWithError(c, err)
+		}
+	}
+}
+
+// AbortWithError writes err as problem+json and stops the handler chain.
+// The error is also recorded on the context so RequestLogger logs its cause.
+func AbortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	apierror.Write(c.Writer, c.Request, GetRequestID(c), err)
+	c.Abort()
+}
+
+// ErrorHandler renders the last error attached with c.Error when the handler
+// chain finished without writing a response
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		apierror.Write(c.Writer, c.Request, GetRequestID(c), c.Errors.Last().Err)
+	}
+}
+
+// BindJSON decodes and validates the JSON body into dst, converting failures
+// into API errors: field errors become a 422 with details, anything else a 400
+func BindJSON(c *gin.Context, dst interface{}) error {
+	err := c.ShouldBindJSON(dst)
+	if err == nil {
+		return nil
+	}
+
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		fields := make([]apierror.FieldError, len(errs))
+		for i, fe := range errs {
+			fields[i] = apierror.FieldError{Field: fe.Field, Code: fe.Rule, Message: fe.Message}
+		}
+		return apierror.Validation(fields...)
+	}
+	if errors.Is(err, io.EOF) {
+		return apierror.BadRequest("Request body is required").Wrap(err)
+	}
+	return apierror.BadRequest("Request body is not valid JSON for this endpoint").Wrap(err)
+}
+
+// Recovery turns panics into a 500 problem response
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		AbortWithError(c, apierror.Internal(fmt.Errorf("panic: %v", recovered)))
+	})
+}
+
+// NotFound responds to requests that match no route
+func NotFound() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		AbortWithError(c, apierror.NotFound(fmt.Sprintf("No route for %s %s", c.Request.Method, c.Request.URL.Path)))
+	}
+}
+
+// MethodNotAllowed responds to requests using a method the route lacks
+func MethodNotAllowed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		AbortWithError(c, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed,
+			fmt.Sprintf("Method %s is not allowed for %s", c.Request.Method, c.Request.URL.Path)))
+	}
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/validation"
+)
+
+func setupErrorRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.Use(RequestID(), Recovery(), ErrorHandler())
+	router.NoRoute(NotFound())
+	router.NoMethod(MethodNotAllowed())
+
+	router.GET("/returned", Handle(func(c *gin.Context) error {
+		return apierror.Conflict("Email already registered")
+	}))
+	router.GET("/attached", func(c *gin.Context) {
+		_ = c.Error(apierror.NotFound("User not found"))
+	})
+	router.GET("/internal", Handle(func(c *gin.Context) error {
+		return errors.New("sql: connection refused")
+	}))
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return router
+}
+
+func TestErrorHandling(t *testing.T) {
+	router := setupErrorRouter()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCode   apierror.Code
+	}{
+		{"returned error", http.MethodGet, "/returned", http.StatusConflict, apierror.CodeConflict},
+		{"attached error", http.MethodGet, "/attached", http.StatusNotFound, apierror.CodeNotFound},
+		{"plain error", http.MethodGet, "/internal", http.StatusInternalServerError, apierror.CodeInternal},
+		{"panic", http.MethodGet, "/panic", http.StatusInternalServerError, apierror.CodeInternal},
+		{"no route", http.MethodGet, "/missing", http.StatusNotFound, apierror.CodeNotFound},
+		{"no method", http.MethodPost, "/returned", http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != apierror.ContentType {
+				t.Errorf("Expected content type %q, got %q", apierror.ContentType, ct)
+			}
+			if strings.Contains(rr.Body.String(), "sql:") {
+				t.Errorf("Internal error details leaked: %s", rr.Body.String())
+			}
+
+			var problem apierror.Problem
+			if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+				t.Fatalf("Could not decode problem: %v", err)
+			}
+			if problem.Code != tt.wantCode || problem.Status != tt.wantStatus {
+				t.Errorf("Expected %s/%d, got %s/%d", tt.wantCode, tt.wantStatus, problem.Code, problem.Status)
+			}
+			if problem.Instance != tt.path {
+				t.Errorf("Expected instance %q, got %q", tt.path, problem.Instance)
+			}
+			if problem.RequestID == "" || problem.RequestID != rr.Header().Get(RequestIDHeader) {
+				t.Errorf("Expected request ID %q, got %q", rr.Header().Get(RequestIDHeader), problem.RequestID)
+			}
+		})
+	}
+}
+
+type bindRequest struct {
+	Name  string `json:"name" validate:"required,min=2"`
+	Color string `json:"color" validate:"omitempty,hexcolor"`
+}
+
+func TestBindJSON(t *testing.T) {
+	binding.Validator = validation.GinValidator{}
+	router := setupErrorRouter()
+	router.POST("/bind", Handle(func(c *gin.Context) error {
+		var req bindRequest
+		if err := BindJSON(c, &req); err != nil {
+			return err
+		}
+		c.JSON(http.StatusCreated, req)
+		return nil
+	}))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantFields int
+	}{
+		{"valid", `{"name":"Go"}`, http.StatusCreated, 0},
+		{"invalid fields", `{"name":"G","color":"red"}`, http.StatusUnprocessableEntity, 2},
+		{"malformed", `{"name":`, http.StatusBadRequest, 0},
+		{"empty", ``, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/bind", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus == http.StatusCreated {
+				return
+			}
+			var problem apierror.Problem
+			if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+				t.Fatalf("Could not decode problem: %v", err)
+			}
+			if len(problem.Errors) != tt.wantFields {
+				t.Errorf("Expected %d field errors, got %v", tt.wantFields, problem.Errors)
+			}
+		})
+	}
+}
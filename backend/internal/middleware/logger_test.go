@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+func setupLoggerRouter(buf *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	router := gin.New()
+	router.Use(RequestID(), RequestLogger(logger))
+	router.GET("/users/:id", NewAuth(testSecret).OptionalAuth(), func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("inside handler")
+		c.String(http.StatusOK, "hello")
+	})
+	return router
+}
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Invalid JSON log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	router := setupLoggerRouter(&buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testSecret, validClaims()))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	id := rr.Header().Get(RequestIDHeader)
+	if len(id) != 32 {
+		t.Fatalf("Expected generated request ID, got %q", id)
+	}
+
+	entries := decodeLogLines(t, &buf)
+	if len(entries) != 2 {
+		t.Fatalf("Expected handler line and request line, got %d", len(entries))
+	}
+	if entries[0]["request_id"] != id {
+		t.Errorf("Handler logger should carry the request ID, got %v", entries[0])
+	}
+
+	entry := entries[1]
+	expected := map[string]interface{}{
+		"msg":        "request",
+		"method":     "GET",
+		"route":      "/users/:id",
+		"path":       "/users/7",
+		"status":     float64(200),
+		"bytes":      float64(5),
+		"user_id":    float64(42),
+		"request_id": id,
+	}
+	for key, want := range expected {
+		if entry[key] != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, entry[key])
+		}
+	}
+	if _, ok := entry["latency_ms"]; !ok {
+		t.Error("Expected latency in log line")
+	}
+	if _, ok := entry["client_ip"]; !ok {
+		t.Error("Expected client_ip in log line")
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	var buf bytes.Buffer
+	router := setupLoggerRouter(&buf)
+
+	tests := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{"well-formed ID is reused", "abc-123", true},
+		{"oversized ID is replaced", strings.Repeat("a", 200), false},
+		{"unsafe ID is replaced", "abc\ninjected", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			req.Header.Set(RequestIDHeader, tt.incoming)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(RequestIDHeader)
+			if tt.reused && got != tt.incoming {
+				t.Errorf("Expected request ID %q, got %q", tt.incoming, got)
+			}
+			if !tt.reused && (got == tt.incoming || got == "") {
+				t.Errorf("Expected a fresh request ID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRequestLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	router := setupLoggerRouter(&buf)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	entries := decodeLogLines(t, &buf)
+	if entries[0]["level"] != "WARN" {
+		t.Errorf("Expected 404 to log at WARN, got %v", entries[0]["level"])
+	}
+	if _, ok := entries[0]["user_id"]; ok {
+		t.Error("Anonymous request should not log a user ID")
+	}
+}
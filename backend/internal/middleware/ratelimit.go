@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/logging"
+)
+
+// DefaultRateLimitGroup is used for groups without a limit of their own
+const DefaultRateLimitGroup = "default"
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next token is available
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// RateLimitStore keeps token buckets. Implementations must be safe for
+// concurrent use; a shared store lets several instances enforce one limit.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit config.RateLimit) (RateLimitResult, error)
+}
+
+// RateLimitsFrom returns the configured limits, or none if rate limiting
+// is disabled
+func RateLimitsFrom(cfg *config.Config) (map[string]config.RateLimit, error) {
+	if !cfg.RateLimitEnabled {
+		return map[string]config.RateLimit{}, nil
+	}
+	return config.ParseRateLimits(cfg.RateLimits)
+}
+
+// RateLimiter enforces per-group limits keyed by user ID or client IP.
+// Limits can be replaced at runtime with SetLimits.
+type RateLimiter struct {
+	store  RateLimitStore
+	limits atomic.Pointer[map[string]config.RateLimit]
+}
+
+// NewRateLimiter creates a limiter backed by store
+func NewRateLimiter(store RateLimitStore, limits map[string]config.RateLimit) *RateLimiter {
+	rl := &RateLimiter{store: store}
+	rl.SetLimits(limits)
+	return rl
+}
+
+// SetLimits atomically replaces the limits applied to new requests
+func (rl *RateLimiter) SetLimits(limits map[string]config.RateLimit) {
+	copied := make(map[string]config.RateLimit, len(limits))
+	for group, l := range limits {
+		copied[group] = l
+	}
+	rl.limits.Store(&copied)
+}
+
+// limitFor returns the limit for group, falling back to the default group
+func (rl *RateLimiter) limitFor(group string) (config.RateLimit, bool) {
+	limits := *rl.limits.Load()
+	if l, ok := limits[group]; ok {
+		return l, true
+	}
+	l, ok := limits[DefaultRateLimitGroup]
+	return l, ok
+}
+
+// Limit rate limits requests in the named route group. Authenticated
+// requests are keyed by user ID, so it should run after the auth middleware
+// when per-user limits are wanted; anonymous requests are keyed by client IP.
+func (rl *RateLimiter) Limit(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, ok := rl.limitFor(group)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		key := group + ":ip:" + c.ClientIP()
+		if claims, ok := GetClaims(c); ok {
+			key = group + ":user:" + strconv.Itoa(claims.UserID)
+		}
+
+		result, err := rl.store.Take(c.Request.Context(), key, limit)
+		if err != nil {
+			// Fail open: an unavailable store should not take the API down
+			logging.FromContext(c.Request.Context()).Warn("rate limit store failed", "group", group, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+			AbortWithError(c, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests, please retry later"))
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// memorySweepInterval is how often idle buckets are dropped
+const memorySweepInterval = time.Minute
+
+// bucket is a token bucket refilled lazily on access
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket will have refilled completely
+	full time.Time
+}
+
+// MemoryStore is an in-process RateLimitStore for a single instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take removes a token from the bucket for key if one is available
+func (s *MemoryStore) Take(_ context.Context, key string, limit config.RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	rate := limit.Rate()
+	burst := float64(limit.Burst)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result := RateLimitResult{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = secondsDuration((burst - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep drops buckets that have refilled completely, since a fresh bucket
+// is equivalent; callers must hold mu
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+)
+
+// fakeClock is a controllable time source for the memory store
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time { return f.t }
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.now
+	return store, clock
+}
+
+func setupRateLimitRouter(rl *RateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	auth := NewAuth(testSecret)
+	router.GET("/public", rl.Limit("api"), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	router.GET("/private", auth.RequireAuth(), rl.Limit("user"), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	router.GET("/other", rl.Limit("other"), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	return router
+}
+
+func rateLimitRequest(router *gin.Engine, path, ip, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	store, clock := newTestStore()
+	limit := config.RateLimit{Requests: 1, Period: time.Second, Burst: 2}
+	ctx := context.Background()
+
+	for i, wantRemaining := range []int{1, 0} {
+		result, _ := store.Take(ctx, "k", limit)
+		if !result.Allowed || result.Remaining != wantRemaining {
+			t.Fatalf("Request %d: expected allowed with %d remaining, got %+v", i+1, wantRemaining, result)
+		}
+	}
+
+	result, _ := store.Take(ctx, "k", limit)
+	if result.Allowed {
+		t.Fatal("Expected the bucket to be empty")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("Expected retry after 1s, got %s", result.RetryAfter)
+	}
+	if result.Reset != 2*time.Second {
+		t.Errorf("Expected reset after 2s, got %s", result.Reset)
+	}
+
+	clock.t = clock.t.Add(time.Second)
+	if result, _ := store.Take(ctx, "k", limit); !result.Allowed {
+		t.Error("Expected a token after one refill period")
+	}
+	if result, _ := store.Take(ctx, "other", limit); !result.Allowed {
+		t.Error("Buckets should be independent per key")
+	}
+
+	clock.t = clock.t.Add(time.Hour)
+	store.Take(ctx, "k", limit)
+	if len(store.buckets) != 1 {
+		t.Errorf("Expected idle buckets to be swept, got %d buckets", len(store.buckets))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	store, _ := newTestStore()
+	rl := NewRateLimiter(store, map[string]config.RateLimit{
+		"api":  {Requests: 1, Period: time.Minute, Burst: 2},
+		"user": {Requests: 1, Period: time.Minute, Burst: 1},
+	})
+	router := setupRateLimitRouter(rl)
+
+	for i := 0; i < 2; i++ {
+		rr := rateLimitRequest(router, "/public", "10.0.0.1", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusOK, rr.Code)
+		}
+		if rr.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Errorf("Expected X-RateLimit-Limit 2, got %q", rr.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+
+	rr := rateLimitRequest(router, "/public", "10.0.0.1", "")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	expected := map[string]string{
+		"Retry-After":           "60",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "120",
+	}
+	for header, want := range expected {
+		if got := rr.Header().Get(header); got != want {
+			t.Errorf("Expected %s %q, got %q", header, want, got)
+		}
+	}
+
+	if rr := rateLimitRequest(router, "/public", "10.0.0.2", ""); rr.Code != http.StatusOK {
+		t.Errorf("Other clients should not share a bucket, got %d", rr.Code)
+	}
+
+	// Authenticated requests are keyed by user, whatever their IP
+	token := signTestToken(t, testSecret, validClaims())
+	if rr := rateLimitRequest(router, "/private", "10.0.0.3", token); rr.Code != http.StatusOK {
+		t.Errorf("Expected first user request to pass, got %d", rr.Code)
+	}
+	if rr := rateLimitRequest(router, "/private", "10.0.0.4", token); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected user limit to follow the user across IPs, got %d", rr.Code)
+	}
+
+	// Groups without a limit and without a default are not limited
+	for i := 0; i < 5; i++ {
+		if rr := rateLimitRequest(router, "/other", "10.0.0.1", ""); rr.Code != http.StatusOK {
+			t.Fatalf("Unlimited group should pass, got %d", rr.Code)
+		}
+	}
+}
+
+func TestRateLimiterSetLimits(t *testing.T) {
+	store, _ := newTestStore()
+	rl := NewRateLimiter(store, nil)
+	router := setupRateLimitRouter(rl)
+
+	if rr := rateLimitRequest(router, "/other", "10.0.0.1", ""); rr.Header().Get("X-RateLimit-Limit") != "" {
+		t.Error("Expected no rate limit headers without limits")
+	}
+
+	rl.SetLimits(map[string]config.RateLimit{DefaultRateLimitGroup: {Requests: 1, Period: time.Minute, Burst: 1}})
+	rateLimitRequest(router, "/other", "10.0.0.1", "")
+	if rr := rateLimitRequest(router, "/other", "10.0.0.1", ""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected default limit to apply after reload, got %d", rr.Code)
+	}
+}
+
+// failingStore simulates an unreachable shared store
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, config.RateLimit) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("connection refused")
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	rl := NewRateLimiter(failingStore{}, map[string]config.RateLimit{"api": {Requests: 1, Period: time.Minute, Burst: 1}})
+	router := setupRateLimitRouter(rl)
+
+	if rr := rateLimitRequest(router, "/public", "10.0.0.1", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected request to pass when the store fails, got %d", rr.Code)
+	}
+}
+
+func TestRateLimitsFrom(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimits = []string{"api=100/m:20"}
+
+	limits, err := RateLimitsFrom(cfg)
+	if err != nil {
+		t.Fatalf("RateLimitsFrom() failed: %v", err)
+	}
+	if want := (config.RateLimit{Requests: 100, Period: time.Minute, Burst: 20}); limits["api"] != want {
+		t.Errorf("Expected %+v, got %+v", want, limits["api"])
+	}
+
+	cfg.RateLimitEnabled = false
+	if limits, _ := RateLimitsFrom(cfg); len(limits) != 0 {
+		t.Errorf("Expected no limits when disabled, got %v", limits)
+	}
+}
@@ -0,0 +1,464 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultTable is the name of the table that records applied migrations
+const DefaultTable = "schema_migrations"
+
+// lockKey identifies the Postgres advisory lock held while migrating, so that
+// replicas starting at the same time do not apply a migration twice
+const lockKey int64 = 4108532093117064
+
+// ErrChecksumMismatch indicates an applied migration file was edited after it ran
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// ErrMissingMigration indicates an applied migration has no file on disk
+var ErrMissingMigration = errors.New("applied migration missing from directory")
+
+// ErrUnknownVersion indicates a goto target that matches no migration file
+var ErrUnknownVersion = errors.New("unknown migration version")
+
+// fileNamePattern matches files such as 000001_create_users.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change loaded from disk
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes a migration and whether it has been applied
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// appliedRecord is a row of the schema table
+type appliedRecord struct {
+	version   int64
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// execer is the part of *sql.DB and *sql.Conn that migrations run on
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Migrator applies migrations from a directory to a database
+type Migrator struct {
+	db *sql.DB
+	// conn runs the statements; it is the connection holding the lock
+	// while up, down, redo or goto are in progress
+	conn       execer
+	advisory   bool
+	table      string
+	migrations []Migration
+	now        func() time.Time
+}
+
+// New creates a migrator for the migrations stored in dir
+func New(db *sql.DB, dir string) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection cannot be nil")
+	}
+
+	migrations, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	_, advisory := db.Driver().(*pq.Driver)
+	return &Migrator{
+		db:         db,
+		conn:       db,
+		advisory:   advisory,
+		table:      DefaultTable,
+		migrations: migrations,
+		now:        time.Now,
+	}, nil
+}
+
+// Load reads and pairs the up/down SQL files in dir, sorted by version
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			m.Checksum = checksum(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies all pending migrations and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.locked(ctx, func(m *Migrator) error {
+		applied, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, mig); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down rolls back the n most recently applied migrations
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("number of migrations to roll back must be positive")
+	}
+
+	count := 0
+	err := m.locked(ctx, func(m *Migrator) error {
+		applied, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < n; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.rollback(ctx, mig); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Redo rolls back the latest applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.locked(ctx, func(m *Migrator) error {
+		applied, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.rollback(ctx, mig); err != nil {
+				return err
+			}
+			return m.apply(ctx, mig)
+		}
+		return fmt.Errorf("no applied migrations to redo")
+	})
+}
+
+// Goto migrates up or down until version is the latest applied migration.
+// Version 0 rolls back every migration.
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.locked(ctx, func(m *Migrator) error {
+		applied, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok || mig.Version <= version {
+				continue
+			}
+			if err := m.rollback(ctx, mig); err != nil {
+				return err
+			}
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+			if err := m.apply(ctx, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status reports every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if rec, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = rec.appliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// locked runs fn while holding the migration lock. On Postgres that is a
+// session advisory lock, so fn gets a copy of the migrator bound to the
+// connection holding it; SQLite serialises writers itself.
+func (m *Migrator) locked(ctx context.Context, fn func(m *Migrator) error) error {
+	if !m.advisory {
+		return fn(m)
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			// Drop the connection rather than return it to the pool still locked
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	bound := *m
+	bound.conn = conn
+	return fn(&bound)
+}
+
+// prepare ensures the schema table exists and verifies applied migrations
+// against the files on disk
+func (m *Migrator) prepare(ctx context.Context) (map[int64]appliedRecord, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for version, rec := range applied {
+		mig := m.find(version)
+		if mig == nil {
+			return nil, fmt.Errorf("%w: %d_%s", ErrMissingMigration, version, rec.name)
+		}
+		if mig.Checksum != rec.checksum {
+			return nil, fmt.Errorf("%w: %d_%s was modified after it was applied (recorded %s, found %s)",
+				ErrChecksumMismatch, version, mig.Name, rec.checksum, mig.Checksum)
+		}
+	}
+	return applied, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`, m.table)
+	if _, err := m.conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create %s table: %v", m.table, err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]appliedRecord, error) {
+	rows, err := m.conn.QueryContext(ctx, fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s", m.table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedRecord)
+	for rows.Next() {
+		var rec appliedRecord
+		if err := rows.Scan(&rec.version, &rec.name, &rec.checksum, &rec.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %v", err)
+		}
+		applied[rec.version] = rec
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %v", mig.Version, mig.Name, err)
+		}
+		insert := fmt.Sprintf("INSERT INTO %s (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)", m.table)
+		if _, err := tx.ExecContext(ctx, insert, mig.Version, mig.Name, mig.Checksum, m.now().UTC()); err != nil {
+			return fmt.Errorf("failed to record migration %d_%s: %v", mig.Version, mig.Name, err)
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) rollback(ctx context.Context, mig Migration) error {
+	if strings.TrimSpace(mig.Down) == "" {
+		return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+	}
+
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+			return fmt.Errorf("failed to roll back migration %d_%s: %v", mig.Version, mig.Name, err)
+		}
+		remove := fmt.Sprintf("DELETE FROM %s WHERE version = $1", m.table)
+		if _, err := tx.ExecContext(ctx, remove, mig.Version); err != nil {
+			return fmt.Errorf("failed to unrecord migration %d_%s: %v", mig.Version, mig.Name, err)
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// Create writes an empty up/down migration pair numbered after the latest one in dir
+func Create(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migration name cannot be empty")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", fmt.Errorf("failed to create migrations directory: %v", err)
+	}
+
+	migrations, err := Load(dir)
+	if err != nil {
+		return "", "", err
+	}
+	var next int64 = 1
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := fmt.Sprintf("%06d_%s", next, name)
+	upPath := filepath.Join(dir, base+".up.sql")
+	downPath := filepath.Join(dir, base+".down.sql")
+
+	if err := os.WriteFile(upPath, []byte("-- "+base+" up\n"), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %v", upPath, err)
+	}
+	if err := os.WriteFile(downPath, []byte("-- "+base+" down\n"), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %v", downPath, err)
+	}
+	return upPath, downPath, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,222 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func setupTestMigrator(t *testing.T) (*Migrator, *sql.DB, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeMigration(t, dir, "000001_create_users",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);",
+		"DROP TABLE users;")
+	writeMigration(t, dir, "000002_create_posts",
+		"CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL);",
+		"DROP TABLE posts;")
+	writeMigration(t, dir, "000003_add_posts_title",
+		"ALTER TABLE posts ADD COLUMN title TEXT;",
+		"ALTER TABLE posts DROP COLUMN title;")
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return m, db, dir
+}
+
+func writeMigration(t *testing.T, dir, base, up, down string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, base+".up.sql"), []byte(up), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, base+".down.sql"), []byte(down), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appliedVersions(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+	var versions []int64
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	m, db, _ := setupTestMigrator(t)
+
+	n, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 migrations applied, got %d", n)
+	}
+	if _, err := db.Exec("INSERT INTO posts (user_id, title) VALUES (1, 'hello')"); err != nil {
+		t.Errorf("Posts table not migrated properly: %v", err)
+	}
+
+	// Running again should be a no-op
+	n, err = m.Up(ctx)
+	if err != nil || n != 0 {
+		t.Errorf("Expected second Up() to apply nothing, got %d, %v", n, err)
+	}
+
+	n, err = m.Down(ctx, 2)
+	if err != nil {
+		t.Fatalf("Down() failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 migrations rolled back, got %d", n)
+	}
+	if got := appliedVersions(t, m); len(got) != 1 || got[0] != 1 {
+		t.Errorf("Expected only version 1 applied, got %v", got)
+	}
+	if _, err := db.Exec("SELECT COUNT(*) FROM posts"); err == nil {
+		t.Error("Posts table should have been dropped")
+	}
+}
+
+func TestGotoAndRedo(t *testing.T) {
+	ctx := context.Background()
+	m, _, _ := setupTestMigrator(t)
+
+	if err := m.Goto(ctx, 2); err != nil {
+		t.Fatalf("Goto(2) failed: %v", err)
+	}
+	if got := appliedVersions(t, m); len(got) != 2 {
+		t.Errorf("Expected versions 1 and 2 applied, got %v", got)
+	}
+
+	if err := m.Redo(ctx); err != nil {
+		t.Fatalf("Redo() failed: %v", err)
+	}
+	if got := appliedVersions(t, m); len(got) != 2 {
+		t.Errorf("Redo() should keep the same versions applied, got %v", got)
+	}
+
+	if err := m.Goto(ctx, 0); err != nil {
+		t.Fatalf("Goto(0) failed: %v", err)
+	}
+	if got := appliedVersions(t, m); len(got) != 0 {
+		t.Errorf("Expected no versions applied, got %v", got)
+	}
+
+	if err := m.Goto(ctx, 42); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	m, db, dir := setupTestMigrator(t)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() failed: %v", err)
+	}
+
+	// Edit an already applied migration
+	writeMigration(t, dir, "000001_create_users",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL, name TEXT);",
+		"DROP TABLE users;")
+
+	edited, err := New(db, dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if _, err := edited.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := edited.Status(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected Status() to report ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestMissingMigration(t *testing.T) {
+	ctx := context.Background()
+	m, db, dir := setupTestMigrator(t)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() failed: %v", err)
+	}
+	os.Remove(filepath.Join(dir, "000003_add_posts_title.up.sql"))
+	os.Remove(filepath.Join(dir, "000003_add_posts_title.down.sql"))
+
+	trimmed, err := New(db, dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if _, err := trimmed.Up(ctx); !errors.Is(err, ErrMissingMigration) {
+		t.Errorf("Expected ErrMissingMigration, got %v", err)
+	}
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	_, db, dir := setupTestMigrator(t)
+	writeMigration(t, dir, "000004_broken", "CREATE TABLE broken (;", "DROP TABLE broken;")
+
+	m, err := New(db, dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	n, err := m.Up(ctx)
+	if err == nil {
+		t.Fatal("Expected Up() to fail on invalid SQL")
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 migrations applied before failure, got %d", n)
+	}
+	if got := appliedVersions(t, m); len(got) != 3 {
+		t.Errorf("Broken migration should not be recorded, got %v", got)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+
+	up, down, err := Create(dir, "Create Users")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if filepath.Base(up) != "000001_create_users.up.sql" {
+		t.Errorf("Unexpected up file name %s", up)
+	}
+	if filepath.Base(down) != "000001_create_users.down.sql" {
+		t.Errorf("Unexpected down file name %s", down)
+	}
+
+	up, _, err = Create(dir, "add_posts")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if filepath.Base(up) != "000002_add_posts.up.sql" {
+		t.Errorf("Expected next version to be 2, got %s", up)
+	}
+
+	if _, _, err := Create(dir, "  "); err == nil {
+		t.Error("Create() with empty name should fail")
+	}
+}
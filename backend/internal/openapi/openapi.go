@@ -0,0 +1,269 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/apierror"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.1.0"
+
+// BearerAuth is the name of the JWT security scheme
+const BearerAuth = "bearerAuth"
+
+// Operation describes one route. Request and response bodies are given as
+// Go values (usually zero values of the body type) and converted to schemas.
+type Operation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tags        []string
+	// Secured marks routes that require a Bearer token
+	Secured bool
+	// Query lists query parameters by name
+	Query []Parameter
+	// Request is the JSON request body, nil for none
+	Request interface{}
+	// Responses maps status codes to JSON bodies; nil bodies have no content
+	Responses map[int]interface{}
+	// Errors lists status codes answered with problem+json
+	Errors []int
+	// ContentType overrides the success response media type
+	ContentType string
+}
+
+// Parameter is a query or path parameter
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Schema      Schema `json:"schema"`
+}
+
+// Spec collects operations and renders them as an OpenAPI document
+type Spec struct {
+	mu          sync.RWMutex
+	title       string
+	version     string
+	description string
+	operations  map[string]Operation
+	// ignored routes are served but deliberately left out of the document
+	ignored map[string]bool
+}
+
+// New creates an empty spec
+func New(title, version, description string) *Spec {
+	return &Spec{
+		title:       title,
+		version:     version,
+		description: description,
+		operations:  make(map[string]Operation),
+		ignored:     make(map[string]bool),
+	}
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// Add registers an operation; Path uses Gin syntax (/users/:id)
+func (s *Spec) Add(op Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations[routeKey(op.Method, op.Path)] = op
+}
+
+// Ignore excludes a route from the document and from Check
+func (s *Spec) Ignore(method, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignored[routeKey(method, path)] = true
+}
+
+// Check reports routes registered on the router without a spec entry and
+// spec entries that no longer match a route
+func (s *Spec) Check(routes gin.RoutesInfo) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	registered := make(map[string]bool, len(routes))
+	var problems []string
+	for _, r := range routes {
+		key := routeKey(r.Method, r.Path)
+		registered[key] = true
+		if _, ok := s.operations[key]; !ok && !s.ignored[key] {
+			problems = append(problems, "route without spec entry: "+key)
+		}
+	}
+	for key := range s.operations {
+		if !registered[key] {
+			problems = append(problems, "spec entry without route: "+key)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("openapi spec out of sync with router:\n%s", strings.Join(problems, "\n"))
+}
+
+// Document builds the OpenAPI document
+func (s *Spec) Document() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gen := newGenerator()
+	problemRef := gen.schemaFor(apierror.Problem{})
+
+	keys := make([]string, 0, len(s.operations))
+	for key := range s.operations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	paths := map[string]map[string]interface{}{}
+	for _, key := range keys {
+		op := s.operations[key]
+		path, params := convertPath(op.Path)
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(op.Method)] = s.operation(gen, op, params, problemRef)
+	}
+
+	info := map[string]interface{}{
+		"title":   s.title,
+		"version": s.version,
+	}
+	if s.description != "" {
+		info["description"] = s.description
+	}
+
+	return map[string]interface{}{
+		"openapi": Version,
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": gen.schemas,
+			"securitySchemes": map[string]interface{}{
+				BearerAuth: map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+	}
+}
+
+func (s *Spec) operation(gen *generator, op Operation, params []Parameter, problemRef Schema) map[string]interface{} {
+	out := map[string]interface{}{
+		"operationId": operationID(op),
+	}
+	if op.Summary != "" {
+		out["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		out["tags"] = op.Tags
+	}
+	if op.Secured {
+		out["security"] = []map[string][]string{{BearerAuth: {}}}
+	}
+
+	params = append(params, op.Query...)
+	for i := range params {
+		if params[i].In == "" {
+			params[i].In = "query"
+		}
+		if params[i].Schema == nil {
+			params[i].Schema = Schema{"type": "string"}
+		}
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	if op.Request != nil {
+		out["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": gen.schemaFor(op.Request)},
+			},
+		}
+	}
+
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	responses := map[string]interface{}{}
+	for status, body := range op.Responses {
+		response := map[string]interface{}{"description": http.StatusText(status)}
+		if body != nil {
+			response["content"] = map[string]interface{}{
+				contentType: map[string]interface{}{"schema": gen.schemaFor(body)},
+			}
+		}
+		responses[strconv.Itoa(status)] = response
+	}
+	errorStatuses := append([]int(nil), op.Errors...)
+	if op.Secured {
+		errorStatuses = append(errorStatuses, http.StatusUnauthorized)
+	}
+	for _, status := range errorStatuses {
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				apierror.ContentType: map[string]interface{}{"schema": problemRef},
+			},
+		}
+	}
+	out["responses"] = responses
+	return out
+}
+
+// convertPath turns /users/:id into /users/{id} and returns its parameters
+func convertPath(path string) (string, []Parameter) {
+	var params []Parameter
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			name := seg[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: Schema{"type": "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives a stable identifier such as getApiV1UsersId
+func operationID(op Operation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '*' || r == '-' || r == '_' || r == '.'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// Handler serves the document as JSON
+func (s *Spec) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Document())
+	}
+}
@@ -0,0 +1,184 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testMessage struct {
+	ID        int            `json:"id"`
+	Username  string         `json:"username" validate:"required"`
+	Content   string         `json:"content,omitempty"`
+	Tags      []string       `json:"tags,omitempty"`
+	Meta      map[string]int `json:"meta,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+	Reply     *testMessage   `json:"reply,omitempty"`
+	Extra     interface{}    `json:"extra,omitempty"`
+	Hidden    string         `json:"-"`
+	internal  string
+	Nested    struct{ N int }   `json:"nested"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+type testEnvelope struct {
+	testPaging
+	Data []testMessage `json:"data"`
+}
+
+type testPaging struct {
+	Page int `json:"page"`
+}
+
+func TestSchemaGeneration(t *testing.T) {
+	gen := newGenerator()
+	ref := gen.schemaFor(testEnvelope{})
+	if ref["$ref"] != "#/components/schemas/testEnvelope" {
+		t.Fatalf("Expected a component reference, got %v", ref)
+	}
+
+	envelope := gen.schemas["testEnvelope"]
+	props := envelope["properties"].(map[string]interface{})
+	if _, ok := props["page"]; !ok {
+		t.Error("Embedded struct fields should be flattened")
+	}
+
+	msg := gen.schemas["testMessage"]
+	props = msg["properties"].(map[string]interface{})
+	expected := map[string]Schema{
+		"id":        {"type": "integer"},
+		"timestamp": {"type": "string", "format": "date-time"},
+		"tags":      {"type": "array", "items": Schema{"type": "string"}},
+		"meta":      {"type": "object", "additionalProperties": Schema{"type": "integer"}},
+		"reply":     {"$ref": "#/components/schemas/testMessage"},
+		"extra":     {},
+	}
+	for name, want := range expected {
+		if got := props[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Property %s: expected %v, got %v", name, want, got)
+		}
+	}
+	for _, name := range []string{"Hidden", "internal", "-"} {
+		if _, ok := props[name]; ok {
+			t.Errorf("Property %q should be skipped", name)
+		}
+	}
+
+	required := msg["required"].([]string)
+	want := []string{"id", "username", "timestamp", "nested"}
+	if !reflect.DeepEqual(required, want) {
+		t.Errorf("Expected required %v, got %v", want, required)
+	}
+}
+
+func newTestSpec() *Spec {
+	spec := New("Test API", "1.0.0", "")
+	spec.Add(Operation{
+		Method:    http.MethodGet,
+		Path:      "/messages/:id",
+		Summary:   "Get a message",
+		Responses: map[int]interface{}{http.StatusOK: testMessage{}},
+		Errors:    []int{http.StatusNotFound},
+	})
+	spec.Add(Operation{
+		Method:    http.MethodPost,
+		Path:      "/messages",
+		Secured:   true,
+		Request:   testMessage{},
+		Responses: map[int]interface{}{http.StatusCreated: testMessage{}},
+	})
+	spec.Ignore(http.MethodGet, "/openapi.json")
+	return spec
+}
+
+func TestCheck(t *testing.T) {
+	spec := newTestSpec()
+	routes := gin.RoutesInfo{
+		{Method: http.MethodGet, Path: "/messages/:id"},
+		{Method: http.MethodPost, Path: "/messages"},
+		{Method: http.MethodGet, Path: "/openapi.json"},
+	}
+	if err := spec.Check(routes); err != nil {
+		t.Errorf("Expected spec to match routes, got %v", err)
+	}
+
+	routes = append(routes[1:], gin.RouteInfo{Method: http.MethodDelete, Path: "/messages/:id"})
+	err := spec.Check(routes)
+	if err == nil {
+		t.Fatal("Expected mismatched routes to be reported")
+	}
+	for _, want := range []string{"route without spec entry: DELETE /messages/:id", "spec entry without route: GET /messages/:id"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	data, err := json.Marshal(newTestSpec().Document())
+	if err != nil {
+		t.Fatalf("Document is not serializable: %v", err)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string                    `json:"operationId"`
+			Parameters  []Parameter               `json:"parameters"`
+			Security    []map[string][]string     `json:"security"`
+			Responses   map[string]map[string]any `json:"responses"`
+			RequestBody map[string]any            `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Could not decode document: %v", err)
+	}
+
+	get, ok := doc.Paths["/messages/{id}"]["get"]
+	if !ok {
+		t.Fatalf("Expected Gin path parameters to be converted, got paths %v", doc.Paths)
+	}
+	if get.OperationID != "getMessagesId" {
+		t.Errorf("Expected operationId getMessagesId, got %q", get.OperationID)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" {
+		t.Errorf("Expected id path parameter, got %v", get.Parameters)
+	}
+	if _, ok := get.Responses["404"]["content"].(map[string]any)["application/problem+json"]; !ok {
+		t.Errorf("Expected 404 to be a problem response, got %v", get.Responses["404"])
+	}
+
+	post := doc.Paths["/messages"]["post"]
+	if len(post.Security) != 1 {
+		t.Error("Expected secured operation to require bearer auth")
+	}
+	if _, ok := post.Responses["401"]; !ok {
+		t.Error("Expected secured operation to document 401")
+	}
+	for _, name := range []string{"testMessage", "Problem"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Expected component schema %s", name)
+		}
+	}
+}
+
+func TestDocumentLeavesOperationErrors(t *testing.T) {
+	errs := make([]int, 1, 4)
+	errs[0] = http.StatusNotFound
+	spec := New("Test API", "1.0.0", "")
+	spec.Add(Operation{Method: http.MethodGet, Path: "/a", Secured: true, Errors: errs})
+	spec.Add(Operation{Method: http.MethodGet, Path: "/b", Errors: errs})
+
+	spec.Document()
+	if extra := errs[:2][1]; extra != 0 {
+		t.Errorf("Document() must not write into the caller's Errors slice, found %d", extra)
+	}
+}
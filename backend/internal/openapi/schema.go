@@ -0,0 +1,166 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema object as used by OpenAPI 3.1
+type Schema map[string]interface{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// generator converts Go types to schemas, collecting named structs under
+// components/schemas
+type generator struct {
+	schemas map[string]Schema
+	names   map[reflect.Type]string
+}
+
+func newGenerator() *generator {
+	return &generator{
+		schemas: make(map[string]Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schemaFor returns the schema for the type of v
+func (g *generator) schemaFor(v interface{}) Schema {
+	if v == nil {
+		return Schema{}
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *generator) schema(t reflect.Type) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(time.Duration(0)):
+		return Schema{"type": "integer", "description": "duration in nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.ref(t)
+	default:
+		// interface{} and anything else accept any JSON value
+		return Schema{}
+	}
+}
+
+// ref registers a named struct once and returns a reference to it
+func (g *generator) ref(t reflect.Type) Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = g.uniqueName(t)
+		g.names[t] = name
+		// Reserve the name before recursing so self-references terminate
+		g.schemas[name] = Schema{}
+		g.schemas[name] = g.object(t)
+	}
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+// uniqueName qualifies the type name with its package when two packages
+// declare types with the same name
+func (g *generator) uniqueName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	qualified := pkg + "." + name
+	for i := 2; ; i++ {
+		if _, taken := g.schemas[qualified]; !taken {
+			return qualified
+		}
+		qualified = pkg + "." + name + strconv.Itoa(i)
+	}
+}
+
+// object builds an object schema from exported fields and their json tags.
+// Fields are required unless tagged omitempty, and validate:"required"
+// always makes them required.
+func (g *generator) object(t reflect.Type) Schema {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a json name are flattened like encoding/json
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := g.object(ft)
+				for k, v := range embedded["properties"].(map[string]interface{}) {
+					properties[k] = v
+				}
+				if req, ok := embedded["required"].([]string); ok {
+					required = append(required, req...)
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+
+		omitempty := strings.Contains(opts, "omitempty")
+		validateRequired := strings.Contains(","+f.Tag.Get("validate")+",", ",required,")
+		if validateRequired || (!omitempty && f.Type.Kind() != reflect.Pointer) {
+			required = append(required, name)
+		}
+	}
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,33 @@
+// Package version exposes build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X github.com/timur-harin/sum25-go-flutter-course/backend/internal/version.Version=v1.2.0 \
+//	  -X github.com/timur-harin/sum25-go-flutter-course/backend/internal/version.Commit=$(git rev-parse --short HEAD)"
+package version
+
+import "runtime/debug"
+
+// Version is the release version of the build
+var Version = "dev"
+
+// Commit is the VCS revision the build was made from
+var Commit = "unknown"
+
+// BuildTime is the UTC time the binary was built, in RFC 3339 format
+var BuildTime = ""
+
+// init falls back to the VCS stamp recorded by the Go toolchain when the
+// commit was not injected through ldflags
+func init() {
+	if Commit != "unknown" {
+		return
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && s.Value != "" {
+			Commit = s.Value
+		}
+	}
+}
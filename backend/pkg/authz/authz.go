@@ -0,0 +1,182 @@
+// Package authz maps roles to permissions and checks them for a subject.
+//
+// Permissions are "resource:action" strings such as posts:write. A policy
+// grants permissions to roles; a grant may end in ":*" to cover every
+// action of a resource, and "*" covers everything. Tokens may also carry
+// scopes, which narrow what the roles allow but never widen it.
+//
+// Ownership checks use the resource's "admin" action: a user may modify a
+// resource they own with resource:write, and anyone's with resource:admin.
+//
+// Like pkg/validation, the package only uses the standard library so the Gin
+// backend and the lab modules share one policy implementation.
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Permissions of the default policy
+const (
+	PostsRead       = "posts:read"
+	PostsWrite      = "posts:write"
+	PostsAdmin      = "posts:admin"
+	CategoriesRead  = "categories:read"
+	CategoriesWrite = "categories:write"
+	CategoriesAdmin = "categories:admin"
+	UsersAdmin      = "users:admin"
+)
+
+// Roles of the default policy
+const (
+	RoleUser   = "user"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// ErrForbidden is matched by every denial, see Error
+var ErrForbidden = errors.New("forbidden")
+
+// Error explains why a check failed; errors.Is(err, ErrForbidden) is true
+type Error struct {
+	Permission string
+	Reason     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("forbidden: %s (%s)", e.Permission, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrForbidden) match
+func (e *Error) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Subject is whoever is being authorized
+type Subject struct {
+	UserID int
+	Roles  []string
+	// Scopes restrict the subject to matching permissions; empty means no
+	// restriction beyond the roles
+	Scopes []string
+}
+
+// Policy grants permissions to roles
+type Policy struct {
+	roles map[string][]string
+}
+
+// NewPolicy creates a policy from role names to granted permissions
+func NewPolicy(roles map[string][]string) *Policy {
+	p := &Policy{roles: make(map[string][]string, len(roles))}
+	for role, grants := range roles {
+		p.roles[role] = append([]string(nil), grants...)
+	}
+	return p
+}
+
+// DefaultPolicy returns the policy used by the course services: users write
+// their own posts, editors also moderate posts and manage categories, and
+// admins may do anything
+func DefaultPolicy() *Policy {
+	return NewPolicy(map[string][]string{
+		RoleUser:   {PostsRead, PostsWrite, CategoriesRead},
+		RoleEditor: {PostsRead, PostsWrite, PostsAdmin, CategoriesRead, CategoriesWrite},
+		RoleAdmin:  {"*"},
+	})
+}
+
+// HasRole reports whether the policy defines role
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+// Roles returns the defined role names in sorted order
+func (p *Policy) Roles() []string {
+	roles := make([]string, 0, len(p.roles))
+	for role := range p.roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// Grants returns the grants of all the given roles, sorted and deduplicated
+func (p *Policy) Grants(roles []string) []string {
+	seen := make(map[string]bool)
+	var grants []string
+	for _, role := range roles {
+		for _, grant := range p.roles[role] {
+			if !seen[grant] {
+				seen[grant] = true
+				grants = append(grants, grant)
+			}
+		}
+	}
+	sort.Strings(grants)
+	return grants
+}
+
+// Can reports whether the subject holds permission
+func (p *Policy) Can(s Subject, permission string) bool {
+	return p.Authorize(s, permission) == nil
+}
+
+// Authorize returns an *Error unless the subject holds permission
+func (p *Policy) Authorize(s Subject, permission string) error {
+	granted := false
+	for _, role := range s.Roles {
+		if matchesAny(p.roles[role], permission) {
+			granted = true
+			break
+		}
+	}
+	if !granted {
+		return &Error{Permission: permission, Reason: "no role grants it"}
+	}
+	if len(s.Scopes) > 0 && !matchesAny(s.Scopes, permission) {
+		return &Error{Permission: permission, Reason: "outside the token's scopes"}
+	}
+	return nil
+}
+
+// AuthorizeOwner checks permission on a resource owned by ownerID. Owners
+// need permission itself; everyone else needs the resource's admin action,
+// e.g. posts:admin for posts:write.
+func (p *Policy) AuthorizeOwner(s Subject, permission string, ownerID int) error {
+	if err := p.Authorize(s, permission); err != nil {
+		return err
+	}
+	if s.UserID != 0 && s.UserID == ownerID {
+		return nil
+	}
+
+	resource, _, _ := strings.Cut(permission, ":")
+	if p.Authorize(s, resource+":admin") != nil {
+		return &Error{Permission: permission, Reason: "not the owner"}
+	}
+	return nil
+}
+
+func matchesAny(grants []string, permission string) bool {
+	for _, grant := range grants {
+		if matches(grant, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(grant, permission string) bool {
+	switch {
+	case grant == "*" || grant == permission:
+		return true
+	case strings.HasSuffix(grant, ":*"):
+		return strings.HasPrefix(permission, strings.TrimSuffix(grant, "*"))
+	}
+	return false
+}
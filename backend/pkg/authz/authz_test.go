@@ -0,0 +1,90 @@
+package authz
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	policy := NewPolicy(map[string][]string{
+		"user":      {PostsRead, PostsWrite},
+		"moderator": {"posts:*"},
+		"admin":     {"*"},
+	})
+
+	tests := []struct {
+		name       string
+		subject    Subject
+		permission string
+		allowed    bool
+	}{
+		{"granted", Subject{Roles: []string{"user"}}, PostsWrite, true},
+		{"not granted", Subject{Roles: []string{"user"}}, CategoriesAdmin, false},
+		{"no roles", Subject{}, PostsRead, false},
+		{"unknown role", Subject{Roles: []string{"owner"}}, PostsRead, false},
+		{"resource wildcard", Subject{Roles: []string{"moderator"}}, PostsAdmin, true},
+		{"resource wildcard is scoped", Subject{Roles: []string{"moderator"}}, CategoriesRead, false},
+		{"global wildcard", Subject{Roles: []string{"admin"}}, UsersAdmin, true},
+		{"any role suffices", Subject{Roles: []string{"owner", "user"}}, PostsRead, true},
+		{"scope allows", Subject{Roles: []string{"admin"}, Scopes: []string{"posts:read"}}, PostsRead, true},
+		{"scope restricts", Subject{Roles: []string{"admin"}, Scopes: []string{"posts:read"}}, PostsWrite, false},
+		{"scope cannot widen", Subject{Roles: []string{"user"}, Scopes: []string{"*"}}, UsersAdmin, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Authorize(tt.subject, tt.permission)
+			if (err == nil) != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %v", tt.allowed, err)
+			}
+			if err != nil && !errors.Is(err, ErrForbidden) {
+				t.Errorf("Expected ErrForbidden, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeOwner(t *testing.T) {
+	policy := DefaultPolicy()
+
+	tests := []struct {
+		name    string
+		subject Subject
+		ownerID int
+		allowed bool
+	}{
+		{"owner", Subject{UserID: 1, Roles: []string{RoleUser}}, 1, true},
+		{"other user", Subject{UserID: 2, Roles: []string{RoleUser}}, 1, false},
+		{"editor moderates", Subject{UserID: 2, Roles: []string{RoleEditor}}, 1, true},
+		{"admin", Subject{UserID: 3, Roles: []string{RoleAdmin}}, 1, true},
+		{"owner without permission", Subject{UserID: 1}, 1, false},
+		{"anonymous never owns", Subject{Roles: []string{RoleUser}}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.AuthorizeOwner(tt.subject, PostsWrite, tt.ownerID)
+			if (err == nil) != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %v", tt.allowed, err)
+			}
+		})
+	}
+}
+
+func TestPolicyRolesAndGrants(t *testing.T) {
+	policy := DefaultPolicy()
+
+	if !reflect.DeepEqual(policy.Roles(), []string{RoleAdmin, RoleEditor, RoleUser}) {
+		t.Errorf("Unexpected roles %v", policy.Roles())
+	}
+	if policy.HasRole("owner") {
+		t.Error("Expected owner to be undefined")
+	}
+
+	grants := policy.Grants([]string{RoleUser, RoleEditor})
+	expected := []string{CategoriesRead, CategoriesWrite, PostsAdmin, PostsRead, PostsWrite}
+	if !reflect.DeepEqual(grants, expected) {
+		t.Errorf("Expected %v, got %v", expected, grants)
+	}
+}
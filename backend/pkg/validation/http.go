@@ -0,0 +1,89 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// DecodeError reports a request body that is not valid JSON for the target
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "invalid request body: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeJSON decodes the JSON request body into dst and validates it. It is
+// meant for net/http and gorilla/mux handlers; a malformed body yields a
+// *DecodeError and failed rules yield Errors.
+func DecodeJSON(r *http.Request, dst interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("body is empty")
+		}
+		return &DecodeError{Err: err}
+	}
+	return Struct(dst)
+}
+
+// GinValidator satisfies gin's binding.StructValidator, so installing it with
+//
+//	binding.Validator = validation.GinValidator{}
+//
+// makes ShouldBind and friends enforce validate tags
+type GinValidator struct{}
+
+// ValidateStruct validates structs, pointers to structs and slices of them;
+// other values are accepted unchanged, matching gin's default validator
+func (GinValidator) ValidateStruct(obj interface{}) error {
+	if obj == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return Struct(v.Interface())
+	case reflect.Slice, reflect.Array:
+		var all Errors
+		for i := 0; i < v.Len(); i++ {
+			err := GinValidator{}.ValidateStruct(v.Index(i).Interface())
+			var errs Errors
+			switch {
+			case errors.As(err, &errs):
+				for _, fe := range errs {
+					fe.Field = fmt.Sprintf("[%d].%s", i, fe.Field)
+					fe.Message = fmt.Sprintf("[%d].%s", i, fe.Message)
+					all = append(all, fe)
+				}
+			case err != nil:
+				return err
+			}
+		}
+		if len(all) > 0 {
+			return all
+		}
+	}
+	return nil
+}
+
+// Engine returns nil; there is no underlying validator library
+func (GinValidator) Engine() interface{} {
+	return nil
+}
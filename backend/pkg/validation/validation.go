@@ -0,0 +1,346 @@
+// Package validation enforces `validate` struct tags.
+//
+// Supported rules: required, omitempty, min=N, max=N, len=N, email, hexcolor,
+// oneof=a b c and dive, which applies the rules after it to every element of
+// a slice, array or map. Nested structs are validated recursively. All
+// failing fields are reported at once as Errors.
+//
+// The package only depends on the standard library so the lab modules can
+// use it as well as the Gin backend.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError describes a single failed rule
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. items[0].name
+	Field string
+	// Rule is the failed rule, e.g. min
+	Rule string
+	// Param is the rule parameter, e.g. 2 for min=2
+	Param   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// Errors lists every field that failed validation
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// rule is one parsed entry of a validate tag
+type rule struct {
+	name  string
+	param string
+}
+
+// fieldRules are the rules of one struct field; elem holds rules after dive
+type fieldRules struct {
+	index     int
+	name      string
+	rules     []rule
+	omitempty bool
+	dive      *fieldRules
+}
+
+var (
+	typeCache sync.Map // reflect.Type -> []fieldRules
+	hexColor  = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+)
+
+// Struct validates v, which must be a struct or a pointer to one. It returns
+// Errors when fields fail validation and another error when a tag is invalid.
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return errors.New("validation: nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: expected a struct, got %s", rv.Kind())
+	}
+
+	var errs Errors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *Errors) error {
+	fields, err := rulesFor(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := validateValue(v.Field(f.index), joinPath(prefix, f.name), &f, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(v reflect.Value, path string, f *fieldRules, errs *Errors) error {
+	if f.omitempty && isEmpty(v) {
+		return nil
+	}
+
+	for _, r := range f.rules {
+		fe, err := check(v, path, r)
+		if err != nil {
+			return err
+		}
+		if fe != nil {
+			*errs = append(*errs, *fe)
+			// Later rules rarely add information once one has failed
+			return nil
+		}
+	}
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if f.dive != nil {
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), f.dive, errs); err != nil {
+					return err
+				}
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				if err := validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), f.dive, errs); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("validation: dive on %s, which is not a slice or map", path)
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Struct {
+		return validateStruct(v, path, errs)
+	}
+	return nil
+}
+
+// check applies one rule, returning a FieldError when it fails
+func check(v reflect.Value, path string, r rule) (*FieldError, error) {
+	fail := func(format string, args ...interface{}) (*FieldError, error) {
+		return &FieldError{Field: path, Rule: r.name, Param: r.param, Message: path + " " + fmt.Sprintf(format, args...)}, nil
+	}
+
+	if r.name == "required" {
+		if isEmpty(v) {
+			return fail("is required")
+		}
+		return nil, nil
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	switch r.name {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("validation: invalid %s parameter %q on %s", r.name, r.param, path)
+		}
+		size, unit, ok := measure(v)
+		if !ok {
+			return nil, fmt.Errorf("validation: %s is not supported on %s (%s)", r.name, path, v.Kind())
+		}
+		switch {
+		case r.name == "min" && size < limit:
+			return fail("must be at least %s%s", r.param, unit)
+		case r.name == "max" && size > limit:
+			return fail("must be at most %s%s", r.param, unit)
+		case r.name == "len" && size != limit:
+			return fail("must be exactly %s%s", r.param, unit)
+		}
+	case "email":
+		s, err := stringValue(v, path, r)
+		if err != nil {
+			return nil, err
+		}
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s || !strings.Contains(s[strings.LastIndex(s, "@"):], ".") {
+			return fail("must be a valid email address")
+		}
+	case "hexcolor":
+		s, err := stringValue(v, path, r)
+		if err != nil {
+			return nil, err
+		}
+		if !hexColor.MatchString(s) {
+			return fail("must be a valid hex color such as #1a2b3c")
+		}
+	case "oneof":
+		options := strings.Fields(r.param)
+		actual := fmt.Sprint(v.Interface())
+		for _, option := range options {
+			if actual == option {
+				return nil, nil
+			}
+		}
+		return fail("must be one of: %s", strings.Join(options, ", "))
+	default:
+		return nil, fmt.Errorf("validation: unknown rule %q on %s", r.name, path)
+	}
+	return nil, nil
+}
+
+// measure returns what min, max and len compare: the rune count of strings,
+// the length of collections and the value of numbers
+func measure(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	return 0, "", false
+}
+
+func stringValue(v reflect.Value, path string, r rule) (string, error) {
+	if v.Kind() != reflect.String {
+		return "", fmt.Errorf("validation: %s is only supported on strings, %s is %s", r.name, path, v.Kind())
+	}
+	return v.String(), nil
+}
+
+// isEmpty reports whether v is its zero value; nil pointers are empty but a
+// pointer to a zero value is not, so optional fields can be set to ""
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
+
+// rulesFor parses and caches the validate tags of a struct type
+func rulesFor(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := typeCache.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		f, err := parseTag(sf.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("validation: %s.%s: %v", t.Name(), sf.Name, err)
+		}
+		f.index = i
+		f.name = name
+		if sf.Anonymous && sf.Tag.Get("json") == "" {
+			// Embedded structs share their parent's JSON namespace
+			f.name = ""
+		}
+		fields = append(fields, *f)
+	}
+
+	typeCache.Store(t, fields)
+	return fields, nil
+}
+
+func parseTag(tag string) (*fieldRules, error) {
+	f := &fieldRules{}
+	if tag == "" || tag == "-" {
+		return f, nil
+	}
+
+	parts := strings.Split(tag, ",")
+	for i, part := range parts {
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "":
+			continue
+		case "omitempty":
+			f.omitempty = true
+		case "dive":
+			dive, err := parseTag(strings.Join(parts[i+1:], ","))
+			if err != nil {
+				return nil, err
+			}
+			f.dive = dive
+			return f, nil
+		case "required", "email", "hexcolor":
+			f.rules = append(f.rules, rule{name: name})
+		case "min", "max", "len", "oneof":
+			if param == "" {
+				return nil, fmt.Errorf("rule %q needs a parameter", name)
+			}
+			f.rules = append(f.rules, rule{name: name, param: param})
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+	}
+	return f, nil
+}
+
+func joinPath(prefix, name string) string {
+	switch {
+	case prefix == "":
+		return name
+	case name == "":
+		return prefix
+	}
+	return prefix + "." + name
+}
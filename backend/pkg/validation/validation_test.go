@@ -0,0 +1,181 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type createCategoryRequest struct {
+	Name        string            `json:"name" validate:"required,min=2,max=100"`
+	Description string            `json:"description" validate:"max=500"`
+	Color       string            `json:"color" validate:"omitempty,hexcolor"`
+	Email       string            `json:"email" validate:"omitempty,email"`
+	Role        string            `json:"role" validate:"omitempty,oneof=admin editor viewer"`
+	Age         int               `json:"age" validate:"omitempty,min=18"`
+	Nickname    *string           `json:"nickname,omitempty" validate:"omitempty,min=2"`
+	Tags        []string          `json:"tags" validate:"max=3,dive,required,max=5"`
+	Labels      map[string]string `json:"labels" validate:"dive,hexcolor"`
+	Address     *address          `json:"address,omitempty"`
+	Addresses   []address         `json:"addresses" validate:"dive"`
+}
+
+func validRequest() createCategoryRequest {
+	return createCategoryRequest{Name: "Go", Color: "#00ADD8", Email: "gopher@example.com", Role: "admin", Age: 30}
+}
+
+func fieldErrors(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected validation.Errors, got %v", err)
+	}
+	fields := make(map[string]string)
+	for _, fe := range errs {
+		fields[fe.Field] = fe.Rule
+	}
+	return fields
+}
+
+func TestStructValid(t *testing.T) {
+	req := validRequest()
+	nickname := "gg"
+	req.Nickname = &nickname
+	req.Tags = []string{"a", "bb"}
+	req.Labels = map[string]string{"primary": "#fff"}
+	req.Address = &address{City: "Innopolis"}
+
+	if err := Struct(&req); err != nil {
+		t.Errorf("Expected valid request, got %v", err)
+	}
+	if err := Struct(createCategoryRequest{Name: "Go"}); err != nil {
+		t.Errorf("omitempty fields should be optional, got %v", err)
+	}
+}
+
+func TestStructRules(t *testing.T) {
+	empty := ""
+	tests := []struct {
+		name   string
+		modify func(r *createCategoryRequest)
+		field  string
+		rule   string
+	}{
+		{"required", func(r *createCategoryRequest) { r.Name = "" }, "name", "required"},
+		{"min string counts runes", func(r *createCategoryRequest) { r.Name = "é" }, "name", "min"},
+		{"max string", func(r *createCategoryRequest) { r.Description = strings.Repeat("x", 501) }, "description", "max"},
+		{"hexcolor", func(r *createCategoryRequest) { r.Color = "blue" }, "color", "hexcolor"},
+		{"email", func(r *createCategoryRequest) { r.Email = "not-an-email" }, "email", "email"},
+		{"email without domain dot", func(r *createCategoryRequest) { r.Email = "a@localhost" }, "email", "email"},
+		{"oneof", func(r *createCategoryRequest) { r.Role = "owner" }, "role", "oneof"},
+		{"min number", func(r *createCategoryRequest) { r.Age = 17 }, "age", "min"},
+		{"set pointer is validated", func(r *createCategoryRequest) { r.Nickname = &empty }, "nickname", "min"},
+		{"max items", func(r *createCategoryRequest) { r.Tags = []string{"a", "b", "c", "d"} }, "tags", "max"},
+		{"dive slice", func(r *createCategoryRequest) { r.Tags = []string{"ok", ""} }, "tags[1]", "required"},
+		{"dive map", func(r *createCategoryRequest) { r.Labels = map[string]string{"bad": "red"} }, "labels[bad]", "hexcolor"},
+		{"nested pointer", func(r *createCategoryRequest) { r.Address = &address{} }, "address.city", "required"},
+		{"dive structs", func(r *createCategoryRequest) { r.Addresses = []address{{City: "Kazan"}, {}} }, "addresses[1].city", "required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(&req)
+
+			fields := fieldErrors(t, Struct(req))
+			if fields[tt.field] != tt.rule {
+				t.Errorf("Expected %s to fail %s, got %v", tt.field, tt.rule, fields)
+			}
+		})
+	}
+}
+
+func TestStructReportsAllFields(t *testing.T) {
+	err := Struct(createCategoryRequest{Color: "nope", Age: 1})
+
+	fields := fieldErrors(t, err)
+	if len(fields) != 3 {
+		t.Errorf("Expected 3 failing fields, got %v", fields)
+	}
+	if !strings.Contains(err.Error(), "name is required") {
+		t.Errorf("Expected readable message, got %q", err.Error())
+	}
+}
+
+func TestStructInvalidTags(t *testing.T) {
+	var errs Errors
+	tests := []interface{}{
+		struct {
+			A string `validate:"unknown"`
+		}{},
+		struct {
+			A string `validate:"min=x"`
+		}{A: "a"},
+		struct {
+			A int `validate:"email"`
+		}{A: 1},
+	}
+	for i, v := range tests {
+		err := Struct(v)
+		if err == nil || errors.As(err, &errs) {
+			t.Errorf("Case %d: expected a tag error, got %v", i, err)
+		}
+	}
+	if err := Struct("not a struct"); err == nil {
+		t.Error("Expected non-struct values to be rejected")
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		decodeErr bool
+		fields    int
+	}{
+		{"valid", `{"name":"Go"}`, false, 0},
+		{"invalid fields", `{"name":"","color":"red"}`, false, 2},
+		{"malformed", `{"name":`, true, 0},
+		{"empty body", ``, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			var dst createCategoryRequest
+			err := DecodeJSON(req, &dst)
+
+			var decodeErr *DecodeError
+			if errors.As(err, &decodeErr) != tt.decodeErr {
+				t.Errorf("Expected decode error %v, got %v", tt.decodeErr, err)
+			}
+			var errs Errors
+			errors.As(err, &errs)
+			if len(errs) != tt.fields {
+				t.Errorf("Expected %d field errors, got %v", tt.fields, err)
+			}
+		})
+	}
+}
+
+func TestGinValidator(t *testing.T) {
+	v := GinValidator{}
+
+	if err := v.ValidateStruct(&createCategoryRequest{Name: "Go"}); err != nil {
+		t.Errorf("Expected valid struct, got %v", err)
+	}
+	if err := v.ValidateStruct(map[string]string{}); err != nil {
+		t.Errorf("Non-struct values should pass, got %v", err)
+	}
+
+	fields := fieldErrors(t, v.ValidateStruct([]createCategoryRequest{{Name: "Go"}, {}}))
+	if fields["[1].name"] != "required" {
+		t.Errorf("Expected slice element errors to be indexed, got %v", fields)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"lab03-backend/models"
 	"lab03-backend/storage"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/validation"
 )
 
 // Handler holds the storage instance
@@ -50,13 +52,7 @@ func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
 // CreateMessage handles POST /api/messages
 func (h *Handler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateMessageRequest
-	if err := h.parseJSON(r, &req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	if err := req.Validate(); err != nil {
-		h.writeError(w, http.StatusBadRequest, err.Error())
+	if !h.decodeRequest(w, r, &req) {
 		return
 	}
 
@@ -82,13 +78,7 @@ func (h *Handler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.UpdateMessageRequest
-	if err := h.parseJSON(r, &req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	if err := req.Validate(); err != nil {
-		h.writeError(w, http.StatusBadRequest, err.Error())
+	if !h.decodeRequest(w, r, &req) {
 		return
 	}
 
@@ -169,9 +159,21 @@ func (h *Handler) writeError(w http.ResponseWriter, status int, message string)
 	})
 }
 
-// Helper function to parse JSON request body
-func (h *Handler) parseJSON(r *http.Request, dst interface{}) error {
-	return json.NewDecoder(r.Body).Decode(dst)
+// Helper function to parse and validate a JSON request body; it writes the
+// error response and returns false when the body is unusable
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	err := validation.DecodeJSON(r, dst)
+	if err == nil {
+		return true
+	}
+
+	var decodeErr *validation.DecodeError
+	if errors.As(err, &decodeErr) {
+		h.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	h.writeError(w, http.StatusBadRequest, err.Error())
+	return false
 }
 
 // Helper function to get HTTP status description
@@ -1,5 +1,10 @@
 module lab03-backend
 
-go 1.24
+go 1.24.3
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/gorilla/mux v1.8.0
+	github.com/timur-harin/sum25-go-flutter-course/backend v0.0.0
+)
+
+replace github.com/timur-harin/sum25-go-flutter-course/backend => ../../../backend
@@ -1,8 +1,9 @@
 package models
 
 import (
-	"errors"
 	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/validation"
 )
 
 // Message represents a chat message
@@ -48,21 +49,12 @@ func NewMessage(id int, username, content string) *Message {
 	}
 }
 
-// Validate checks if the create message request is valid
+// Validate checks the request against its validate tags
 func (r *CreateMessageRequest) Validate() error {
-	if r.Username == "" {
-		return errors.New("username is required")
-	}
-	if r.Content == "" {
-		return errors.New("content is required")
-	}
-	return nil
+	return validation.Struct(r)
 }
 
-// Validate checks if the update message request is valid
+// Validate checks the request against its validate tags
 func (r *UpdateMessageRequest) Validate() error {
-	if r.Content == "" {
-		return errors.New("content is required")
-	}
-	return nil
+	return validation.Struct(r)
 }
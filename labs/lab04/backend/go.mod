@@ -1,11 +1,12 @@
 module lab04-backend
 
-go 1.24
+go 1.24.3
 
 require (
 	github.com/Masterminds/squirrel v1.5.4
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/pressly/goose/v3 v3.24.3
+	github.com/timur-harin/sum25-go-flutter-course/backend v0.0.0
 	gorm.io/gorm v1.25.12
 )
 
@@ -20,3 +21,5 @@ require (
 	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
 )
+
+replace github.com/timur-harin/sum25-go-flutter-course/backend => ../../../backend
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/validation"
 	"gorm.io/gorm"
 )
 
@@ -73,15 +74,15 @@ func (c *Category) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
-// TODO: Implement Validate method for CreateCategoryRequest
+// Validate checks the request against its validate tags. Name uniqueness is
+// enforced by the database through the unique index.
 func (req *CreateCategoryRequest) Validate() error {
-	// TODO: Add validation logic for GORM model
-	// - Name should be unique (checked at database level via GORM)
-	// - Color should be valid hex color
-	// - Description should not exceed limits
-	// Example using validator package:
-	// return validator.New().Struct(req)
-	return nil
+	return validation.Struct(req)
+}
+
+// Validate checks the fields that are being updated against their validate tags
+func (req *UpdateCategoryRequest) Validate() error {
+	return validation.Struct(req)
 }
 
 // TODO: Implement ToCategory method
@@ -0,0 +1,41 @@
+package models
+
+import "testing"
+
+func TestCreateCategoryRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     CreateCategoryRequest
+		wantErr bool
+	}{
+		{"valid request", CreateCategoryRequest{Name: "Go", Color: "#00ADD8"}, false},
+		{"color is optional", CreateCategoryRequest{Name: "Go"}, false},
+		{"empty name", CreateCategoryRequest{Name: ""}, true},
+		{"short name", CreateCategoryRequest{Name: "G"}, true},
+		{"invalid color", CreateCategoryRequest{Name: "Go", Color: "blue"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateCategoryRequest_Validate(t *testing.T) {
+	short := "G"
+	name := "Golang"
+
+	if err := (&UpdateCategoryRequest{}).Validate(); err != nil {
+		t.Errorf("Empty update should be valid, got %v", err)
+	}
+	if err := (&UpdateCategoryRequest{Name: &name}).Validate(); err != nil {
+		t.Errorf("Valid update returned %v", err)
+	}
+	if err := (&UpdateCategoryRequest{Name: &short}).Validate(); err == nil {
+		t.Error("Expected short name to be rejected")
+	}
+}
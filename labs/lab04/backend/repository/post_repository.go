@@ -2,20 +2,31 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"lab04-backend/models"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/authz"
 )
 
 // PostRepository handles database operations for posts
 // This repository demonstrates SCANY MAPPING approach for result scanning
 type PostRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	policy *authz.Policy
 }
 
-// NewPostRepository creates a new PostRepository
+// NewPostRepository creates a new PostRepository using authz.DefaultPolicy
+// for ownership checks
 func NewPostRepository(db *sql.DB) *PostRepository {
-	return &PostRepository{db: db}
+	return &PostRepository{db: db, policy: authz.DefaultPolicy()}
+}
+
+// WithPolicy returns a copy of the repository that authorizes with policy
+func (r *PostRepository) WithPolicy(policy *authz.Policy) *PostRepository {
+	return &PostRepository{db: r.db, policy: policy}
 }
 
 // TODO: Implement Create method using scany for result mapping
@@ -99,3 +110,94 @@ func (r *PostRepository) CountByUserID(userID int) (int, error) {
 	// - Use standard QueryRow.Scan() for single integer result
 	return 0, fmt.Errorf("TODO: implement CountByUserID method")
 }
+
+// UpdateAs updates a post on behalf of subject. Users may only edit their own
+// posts; editing someone else's requires posts:admin. Returns sql.ErrNoRows
+// if the post doesn't exist and an error matching authz.ErrForbidden if the
+// subject may not edit it.
+//
+// Ownership is checked in the UPDATE itself, so the post cannot change hands
+// between the check and the write.
+func (r *PostRepository) UpdateAs(subject authz.Subject, id int, req *models.UpdatePostRequest) (*models.Post, error) {
+	if err := r.policy.Authorize(subject, authz.PostsWrite); err != nil {
+		return nil, err
+	}
+
+	query := squirrel.Update("posts").Set("updated_at", squirrel.Expr("CURRENT_TIMESTAMP"))
+	if req.Title != nil {
+		query = query.Set("title", *req.Title)
+	}
+	if req.Content != nil {
+		query = query.Set("content", *req.Content)
+	}
+	if req.Published != nil {
+		query = query.Set("published", *req.Published)
+	}
+	query = query.Where(r.ownedBy(subject, id)).
+		Suffix("RETURNING id, user_id, title, COALESCE(content, ''), COALESCE(published, FALSE), created_at, updated_at")
+
+	var post models.Post
+	err := query.RunWith(r.db).QueryRow().Scan(
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.Published, &post.CreatedAt, &post.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.explainNoRows(subject, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update post: %v", err)
+	}
+	return &post, nil
+}
+
+// DeleteAs deletes a post on behalf of subject, with the same rules as UpdateAs
+func (r *PostRepository) DeleteAs(subject authz.Subject, id int) error {
+	if err := r.policy.Authorize(subject, authz.PostsWrite); err != nil {
+		return err
+	}
+
+	result, err := squirrel.Delete("posts").Where(r.ownedBy(subject, id)).RunWith(r.db).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to delete post: %v", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete post: %v", err)
+	}
+	if deleted == 0 {
+		return r.explainNoRows(subject, id)
+	}
+	return nil
+}
+
+// ownedBy matches post id if subject may write it: their own posts, or any
+// post with posts:admin
+func (r *PostRepository) ownedBy(subject authz.Subject, id int) squirrel.Sqlizer {
+	where := squirrel.And{squirrel.Eq{"id": id}, squirrel.Eq{"deleted_at": nil}}
+	if !r.policy.Can(subject, authz.PostsAdmin) {
+		where = append(where, squirrel.Eq{"user_id": subject.UserID})
+	}
+	return where
+}
+
+// explainNoRows tells why a write on post id matched no row: the post does
+// not exist, or subject does not own it
+func (r *PostRepository) explainNoRows(subject authz.Subject, id int) error {
+	ownerID, err := r.ownerOf(id)
+	if err != nil {
+		return err
+	}
+	if err := r.policy.AuthorizeOwner(subject, authz.PostsWrite, ownerID); err != nil {
+		return err
+	}
+	// The post was given to the subject after the write missed it
+	return sql.ErrNoRows
+}
+
+// ownerOf returns the user_id of post id, or sql.ErrNoRows
+func (r *PostRepository) ownerOf(id int) (int, error) {
+	var ownerID int
+	err := r.db.QueryRow(`SELECT user_id FROM posts WHERE id = ? AND deleted_at IS NULL`, id).Scan(&ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return ownerID, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/authz"
+)
+
+func setupPostTestDB(t *testing.T) (*PostRepository, *sql.DB, func()) {
+	testDB := "./test_post_repo.db"
+	db, err := sql.Open("sqlite3", testDB)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	cleanup := func() {
+		db.Close()
+		os.Remove(testDB)
+	}
+	return NewPostRepository(db), db, cleanup
+}
+
+func TestPostRepository_Ownership(t *testing.T) {
+	repo, db, cleanup := setupPostTestDB(t)
+	defer cleanup()
+
+	// Seed directly so the check does not depend on Create
+	_, err := db.Exec(`INSERT INTO users (id, name, email) VALUES (1, 'Owner', 'owner@example.com'), (2, 'Other', 'other@example.com')`)
+	if err != nil {
+		t.Fatalf("Failed to seed users: %v", err)
+	}
+	seedPost := func(t *testing.T) {
+		t.Helper()
+		if _, err := db.Exec(`INSERT OR REPLACE INTO posts (id, user_id, title) VALUES (10, 1, 'Owned post')`); err != nil {
+			t.Fatalf("Failed to seed post: %v", err)
+		}
+	}
+
+	title := "Edited title"
+	req := &models.UpdatePostRequest{Title: &title}
+
+	tests := []struct {
+		name      string
+		subject   authz.Subject
+		postID    int
+		forbidden bool
+		notFound  bool
+	}{
+		{"owner", authz.Subject{UserID: 1, Roles: []string{authz.RoleUser}}, 10, false, false},
+		{"other user", authz.Subject{UserID: 2, Roles: []string{authz.RoleUser}}, 10, true, false},
+		{"editor", authz.Subject{UserID: 2, Roles: []string{authz.RoleEditor}}, 10, false, false},
+		{"no roles", authz.Subject{UserID: 1}, 10, true, false},
+		{"missing post", authz.Subject{UserID: 1, Roles: []string{authz.RoleUser}}, 99, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seedPost(t)
+			post, updateErr := repo.UpdateAs(tt.subject, tt.postID, req)
+			deleteErr := repo.DeleteAs(tt.subject, tt.postID)
+
+			for _, err := range []error{updateErr, deleteErr} {
+				if errors.Is(err, authz.ErrForbidden) != tt.forbidden {
+					t.Errorf("Expected forbidden=%v, got %v", tt.forbidden, err)
+				}
+				if errors.Is(err, sql.ErrNoRows) != tt.notFound {
+					t.Errorf("Expected not found=%v, got %v", tt.notFound, err)
+				}
+			}
+
+			var remaining int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM posts WHERE id = 10`).Scan(&remaining); err != nil {
+				t.Fatalf("Failed to count posts: %v", err)
+			}
+			if tt.forbidden || tt.notFound {
+				if remaining != 1 {
+					t.Errorf("Expected the post to be kept, %d left", remaining)
+				}
+				return
+			}
+
+			if updateErr != nil || deleteErr != nil {
+				t.Fatalf("Expected success, got update=%v delete=%v", updateErr, deleteErr)
+			}
+			if post.ID != 10 || post.UserID != 1 || post.Title != title {
+				t.Errorf("Expected the edited post, got %+v", post)
+			}
+			if remaining != 0 {
+				t.Errorf("Expected the post to be deleted, %d left", remaining)
+			}
+		})
+	}
+}
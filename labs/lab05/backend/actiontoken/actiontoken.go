@@ -0,0 +1,253 @@
+// Package actiontoken issues single-use tokens for account actions such as
+// confirming an email address or resetting a password. Tokens are random,
+// expire, and are stored only as SHA-256 hashes.
+package actiontoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Purpose separates tokens for different actions, so a verification token
+// cannot reset a password
+type Purpose string
+
+// Purposes
+const (
+	VerifyEmail   Purpose = "verify_email"
+	ResetPassword Purpose = "reset_password"
+)
+
+var (
+	// ErrInvalidToken is returned for unknown, used, expired or mismatched
+	// tokens. The cases are not told apart so callers cannot probe tokens.
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrTokenNotFound is returned by stores for unknown hashes
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// Token is the stored form of an issued token
+type Token struct {
+	Hash    string
+	Purpose Purpose
+	UserID  int
+	// Email is the address the token was sent to; a verification token
+	// must not confirm an address the user has since changed
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// Store persists tokens
+type Store interface {
+	Save(ctx context.Context, token *Token) error
+	// Get returns ErrTokenNotFound for unknown hashes
+	Get(ctx context.Context, hash string) (*Token, error)
+	// MarkUsed sets UsedAt unless it is already set, reporting whether it
+	// did; it must be atomic so a token cannot be used twice
+	MarkUsed(ctx context.Context, hash string, at time.Time) (bool, error)
+	// DeleteUser removes the user's unused tokens for purpose
+	DeleteUser(ctx context.Context, userID int, purpose Purpose) error
+}
+
+// Default lifetimes
+const (
+	DefaultVerifyEmailTTL   = 24 * time.Hour
+	DefaultResetPasswordTTL = time.Hour
+)
+
+// Service issues and redeems tokens
+type Service struct {
+	store Store
+	ttl   map[Purpose]time.Duration
+	now   func() time.Time
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithStore sets where tokens are kept. The default is a MemoryStore.
+func WithStore(store Store) Option {
+	return func(s *Service) { s.store = store }
+}
+
+// WithTTL sets how long tokens for purpose stay valid
+func WithTTL(purpose Purpose, ttl time.Duration) Option {
+	return func(s *Service) { s.ttl[purpose] = ttl }
+}
+
+// WithClock sets the time source, for tests
+func WithClock(now func() time.Time) Option {
+	return func(s *Service) { s.now = now }
+}
+
+// NewService creates a service
+func NewService(opts ...Option) *Service {
+	s := &Service{
+		store: NewMemoryStore(),
+		ttl: map[Purpose]time.Duration{
+			VerifyEmail:   DefaultVerifyEmailTTL,
+			ResetPassword: DefaultResetPasswordTTL,
+		},
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// TTL returns how long tokens for purpose stay valid
+func (s *Service) TTL(purpose Purpose) time.Duration {
+	return s.ttl[purpose]
+}
+
+// Issue creates a token for the user and returns it in plain form, to be
+// sent to email. Earlier unused tokens for the same purpose stop working.
+func (s *Service) Issue(ctx context.Context, purpose Purpose, userID int, email string) (string, error) {
+	ttl, ok := s.ttl[purpose]
+	if !ok {
+		return "", fmt.Errorf("unknown token purpose %q", purpose)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	plain := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := s.store.DeleteUser(ctx, userID, purpose); err != nil {
+		return "", fmt.Errorf("failed to replace token: %v", err)
+	}
+	now := s.now()
+	token := &Token{
+		Hash:      hash(plain),
+		Purpose:   purpose,
+		UserID:    userID,
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.store.Save(ctx, token); err != nil {
+		return "", fmt.Errorf("failed to save token: %v", err)
+	}
+	return plain, nil
+}
+
+// Lookup returns the token without using it up, e.g. to check a new
+// password before the reset token is spent
+func (s *Service) Lookup(ctx context.Context, purpose Purpose, plain string) (*Token, error) {
+	if plain == "" {
+		return nil, ErrInvalidToken
+	}
+	token, err := s.store.Get(ctx, hash(plain))
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token: %v", err)
+	}
+	if token.Purpose != purpose || token.UsedAt != nil || !s.now().Before(token.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return token, nil
+}
+
+// Redeem checks the token and marks it used. Only one of several concurrent
+// calls with the same token succeeds.
+func (s *Service) Redeem(ctx context.Context, purpose Purpose, plain string) (*Token, error) {
+	token, err := s.Lookup(ctx, purpose, plain)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	marked, err := s.store.MarkUsed(ctx, token.Hash, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use token: %v", err)
+	}
+	if !marked {
+		return nil, ErrInvalidToken
+	}
+	token.UsedAt = &now
+	return token, nil
+}
+
+func hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryStore is a Store kept in process memory; expired tokens are evicted
+// as new ones are saved
+type MemoryStore struct {
+	mutex  sync.Mutex
+	tokens map[string]Token
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tokens: make(map[string]Token)}
+}
+
+// Save stores a copy of token
+func (m *MemoryStore) Save(ctx context.Context, token *Token) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for hash, t := range m.tokens {
+		if now.After(t.ExpiresAt) {
+			delete(m.tokens, hash)
+		}
+	}
+	m.tokens[token.Hash] = *token
+	return nil
+}
+
+// Get returns a copy of the token
+func (m *MemoryStore) Get(ctx context.Context, hash string) (*Token, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	token, ok := m.tokens[hash]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return &token, nil
+}
+
+// MarkUsed sets UsedAt once
+func (m *MemoryStore) MarkUsed(ctx context.Context, hash string, at time.Time) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	token, ok := m.tokens[hash]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &at
+	m.tokens[hash] = token
+	return true, nil
+}
+
+// DeleteUser removes the user's unused tokens for purpose
+func (m *MemoryStore) DeleteUser(ctx context.Context, userID int, purpose Purpose) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for hash, token := range m.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			delete(m.tokens, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package actiontoken
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIssueAndRedeem(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+
+	token, err := service.Issue(ctx, VerifyEmail, 1, "John@Example.com")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	if _, err := service.Redeem(ctx, ResetPassword, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Token must not work for another purpose, got %v", err)
+	}
+	if _, err := service.Lookup(ctx, VerifyEmail, token); err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+
+	redeemed, err := service.Redeem(ctx, VerifyEmail, token)
+	if err != nil {
+		t.Fatalf("Redeem failed: %v", err)
+	}
+	if redeemed.UserID != 1 || redeemed.Email != "john@example.com" || redeemed.UsedAt == nil {
+		t.Errorf("Unexpected token %+v", redeemed)
+	}
+	if _, err := service.Redeem(ctx, VerifyEmail, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Token must be single-use, got %v", err)
+	}
+
+	for _, bad := range []string{"", "not-a-token"} {
+		if _, err := service.Redeem(ctx, VerifyEmail, bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Redeem(%q) error = %v, want ErrInvalidToken", bad, err)
+		}
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := NewService(WithClock(func() time.Time { return now }), WithTTL(ResetPassword, time.Minute))
+
+	token, err := service.Issue(ctx, ResetPassword, 1, "john@example.com")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := service.Redeem(ctx, ResetPassword, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expired token should be rejected, got %v", err)
+	}
+}
+
+func TestReissueReplacesToken(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+
+	first, _ := service.Issue(ctx, ResetPassword, 1, "john@example.com")
+	verify, _ := service.Issue(ctx, VerifyEmail, 1, "john@example.com")
+	second, _ := service.Issue(ctx, ResetPassword, 1, "john@example.com")
+
+	if _, err := service.Lookup(ctx, ResetPassword, first); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("A new token should replace the previous one, got %v", err)
+	}
+	if _, err := service.Lookup(ctx, ResetPassword, second); err != nil {
+		t.Errorf("Latest token should work, got %v", err)
+	}
+	if _, err := service.Lookup(ctx, VerifyEmail, verify); err != nil {
+		t.Errorf("Tokens for other purposes should be kept, got %v", err)
+	}
+	if _, err := service.Issue(ctx, Purpose("unknown"), 1, "john@example.com"); err == nil {
+		t.Error("Unknown purposes should be rejected")
+	}
+}
+
+func TestConcurrentRedeem(t *testing.T) {
+	ctx := context.Background()
+	service := NewService()
+	token, _ := service.Issue(ctx, ResetPassword, 1, "john@example.com")
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	successes := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.Redeem(ctx, ResetPassword, token); err == nil {
+				mutex.Lock()
+				successes++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("Expected exactly one successful redeem, got %d", successes)
+	}
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"lab05/actiontoken"
+	"lab05/mail"
+	"lab05/security"
+	"lab05/userdomain"
+)
+
+// TokenRequest is the body of POST /auth/verify-email
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+// ForgotPasswordRequest is the body of POST /auth/password/forgot
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest is the body of POST /auth/password/reset
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// backgroundMailTimeout bounds mail sent after the response
+const backgroundMailTimeout = 30 * time.Second
+
+// mailData is passed to the mail templates
+type mailData struct {
+	Name      string
+	URL       string
+	ExpiresIn string
+}
+
+// VerifyEmail handles POST /auth/verify-email
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req TokenRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	token, err := h.actions.Redeem(r.Context(), actiontoken.VerifyEmail, req.Token)
+	if errors.Is(err, actiontoken.ErrInvalidToken) {
+		h.writeError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	user, ok := h.tokenUser(w, r, token)
+	if !ok {
+		return
+	}
+	user.EmailVerified = true
+	user.UpdatedAt = time.Now()
+	if err := h.users.Update(r.Context(), user); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, user)
+}
+
+// ResendVerification handles POST /auth/verify-email/resend for the
+// signed-in user
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	claims, _ := ClaimsFromContext(r.Context())
+	user, err := h.users.GetByID(r.Context(), claims.UserID)
+	if errors.Is(err, userdomain.ErrUserNotFound) {
+		h.writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if user.EmailVerified {
+		h.writeError(w, http.StatusConflict, "Email is already verified")
+		return
+	}
+
+	if err := h.sendActionMail(r.Context(), user, actiontoken.VerifyEmail); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ForgotPassword handles POST /auth/password/forgot. It answers 202 whether
+// or not the email belongs to an account, and mails the link in the
+// background so the response takes as long either way; the endpoint cannot
+// be used to find accounts. Requests are limited per IP and mails per email
+// so it cannot be used to flood an inbox.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	// Requests are limited per IP only. A limit per address would let anyone
+	// block the owner's recovery by asking for links in their name.
+	ip := clientIP(r)
+	if err := h.resetThrottle.Allow(r.Context(), "", ip); err != nil {
+		var throttled *security.ThrottledError
+		if !errors.As(err, &throttled) {
+			h.writeInternalError(w, r, err)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		h.writeError(w, http.StatusTooManyRequests, "Too many reset requests; try again later")
+		return
+	}
+	// Every request counts, whether or not a mail goes out
+	if _, err := h.resetThrottle.Failure(r.Context(), "", ip); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	// Mails per address are capped. Over the cap nothing is sent, but the
+	// answer is the same, known address or not.
+	err := h.resetThrottle.Allow(r.Context(), req.Email, "")
+	if errors.Is(err, security.ErrThrottled) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if _, err := h.resetThrottle.Failure(r.Context(), req.Email, ""); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	user, err := h.users.GetByEmail(r.Context(), req.Email)
+	switch {
+	case errors.Is(err, userdomain.ErrUserNotFound):
+	case err != nil:
+		h.writeInternalError(w, r, err)
+		return
+	default:
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), backgroundMailTimeout)
+			defer cancel()
+			if err := h.sendActionMail(ctx, user, actiontoken.ResetPassword); err != nil {
+				log.Printf("Failed to send password reset to user %d: %v", user.ID, err)
+			}
+		}()
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword handles POST /auth/password/reset. The new password is
+// checked before the token is spent, so a rejected password can be retried
+// with the same link. Existing sessions are signed out.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	token, err := h.actions.Lookup(r.Context(), actiontoken.ResetPassword, req.Token)
+	if errors.Is(err, actiontoken.ErrInvalidToken) {
+		h.writeError(w, http.StatusBadRequest, "Invalid or expired reset link")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	user, ok := h.tokenUser(w, r, token)
+	if !ok {
+		return
+	}
+
+	if err := userdomain.ValidatePassword(req.Password, user.Email, user.Name); err != nil {
+		h.writePasswordError(w, err)
+		return
+	}
+	hash, err := h.passwords.HashPassword(req.Password)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	if _, err := h.actions.Redeem(r.Context(), actiontoken.ResetPassword, req.Token); err != nil {
+		if errors.Is(err, actiontoken.ErrInvalidToken) {
+			h.writeError(w, http.StatusBadRequest, "Invalid or expired reset link")
+			return
+		}
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	user.Password = hash
+	// Following the link proves the user controls the address
+	user.EmailVerified = true
+	user.UpdatedAt = now
+	if err := h.users.Update(r.Context(), user); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(r.Context(), user.ID, now); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if err := h.throttle.Success(r.Context(), user.Email); err != nil {
+		log.Printf("Failed to reset login attempts of user %d: %v", user.ID, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireVerified rejects users whose email address is not verified. It
+// must run behind requireAuth.
+func (h *Handler) requireVerified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		user, err := h.users.GetByID(r.Context(), claims.UserID)
+		if errors.Is(err, userdomain.ErrUserNotFound) {
+			h.writeError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		if err != nil {
+			h.writeInternalError(w, r, err)
+			return
+		}
+		if !user.EmailVerified {
+			h.writeError(w, http.StatusForbidden, "Email address is not verified")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// tokenUser loads the user a token was issued to. A token sent to an
+// address the user no longer has is treated as invalid.
+func (h *Handler) tokenUser(w http.ResponseWriter, r *http.Request, token *actiontoken.Token) (*userdomain.User, bool) {
+	user, err := h.users.GetByID(r.Context(), token.UserID)
+	if err != nil && !errors.Is(err, userdomain.ErrUserNotFound) {
+		h.writeInternalError(w, r, err)
+		return nil, false
+	}
+	if user == nil || !strings.EqualFold(user.Email, token.Email) {
+		h.writeError(w, http.StatusBadRequest, "Invalid or expired link")
+		return nil, false
+	}
+	return user, true
+}
+
+// sendActionMail issues a token for purpose and mails the link to the user
+func (h *Handler) sendActionMail(ctx context.Context, user *userdomain.User, purpose actiontoken.Purpose) error {
+	token, err := h.actions.Issue(ctx, purpose, user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	template, path := mail.TemplateVerifyEmail, "/verify-email"
+	if purpose == actiontoken.ResetPassword {
+		template, path = mail.TemplateResetPassword, "/reset-password"
+	}
+	msg, err := h.templates.Render(template, user.Email, mailData{
+		Name:      user.Name,
+		URL:       h.appURL + path + "?token=" + url.QueryEscape(token),
+		ExpiresIn: humanDuration(h.actions.TTL(purpose)),
+	})
+	if err != nil {
+		return err
+	}
+	return h.mailer.Send(ctx, msg)
+}
+
+// humanDuration formats d for people, e.g. "24 hours" or "30 minutes"
+func humanDuration(d time.Duration) string {
+	unit, n := "minute", int(d.Round(time.Minute)/time.Minute)
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, n = "hour", int(d/time.Hour)
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
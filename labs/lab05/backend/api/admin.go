@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lab05/userdomain"
+
+	"github.com/gorilla/mux"
+)
+
+// SetRolesRequest is the body of PUT /admin/users/{id}/roles
+type SetRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// RequirePermission returns middleware that rejects requests unless the
+// handler's policy grants every one of permissions to the caller. It must
+// wrap a handler behind requireAuth.
+func (h *Handler) RequirePermission(permissions ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+				h.writeError(w, http.StatusUnauthorized, "Missing bearer token")
+				return
+			}
+			for _, permission := range permissions {
+				if err := h.policy.Authorize(claims.Subject(), permission); err != nil {
+					h.writeError(w, http.StatusForbidden, "Missing permission "+permission)
+					return
+				}
+			}
+			next(w, r)
+		}
+	}
+}
+
+// SetUserRoles handles PUT /admin/users/{id}/roles. The user's existing
+// tokens are revoked so the new roles take effect on their next sign-in or
+// refresh.
+func (h *Handler) SetUserRoles(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req SetRolesRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	if len(req.Roles) == 0 {
+		h.writeError(w, http.StatusBadRequest, "At least one role is required")
+		return
+	}
+	for _, role := range req.Roles {
+		if !h.policy.HasRole(role) {
+			h.writeError(w, http.StatusBadRequest, "Unknown role "+role)
+			return
+		}
+	}
+
+	user, err := h.users.GetByID(r.Context(), id)
+	if errors.Is(err, userdomain.ErrUserNotFound) {
+		h.writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	user.Roles = req.Roles
+	user.UpdatedAt = now
+	if err := h.users.Update(r.Context(), user); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(r.Context(), user.ID, now); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, user)
+}
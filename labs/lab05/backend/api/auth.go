@@ -0,0 +1,415 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lab05/actiontoken"
+	"lab05/audit"
+	"lab05/jwtservice"
+	"lab05/security"
+	"lab05/userdomain"
+)
+
+// RegisterRequest is the body of POST /auth/register
+type RegisterRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// LoginRequest is the body of POST /auth/login
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest is the body of POST /auth/refresh and POST /auth/logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// PasswordCheckRequest is the body of POST /auth/password/check. Email and
+// name are optional and let the policy reject passwords based on them.
+type PasswordCheckRequest struct {
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+}
+
+// PasswordCheckResponse reports how a password fares against the policy
+type PasswordCheckResponse struct {
+	Valid      bool                 `json:"valid"`
+	Violations []security.Violation `json:"violations"`
+	// Policy lets clients describe the requirements up front
+	Policy security.PasswordPolicy `json:"policy"`
+}
+
+// TokenResponse carries a newly issued token pair
+type TokenResponse struct {
+	AccessToken  string           `json:"access_token"`
+	RefreshToken string           `json:"refresh_token"`
+	TokenType    string           `json:"token_type"`
+	ExpiresIn    int              `json:"expires_in"`
+	User         *userdomain.User `json:"user"`
+}
+
+type contextKey int
+
+const claimsKey contextKey = iota
+
+// ClaimsFromContext returns the claims stored by requireAuth
+func ClaimsFromContext(ctx context.Context) (*jwtservice.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*jwtservice.Claims)
+	return claims, ok
+}
+
+// Register handles POST /auth/register
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	user, err := userdomain.NewUser(email, strings.TrimSpace(req.Name), req.Password)
+	if err != nil {
+		h.writePasswordError(w, err)
+		return
+	}
+
+	hash, err := h.passwords.HashPassword(req.Password)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	user.Password = hash
+
+	if err := h.users.Create(r.Context(), user); err != nil {
+		if errors.Is(err, userdomain.ErrEmailTaken) {
+			h.writeError(w, http.StatusConflict, "Email is already registered")
+			return
+		}
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if err := h.sendActionMail(r.Context(), user, actiontoken.VerifyEmail); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	h.issueTokens(w, r, http.StatusCreated, user)
+}
+
+// writePasswordError writes a 400 for a validation error, listing every
+// broken rule if a password was rejected
+func (h *Handler) writePasswordError(w http.ResponseWriter, err error) {
+	var policyErr *security.PolicyError
+	if errors.As(err, &policyErr) {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "Password does not meet the requirements", Violations: policyErr.Violations})
+		return
+	}
+	h.writeError(w, http.StatusBadRequest, err.Error())
+}
+
+// CheckPassword handles POST /auth/password/check, reporting every rule the
+// password breaks so clients can give feedback while the user types
+func (h *Handler) CheckPassword(w http.ResponseWriter, r *http.Request) {
+	var req PasswordCheckRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	violations, err := userdomain.PasswordPolicy.Check(req.Password, req.Email, req.Name)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if violations == nil {
+		violations = []security.Violation{}
+	}
+	h.writeJSON(w, http.StatusOK, PasswordCheckResponse{
+		Valid:      len(violations) == 0,
+		Violations: violations,
+		Policy:     userdomain.PasswordPolicy,
+	})
+}
+
+// Login handles POST /auth/login. Accounts with two-factor authentication
+// get an MFAChallengeResponse to complete at POST /auth/mfa/verify.
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	ip := clientIP(r)
+	if err := h.throttle.Allow(r.Context(), req.Email, ip); err != nil {
+		var throttled *security.ThrottledError
+		if !errors.As(err, &throttled) {
+			h.writeInternalError(w, r, err)
+			return
+		}
+		h.audit.Record(r.Context(), audit.Event{Type: audit.LoginLocked, Account: req.Email, IP: ip, Time: time.Now()})
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		h.writeError(w, http.StatusTooManyRequests, "Too many failed login attempts; try again later")
+		return
+	}
+
+	user, err := h.users.GetByEmail(r.Context(), req.Email)
+	if err != nil && !errors.Is(err, userdomain.ErrUserNotFound) {
+		h.releaseAttempt(r, req.Email, ip)
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	// Unknown accounts, and accounts that only sign in through an identity
+	// provider, are checked against a dummy hash so that they take as long
+	// to reject as a wrong password
+	hash := h.passwords.DummyHash()
+	if user != nil && user.Password != "" {
+		hash = user.Password
+	}
+	ok, newHash, err := h.passwords.VerifyAndRehash(req.Password, hash)
+	if err != nil && user != nil {
+		log.Printf("Failed to verify password of user %d: %v", user.ID, err)
+	}
+	if user == nil || user.Password == "" || !ok {
+		h.loginFailed(w, r, req.Email, ip, user)
+		return
+	}
+
+	// Upgrade hashes made with an older algorithm or cost. A failure here
+	// must not block the login; the upgrade is retried next time.
+	if newHash != "" {
+		user.Password = newHash
+		if err := h.users.Update(r.Context(), user); err != nil {
+			log.Printf("Failed to rehash password of user %d: %v", user.ID, err)
+		}
+	}
+
+	h.releaseAttempt(r, req.Email, ip)
+	// Failures are only reset once the second factor is verified, otherwise
+	// someone with the password could guess codes without limit
+	if user.MFAEnabled {
+		h.writeMFAChallenge(w, r, user)
+		return
+	}
+	if err := h.throttle.Success(r.Context(), req.Email); err != nil {
+		log.Printf("Failed to reset login attempts of user %d: %v", user.ID, err)
+	}
+
+	h.issueTokens(w, r, http.StatusOK, user)
+}
+
+// loginFailed records a failed login and writes the response, which is the
+// same whether or not the account exists
+func (h *Handler) loginFailed(w http.ResponseWriter, r *http.Request, account, ip string, user *userdomain.User) {
+	event := audit.Event{Type: audit.LoginFailed, Account: account, IP: ip, Reason: "wrong password", Time: time.Now()}
+	if user != nil {
+		event.UserID = user.ID
+	} else {
+		event.Reason = "unknown account"
+	}
+	h.audit.Record(r.Context(), event)
+
+	locked, err := h.throttle.Failure(r.Context(), account, ip)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if locked {
+		event.Type, event.Reason = audit.AccountLocked, "too many failed attempts"
+		h.audit.Record(r.Context(), event)
+	}
+	h.writeError(w, http.StatusUnauthorized, "Invalid email or password")
+}
+
+// releaseAttempt ends a login attempt that did not fail. An error only
+// delays the release until the throttle forgets the attempt, so it is logged.
+func (h *Handler) releaseAttempt(r *http.Request, account, ip string) {
+	if err := h.throttle.Release(r.Context(), account, ip); err != nil {
+		log.Printf("Failed to release login attempt of %s: %v", ip, err)
+	}
+}
+
+// clientIP returns the address the request came from. X-Forwarded-For is
+// ignored since any client can set it; put a proxy that rewrites RemoteAddr
+// in front if needed.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Refresh handles POST /auth/refresh. The refresh token is rotated: the
+// response carries a new one and the presented token stops working.
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	// The user is reloaded so the new access token carries current roles
+	var user *userdomain.User
+	pair, err := h.tokens.RefreshSession(r.Context(), req.RefreshToken, func(ctx context.Context, userID int) (*jwtservice.Identity, error) {
+		var err error
+		user, err = h.users.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		identity := identityOf(user)
+		return &identity, nil
+	}, clientOf(r))
+	if errors.Is(err, userdomain.ErrUserNotFound) {
+		h.writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if err != nil {
+		h.writeTokenError(w, r, err)
+		return
+	}
+
+	h.writeTokens(w, http.StatusOK, pair, user)
+}
+
+// Logout handles POST /auth/logout, revoking the refresh token and every
+// token rotated from the same login. An access token sent as a Bearer
+// token is revoked as well.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.tokens.RevokeRefreshToken(r.Context(), req.RefreshToken); err != nil {
+		h.writeTokenError(w, r, err)
+		return
+	}
+
+	if token, ok := bearerToken(r); ok {
+		if claims, err := h.tokens.ValidateTokenContext(r.Context(), token); err == nil {
+			if err := h.tokens.RevokeToken(r.Context(), claims); err != nil {
+				h.writeInternalError(w, r, err)
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me handles GET /auth/me
+func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
+	claims, _ := ClaimsFromContext(r.Context())
+
+	user, err := h.users.GetByID(r.Context(), claims.UserID)
+	if errors.Is(err, userdomain.ErrUserNotFound) {
+		h.writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, user)
+}
+
+// requireAuth rejects requests without a valid Bearer access token and
+// stores its claims in the request context
+func (h *Handler) requireAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			h.writeError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+
+		claims, err := h.tokens.ValidateTokenContext(r.Context(), token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			switch {
+			case errors.Is(err, jwtservice.ErrTokenExpired):
+				h.writeError(w, http.StatusUnauthorized, "Token expired")
+			case errors.Is(err, jwtservice.ErrTokenRevoked):
+				h.writeError(w, http.StatusUnauthorized, "Token revoked")
+			case isTokenError(err):
+				h.writeError(w, http.StatusUnauthorized, "Invalid token")
+			default:
+				h.writeInternalError(w, r, err)
+			}
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+	})
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// isTokenError reports whether err means the client sent a bad token, as
+// opposed to a failure such as an unreachable revocation store
+func isTokenError(err error) bool {
+	var methodErr jwtservice.InvalidSigningMethodError
+	return errors.Is(err, jwtservice.ErrInvalidToken) ||
+		errors.Is(err, jwtservice.ErrInvalidClaims) ||
+		errors.Is(err, jwtservice.ErrEmptyToken) ||
+		errors.As(err, &methodErr)
+}
+
+// issueTokens starts a new session for user and writes the token pair
+func (h *Handler) issueTokens(w http.ResponseWriter, r *http.Request, status int, user *userdomain.User) {
+	pair, err := h.tokens.StartSession(r.Context(), identityOf(user), clientOf(r))
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	h.writeTokens(w, status, pair, user)
+}
+
+// identityOf returns the token identity of user
+func identityOf(user *userdomain.User) jwtservice.Identity {
+	return jwtservice.Identity{UserID: user.ID, Email: user.Email, Roles: user.Roles}
+}
+
+func (h *Handler) writeTokens(w http.ResponseWriter, status int, pair *jwtservice.TokenPair, user *userdomain.User) {
+	h.writeJSON(w, status, TokenResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(pair.ExpiresIn.Seconds()),
+		User:         user,
+	})
+}
+
+// writeTokenError maps refresh token failures to responses
+func (h *Handler) writeTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, jwtservice.ErrRefreshTokenReused):
+		h.writeError(w, http.StatusUnauthorized, "Refresh token was already used; please sign in again")
+	case errors.Is(err, jwtservice.ErrTokenExpired):
+		h.writeError(w, http.StatusUnauthorized, "Refresh token expired")
+	case errors.Is(err, jwtservice.ErrInvalidToken), errors.Is(err, jwtservice.ErrEmptyToken):
+		h.writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+	default:
+		h.writeInternalError(w, r, err)
+	}
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"lab05/actiontoken"
+	"lab05/audit"
+	"lab05/jwtservice"
+	"lab05/mail"
+	"lab05/oidc"
+	"lab05/security"
+	"lab05/storage"
+	"lab05/totp"
+	"lab05/userdomain"
+
+	"github.com/gorilla/mux"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/authz"
+)
+
+// Handler serves the authentication API
+type Handler struct {
+	users     userdomain.Repository
+	passwords *security.PasswordService
+	tokens    *jwtservice.JWTService
+	policy    *authz.Policy
+	throttle  *security.LoginThrottle
+	// resetThrottle limits password reset mails
+	resetThrottle *security.LoginThrottle
+	audit         audit.Recorder
+	actions       *actiontoken.Service
+	mailer        mail.Mailer
+	templates     *mail.Templates
+	appURL        string
+	totp          totp.Config
+
+	oidc       map[string]*oidc.Client
+	oidcStates oidc.StateStore
+	identities userdomain.IdentityRepository
+}
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithPolicy sets the policy that maps roles to permissions. The default is
+// authz.DefaultPolicy.
+func WithPolicy(policy *authz.Policy) Option {
+	return func(h *Handler) { h.policy = policy }
+}
+
+// WithLoginThrottle sets the tracker of failed logins. The default uses
+// security.DefaultThrottleConfig with an in-memory store.
+func WithLoginThrottle(throttle *security.LoginThrottle) Option {
+	return func(h *Handler) { h.throttle = throttle }
+}
+
+// WithPasswordResetThrottle sets the limit on password reset requests. The
+// default uses security.PasswordResetThrottleConfig with an in-memory store.
+func WithPasswordResetThrottle(throttle *security.LoginThrottle) Option {
+	return func(h *Handler) { h.resetThrottle = throttle }
+}
+
+// WithAuditRecorder sets where security events go. The default writes them
+// to the log.
+func WithAuditRecorder(recorder audit.Recorder) Option {
+	return func(h *Handler) { h.audit = recorder }
+}
+
+// WithActionTokens sets the service for email verification and password
+// reset tokens. The default keeps tokens in memory.
+func WithActionTokens(actions *actiontoken.Service) Option {
+	return func(h *Handler) { h.actions = actions }
+}
+
+// WithMailer sets how account emails are delivered. The default prints them
+// to the log.
+func WithMailer(mailer mail.Mailer) Option {
+	return func(h *Handler) { h.mailer = mailer }
+}
+
+// WithMailTemplates replaces the bundled mail templates
+func WithMailTemplates(templates *mail.Templates) Option {
+	return func(h *Handler) { h.templates = templates }
+}
+
+// WithAppURL sets the base URL of the client app that links in emails point
+// to, e.g. https://app.example.com. The default is http://localhost:8080.
+func WithAppURL(appURL string) Option {
+	return func(h *Handler) { h.appURL = strings.TrimSuffix(appURL, "/") }
+}
+
+// WithTOTPConfig sets the TOTP parameters. The default is
+// totp.DefaultConfig with "Lab05" as the issuer shown in authenticator apps.
+func WithTOTPConfig(config totp.Config) Option {
+	return func(h *Handler) { h.totp = config }
+}
+
+// WithOIDCProvider enables sign-in with an OpenID Connect provider at
+// /auth/oidc/{name}/login. The client's redirect URL must point to
+// /auth/oidc/{name}/callback.
+func WithOIDCProvider(name string, client *oidc.Client) Option {
+	return func(h *Handler) { h.oidc[name] = client }
+}
+
+// WithOIDCStateStore sets where pending provider logins are kept. The
+// default keeps them in memory.
+func WithOIDCStateStore(store oidc.StateStore) Option {
+	return func(h *Handler) { h.oidcStates = store }
+}
+
+// WithIdentityRepository sets where links to provider accounts are kept.
+// The default keeps them in memory.
+func WithIdentityRepository(identities userdomain.IdentityRepository) Option {
+	return func(h *Handler) { h.identities = identities }
+}
+
+// NewHandler creates a new handler instance
+func NewHandler(users userdomain.Repository, passwords *security.PasswordService, tokens *jwtservice.JWTService, opts ...Option) *Handler {
+	h := &Handler{
+		users:         users,
+		passwords:     passwords,
+		tokens:        tokens,
+		policy:        authz.DefaultPolicy(),
+		throttle:      security.NewLoginThrottle(security.DefaultThrottleConfig()),
+		resetThrottle: security.NewLoginThrottle(security.PasswordResetThrottleConfig()),
+		audit:         audit.LogRecorder{},
+		actions:       actiontoken.NewService(),
+		mailer:        mail.NewConsoleMailer(log.Writer()),
+		templates:     mail.DefaultTemplates(),
+		appURL:        "http://localhost:8080",
+		totp:          totp.DefaultConfig("Lab05"),
+
+		oidc:       make(map[string]*oidc.Client),
+		oidcStates: oidc.NewMemoryStateStore(),
+		identities: storage.NewMemoryIdentityRepository(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// SetupRoutes configures all API routes
+func (h *Handler) SetupRoutes() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/.well-known/jwks.json", h.JWKS).Methods("GET")
+
+	auth := router.PathPrefix("/auth").Subrouter()
+	auth.HandleFunc("/register", h.Register).Methods("POST")
+	auth.HandleFunc("/login", h.Login).Methods("POST")
+	auth.HandleFunc("/refresh", h.Refresh).Methods("POST")
+	auth.HandleFunc("/logout", h.Logout).Methods("POST")
+	auth.HandleFunc("/password/check", h.CheckPassword).Methods("POST")
+	auth.HandleFunc("/password/forgot", h.ForgotPassword).Methods("POST")
+	auth.HandleFunc("/password/reset", h.ResetPassword).Methods("POST")
+	auth.HandleFunc("/verify-email", h.VerifyEmail).Methods("POST")
+	auth.Handle("/verify-email/resend", h.requireAuth(h.ResendVerification)).Methods("POST")
+	auth.Handle("/me", h.requireAuth(h.Me)).Methods("GET")
+	auth.Handle("/sessions", h.requireAuth(h.ListSessions)).Methods("GET")
+	auth.Handle("/sessions/revoke-others", h.requireAuth(h.RevokeOtherSessions)).Methods("POST")
+	auth.Handle("/sessions/{id}", h.requireAuth(h.RevokeSession)).Methods("DELETE")
+	auth.HandleFunc("/oidc/{provider}/login", h.OIDCLogin).Methods("GET")
+	auth.HandleFunc("/oidc/{provider}/callback", h.OIDCCallback).Methods("GET")
+	auth.HandleFunc("/mfa/verify", h.VerifyMFA).Methods("POST")
+	auth.Handle("/mfa/totp/setup", h.requireAuth(h.requireVerified(h.SetupTOTP))).Methods("POST")
+	auth.Handle("/mfa/totp/enable", h.requireAuth(h.requireVerified(h.EnableTOTP))).Methods("POST")
+	auth.Handle("/mfa/totp/disable", h.requireAuth(h.DisableTOTP)).Methods("POST")
+	auth.Handle("/mfa/recovery-codes", h.requireAuth(h.RegenerateRecoveryCodes)).Methods("POST")
+
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Handle("/users/{id:[0-9]+}/roles", h.requireAuth(h.requireVerified(h.RequirePermission(authz.UsersAdmin)(h.SetUserRoles)))).Methods("PUT")
+
+	return router
+}
+
+// JWKS handles GET /.well-known/jwks.json, publishing the public keys other
+// services need to verify access tokens
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	h.writeJSON(w, http.StatusOK, h.tokens.Keys().JWKS())
+}
+
+// errorResponse is the body of every failed request
+type errorResponse struct {
+	Error string `json:"error"`
+	// Violations lists every broken password rule when a password is rejected
+	Violations []security.Violation `json:"violations,omitempty"`
+}
+
+// Helper function to write JSON responses
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// Helper function to write error responses
+func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, errorResponse{Error: message})
+}
+
+// Helper function to log an unexpected error and hide it from the client
+func (h *Handler) writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("%s %s failed: %v", r.Method, r.URL.Path, err)
+	h.writeError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+// Helper function to decode a JSON request body; it writes the error
+// response and returns false when the body is unusable
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			h.writeError(w, http.StatusBadRequest, "Request body is not valid JSON")
+			return false
+		}
+		h.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,776 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"lab05/audit"
+	"lab05/jwtservice"
+	"lab05/mail"
+	"lab05/oidc"
+	"lab05/oidc/oidctest"
+	"lab05/security"
+	"lab05/storage"
+	"lab05/totp"
+	"lab05/userdomain"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/authz"
+)
+
+func newTestServer(t *testing.T) http.Handler {
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	require.NoError(t, err)
+	return NewHandler(storage.NewMemoryUserRepository(), security.NewPasswordService(), tokens,
+		WithMailer(&mail.MemoryMailer{})).SetupRoutes()
+}
+
+func do(t *testing.T, router http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func decodeTokens(t *testing.T, rr *httptest.ResponseRecorder) TokenResponse {
+	t.Helper()
+	var resp TokenResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotEmpty(t, resp.AccessToken)
+	require.NotEmpty(t, resp.RefreshToken)
+	return resp
+}
+
+var validRegistration = RegisterRequest{Email: "John@Example.com", Name: "John Doe", Password: "Password123"}
+
+func TestRegister(t *testing.T) {
+	router := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		body       interface{}
+		wantStatus int
+	}{
+		{"valid", validRegistration, http.StatusCreated},
+		{"duplicate email", RegisterRequest{Email: "john@example.com", Name: "Johnny", Password: "Password123"}, http.StatusConflict},
+		{"invalid email", RegisterRequest{Email: "john", Name: "John Doe", Password: "Password123"}, http.StatusBadRequest},
+		{"weak password", RegisterRequest{Email: "jane@example.com", Name: "Jane Doe", Password: "password"}, http.StatusBadRequest},
+		{"unknown field", map[string]string{"username": "john"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := do(t, router, "POST", "/auth/register", "", tt.body)
+			assert.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestRegisterHashesPassword(t *testing.T) {
+	router := newTestServer(t)
+
+	rr := do(t, router, "POST", "/auth/register", "", validRegistration)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "Password123")
+
+	resp := decodeTokens(t, rr)
+	assert.Equal(t, "john@example.com", resp.User.Email)
+	assert.Equal(t, "Bearer", resp.TokenType)
+}
+
+func TestLogin(t *testing.T) {
+	router := newTestServer(t)
+	require.Equal(t, http.StatusCreated, do(t, router, "POST", "/auth/register", "", validRegistration).Code)
+
+	tests := []struct {
+		name       string
+		body       LoginRequest
+		wantStatus int
+	}{
+		{"valid", LoginRequest{Email: "john@example.com", Password: "Password123"}, http.StatusOK},
+		{"email is case-insensitive", LoginRequest{Email: "JOHN@example.com", Password: "Password123"}, http.StatusOK},
+		{"wrong password", LoginRequest{Email: "john@example.com", Password: "Password124"}, http.StatusUnauthorized},
+		{"unknown user", LoginRequest{Email: "nobody@example.com", Password: "Password123"}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := do(t, router, "POST", "/auth/login", "", tt.body)
+			assert.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestMe(t *testing.T) {
+	router := newTestServer(t)
+	tokens := decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration))
+
+	rr := do(t, router, "GET", "/auth/me", tokens.AccessToken, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var me map[string]interface{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&me))
+	assert.Equal(t, "John Doe", me["name"])
+	assert.NotContains(t, me, "password")
+
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", "", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", "not-a-token", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", tokens.RefreshToken, nil).Code,
+		"refresh tokens must not authenticate requests")
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	router := newTestServer(t)
+	tokens := decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration))
+
+	rr := do(t, router, "POST", "/auth/refresh", "", RefreshRequest{RefreshToken: tokens.RefreshToken})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	refreshed := decodeTokens(t, rr)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+	assert.Equal(t, http.StatusUnauthorized,
+		do(t, router, "POST", "/auth/refresh", "", RefreshRequest{RefreshToken: tokens.AccessToken}).Code,
+		"access tokens must not be accepted as refresh tokens")
+
+	assert.Equal(t, http.StatusNoContent,
+		do(t, router, "POST", "/auth/logout", refreshed.AccessToken, RefreshRequest{RefreshToken: refreshed.RefreshToken}).Code)
+	rr = do(t, router, "GET", "/auth/me", refreshed.AccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "logout must revoke the access token")
+	assert.Contains(t, rr.Body.String(), "revoked")
+	assert.Equal(t, http.StatusUnauthorized,
+		do(t, router, "POST", "/auth/refresh", "", RefreshRequest{RefreshToken: refreshed.RefreshToken}).Code,
+		"logout must revoke the refresh token")
+	assert.Equal(t, http.StatusUnauthorized,
+		do(t, router, "POST", "/auth/logout", "", RefreshRequest{RefreshToken: "bogus"}).Code)
+}
+
+func TestRefreshReuse(t *testing.T) {
+	router := newTestServer(t)
+	tokens := decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration))
+
+	refreshed := decodeTokens(t, do(t, router, "POST", "/auth/refresh", "", RefreshRequest{RefreshToken: tokens.RefreshToken}))
+
+	rr := do(t, router, "POST", "/auth/refresh", "", RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "already used")
+
+	assert.Equal(t, http.StatusUnauthorized,
+		do(t, router, "POST", "/auth/refresh", "", RefreshRequest{RefreshToken: refreshed.RefreshToken}).Code,
+		"reuse must revoke the whole family")
+}
+
+func TestJWKS(t *testing.T) {
+	key, err := jwtservice.GenerateKey("", jwtservice.AlgEdDSA)
+	require.NoError(t, err)
+	ring, err := jwtservice.NewKeyRing(key)
+	require.NoError(t, err)
+	tokens, err := jwtservice.NewJWTServiceWithKeys(ring)
+	require.NoError(t, err)
+	router := NewHandler(storage.NewMemoryUserRepository(), security.NewPasswordService(), tokens).SetupRoutes()
+
+	rr := do(t, router, "GET", "/.well-known/jwks.json", "", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var set jwtservice.JWKS
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&set))
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, key.ID, set.Keys[0].Kid)
+	assert.Equal(t, "OKP", set.Keys[0].Kty)
+
+	// A service holding only the JWKS can verify tokens from this API
+	resp := decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration))
+	verifierKeys, err := set.KeyRing()
+	require.NoError(t, err)
+	verifier, err := jwtservice.NewJWTServiceWithKeys(verifierKeys)
+	require.NoError(t, err)
+	_, err = verifier.ValidateToken(resp.AccessToken)
+	assert.NoError(t, err)
+}
+
+func TestSetUserRoles(t *testing.T) {
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	require.NoError(t, err)
+	users := storage.NewMemoryUserRepository()
+	passwords := security.NewPasswordService()
+	router := NewHandler(users, passwords, tokens).SetupRoutes()
+
+	admin, err := userdomain.NewUser("admin@example.com", "Admin", "Password123")
+	require.NoError(t, err)
+	admin.Password, err = passwords.HashPassword("Password123")
+	require.NoError(t, err)
+	admin.Roles = []string{authz.RoleAdmin}
+	admin.EmailVerified = true
+	require.NoError(t, users.Create(context.Background(), admin))
+	adminTokens := decodeTokens(t, do(t, router, "POST", "/auth/login", "", LoginRequest{Email: admin.Email, Password: "Password123"}))
+
+	john := decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration))
+	assert.Equal(t, []string{userdomain.DefaultRole}, john.User.Roles)
+	path := fmt.Sprintf("/admin/users/%d/roles", john.User.ID)
+
+	rr := do(t, router, "PUT", path, john.AccessToken, SetRolesRequest{Roles: []string{authz.RoleAdmin}})
+	assert.Equal(t, http.StatusForbidden, rr.Code, "users must not grant themselves roles")
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "PUT", path, "", SetRolesRequest{Roles: []string{authz.RoleEditor}}).Code)
+	assert.Equal(t, http.StatusBadRequest, do(t, router, "PUT", path, adminTokens.AccessToken, SetRolesRequest{Roles: []string{"owner"}}).Code)
+	assert.Equal(t, http.StatusNotFound, do(t, router, "PUT", "/admin/users/999/roles", adminTokens.AccessToken, SetRolesRequest{Roles: []string{authz.RoleEditor}}).Code)
+
+	rr = do(t, router, "PUT", path, adminTokens.AccessToken, SetRolesRequest{Roles: []string{authz.RoleUser, authz.RoleEditor}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	stored, err := users.GetByID(context.Background(), john.User.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{authz.RoleUser, authz.RoleEditor}, stored.Roles)
+
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", john.AccessToken, nil).Code,
+		"tokens issued with the old roles must be revoked")
+	assert.Equal(t, http.StatusUnauthorized,
+		do(t, router, "POST", "/auth/refresh", "", RefreshRequest{RefreshToken: john.RefreshToken}).Code)
+
+	relogin := decodeTokens(t, do(t, router, "POST", "/auth/login", "", LoginRequest{Email: validRegistration.Email, Password: validRegistration.Password}))
+	assert.Equal(t, http.StatusOK, do(t, router, "GET", "/auth/me", relogin.AccessToken, nil).Code,
+		"a login right after the change must work")
+}
+
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	require.NoError(t, err)
+	users := storage.NewMemoryUserRepository()
+	argon2id := security.NewArgon2idHasher(security.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	router := NewHandler(users, security.NewPasswordService(security.WithHasher(argon2id)), tokens).SetupRoutes()
+
+	user, err := userdomain.NewUser("john@example.com", "John Doe", "Password123")
+	require.NoError(t, err)
+	user.Password, err = security.NewBcryptHasher(4).Hash("Password123")
+	require.NoError(t, err)
+	require.NoError(t, users.Create(context.Background(), user))
+
+	rr := do(t, router, "POST", "/auth/login", "", LoginRequest{Email: user.Email, Password: "wrong"})
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	stored, err := users.GetByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.Password, stored.Password, "a failed login must not rehash")
+
+	rr = do(t, router, "POST", "/auth/login", "", LoginRequest{Email: user.Email, Password: "Password123"})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	stored, err = users.GetByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.True(t, argon2id.Identifies(stored.Password), "bcrypt hash should be upgraded to Argon2id")
+
+	rr = do(t, router, "POST", "/auth/login", "", LoginRequest{Email: user.Email, Password: "Password123"})
+	assert.Equal(t, http.StatusOK, rr.Code, "the upgraded hash must verify")
+}
+
+func TestRegisterReportsPasswordViolations(t *testing.T) {
+	router := newTestServer(t)
+
+	rr := do(t, router, "POST", "/auth/register", "", RegisterRequest{Email: "john@example.com", Name: "John Doe", Password: "john"})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	var body errorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	var rules []string
+	for _, v := range body.Violations {
+		rules = append(rules, v.Rule)
+	}
+	assert.Equal(t, []string{security.RuleMinLength, security.RuleUpper, security.RuleDigit, security.RulePersonalInfo}, rules)
+}
+
+func TestCheckPassword(t *testing.T) {
+	router := newTestServer(t)
+
+	rr := do(t, router, "POST", "/auth/password/check", "", PasswordCheckRequest{Password: "Welcome123"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp PasswordCheckResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.False(t, resp.Valid)
+	require.Len(t, resp.Violations, 1)
+	assert.Equal(t, security.RuleCommon, resp.Violations[0].Rule)
+	assert.Equal(t, 8, resp.Policy.MinLength)
+
+	rr = do(t, router, "POST", "/auth/password/check", "", PasswordCheckRequest{Password: "Tr0ub4dor&3"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"valid":true,"violations":[]`)
+}
+
+func TestLoginLockout(t *testing.T) {
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	require.NoError(t, err)
+	recorder := &audit.MemoryRecorder{}
+	config := security.DefaultThrottleConfig()
+	config.FreeAttempts = 5
+	config.MaxAccountFailures = 3
+	router := NewHandler(storage.NewMemoryUserRepository(), security.NewPasswordService(), tokens,
+		WithLoginThrottle(security.NewLoginThrottle(config)), WithAuditRecorder(recorder)).SetupRoutes()
+	require.Equal(t, http.StatusCreated, do(t, router, "POST", "/auth/register", "", validRegistration).Code)
+
+	wrong := LoginRequest{Email: "john@example.com", Password: "Password124"}
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusUnauthorized, do(t, router, "POST", "/auth/login", "", wrong).Code)
+	}
+
+	rr := do(t, router, "POST", "/auth/login", "", LoginRequest{Email: "john@example.com", Password: "Password123"})
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "a locked account must reject even the right password")
+	assert.Equal(t, "900", rr.Header().Get("Retry-After"))
+
+	rr = do(t, router, "POST", "/auth/login", "", LoginRequest{Email: "nobody@example.com", Password: "Password123"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "other accounts must not be locked")
+
+	var types []string
+	for _, event := range recorder.Events() {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{
+		audit.LoginFailed, audit.LoginFailed, audit.LoginFailed, audit.AccountLocked,
+		audit.LoginLocked, audit.LoginFailed,
+	}, types)
+	events := recorder.Events()
+	assert.Positive(t, events[0].UserID)
+	assert.Equal(t, "192.0.2.1", events[0].IP)
+	assert.Equal(t, "unknown account", events[5].Reason)
+	assert.Zero(t, events[5].UserID)
+}
+
+// mailedToken returns the token from the link in the last message sent to
+// the given address
+func mailedToken(t *testing.T, mailer *mail.MemoryMailer, to string) string {
+	t.Helper()
+	messages := mailer.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To != to {
+			continue
+		}
+		match := regexp.MustCompile(`token=([A-Za-z0-9_-]+)`).FindStringSubmatch(messages[i].Text)
+		require.NotNil(t, match, messages[i].Text)
+		return match[1]
+	}
+	t.Fatalf("No mail sent to %s", to)
+	return ""
+}
+
+// waitForMail waits until n messages have been sent, for mail that goes out
+// after the response
+func waitForMail(t *testing.T, mailer *mail.MemoryMailer, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(mailer.Messages()) >= n }, time.Second, 5*time.Millisecond)
+	require.Len(t, mailer.Messages(), n)
+}
+
+func newMailServer(t *testing.T) (http.Handler, *mail.MemoryMailer) {
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	require.NoError(t, err)
+	mailer := &mail.MemoryMailer{}
+	router := NewHandler(storage.NewMemoryUserRepository(), security.NewPasswordService(), tokens,
+		WithMailer(mailer), WithAppURL("https://app.example.com/")).SetupRoutes()
+	return router, mailer
+}
+
+func TestVerifyEmail(t *testing.T) {
+	router, mailer := newMailServer(t)
+	tokens := decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration))
+	assert.False(t, tokens.User.EmailVerified)
+
+	messages := mailer.Messages()
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Text, "https://app.example.com/verify-email?token=")
+	first := mailedToken(t, mailer, "john@example.com")
+
+	rr := do(t, router, "POST", "/auth/verify-email/resend", tokens.AccessToken, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	token := mailedToken(t, mailer, "john@example.com")
+	assert.Equal(t, http.StatusBadRequest, do(t, router, "POST", "/auth/verify-email", "", TokenRequest{Token: first}).Code,
+		"resending must invalidate the previous link")
+
+	rr = do(t, router, "POST", "/auth/verify-email", "", TokenRequest{Token: token})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"email_verified":true`)
+	assert.Equal(t, http.StatusBadRequest, do(t, router, "POST", "/auth/verify-email", "", TokenRequest{Token: token}).Code,
+		"links must be single-use")
+
+	rr = do(t, router, "GET", "/auth/me", tokens.AccessToken, nil)
+	assert.Contains(t, rr.Body.String(), `"email_verified":true`)
+	assert.Equal(t, http.StatusConflict, do(t, router, "POST", "/auth/verify-email/resend", tokens.AccessToken, nil).Code)
+}
+
+func TestUnverifiedAccountsAreRestricted(t *testing.T) {
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	require.NoError(t, err)
+	users := storage.NewMemoryUserRepository()
+	passwords := security.NewPasswordService()
+	router := NewHandler(users, passwords, tokens, WithMailer(&mail.MemoryMailer{})).SetupRoutes()
+
+	admin, err := userdomain.NewUser("admin@example.com", "Admin", "Password123")
+	require.NoError(t, err)
+	admin.Password, err = passwords.HashPassword("Password123")
+	require.NoError(t, err)
+	admin.Roles = []string{authz.RoleAdmin}
+	require.NoError(t, users.Create(context.Background(), admin))
+	adminTokens := decodeTokens(t, do(t, router, "POST", "/auth/login", "", LoginRequest{Email: admin.Email, Password: "Password123"}))
+
+	path := fmt.Sprintf("/admin/users/%d/roles", admin.ID)
+	rr := do(t, router, "PUT", path, adminTokens.AccessToken, SetRolesRequest{Roles: []string{authz.RoleAdmin}})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "not verified")
+}
+
+func TestPasswordReset(t *testing.T) {
+	router, mailer := newMailServer(t)
+	tokens := decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration))
+
+	rr := do(t, router, "POST", "/auth/password/forgot", "", ForgotPasswordRequest{Email: "nobody@example.com"})
+	assert.Equal(t, http.StatusAccepted, rr.Code, "unknown emails must look the same as known ones")
+	assert.Len(t, mailer.Messages(), 1, "no mail should be sent for unknown emails")
+
+	rr = do(t, router, "POST", "/auth/password/forgot", "", ForgotPasswordRequest{Email: "JOHN@example.com"})
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	waitForMail(t, mailer, 2)
+	token := mailedToken(t, mailer, "john@example.com")
+	assert.Contains(t, mailer.Messages()[1].Subject, "Reset")
+
+	assert.Equal(t, http.StatusBadRequest,
+		do(t, router, "POST", "/auth/verify-email", "", TokenRequest{Token: token}).Code,
+		"reset tokens must not verify emails")
+
+	rr = do(t, router, "POST", "/auth/password/reset", "", ResetPasswordRequest{Token: token, Password: "weak"})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "violations")
+
+	rr = do(t, router, "POST", "/auth/password/reset", "", ResetPasswordRequest{Token: token, Password: "NewPassword456"})
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Equal(t, http.StatusBadRequest,
+		do(t, router, "POST", "/auth/password/reset", "", ResetPasswordRequest{Token: token, Password: "OtherPassword789"}).Code,
+		"reset links must be single-use")
+
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", tokens.AccessToken, nil).Code,
+		"a reset must sign out existing sessions")
+	assert.Equal(t, http.StatusUnauthorized,
+		do(t, router, "POST", "/auth/login", "", LoginRequest{Email: "john@example.com", Password: "Password123"}).Code)
+	rr = do(t, router, "POST", "/auth/login", "", LoginRequest{Email: "john@example.com", Password: "NewPassword456"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	relogin := decodeTokens(t, rr)
+	assert.True(t, relogin.User.EmailVerified, "a reset proves control of the address")
+	assert.Equal(t, http.StatusOK, do(t, router, "GET", "/auth/me", relogin.AccessToken, nil).Code,
+		"a login right after the reset must work")
+
+	// Mails per address are capped, but the answer stays the same, so asking
+	// for links in someone's name cannot block their own request
+	require.Equal(t, http.StatusAccepted, do(t, router, "POST", "/auth/password/forgot", "", ForgotPasswordRequest{Email: "john@example.com"}).Code)
+	waitForMail(t, mailer, 3)
+	require.Equal(t, http.StatusAccepted, do(t, router, "POST", "/auth/password/forgot", "", ForgotPasswordRequest{Email: "john@example.com"}).Code)
+	assert.Never(t, func() bool { return len(mailer.Messages()) > 3 }, 100*time.Millisecond, 5*time.Millisecond,
+		"a third link within a minute must not be mailed")
+
+	// Requests are limited per IP: ten go through freely, the eleventh
+	// starts the backoff
+	for i := 0; i < 7; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		require.Equal(t, http.StatusAccepted, do(t, router, "POST", "/auth/password/forgot", "", ForgotPasswordRequest{Email: email}).Code)
+	}
+	rr = do(t, router, "POST", "/auth/password/forgot", "", ForgotPasswordRequest{Email: "john@example.com"})
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+}
+
+func TestTOTPLogin(t *testing.T) {
+	router, mailer := newMailServer(t)
+	config := totp.DefaultConfig("Lab05")
+	login := LoginRequest{Email: validRegistration.Email, Password: validRegistration.Password}
+	access := decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration)).AccessToken
+
+	rr := do(t, router, "POST", "/auth/mfa/totp/setup", access, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "unverified accounts must not set up MFA")
+	token := mailedToken(t, mailer, "john@example.com")
+	require.Equal(t, http.StatusOK, do(t, router, "POST", "/auth/verify-email", "", TokenRequest{Token: token}).Code)
+
+	rr = do(t, router, "POST", "/auth/mfa/totp/setup", access, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var setup TOTPSetupResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&setup))
+	assert.Contains(t, setup.URI, "otpauth://totp/Lab05:john@example.com?")
+
+	rr = do(t, router, "POST", "/auth/mfa/totp/enable", access, CodeRequest{Code: "000000x"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	now := time.Now()
+	code, err := config.Code(setup.Secret, now)
+	require.NoError(t, err)
+	rr = do(t, router, "POST", "/auth/mfa/totp/enable", access, CodeRequest{Code: code})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var recovery RecoveryCodesResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&recovery))
+	require.Len(t, recovery.RecoveryCodes, 10)
+
+	challenge := func() string {
+		t.Helper()
+		rr := do(t, router, "POST", "/auth/login", "", login)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var resp MFAChallengeResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.True(t, resp.MFARequired)
+		return resp.MFAToken
+	}
+
+	pending := challenge()
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", pending, nil).Code,
+		"the pending token must not work as an access token")
+	rr = do(t, router, "POST", "/auth/mfa/verify", "", MFAVerifyRequest{MFAToken: pending, Code: code})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "a code must not be accepted twice")
+
+	next, err := config.Code(setup.Secret, now.Add(config.Period))
+	require.NoError(t, err)
+	rr = do(t, router, "POST", "/auth/mfa/verify", "", MFAVerifyRequest{MFAToken: pending, Code: next})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	decodeTokens(t, rr)
+	rr = do(t, router, "POST", "/auth/mfa/verify", "", MFAVerifyRequest{MFAToken: pending, Code: recovery.RecoveryCodes[0]})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "the pending token is single use")
+
+	rr = do(t, router, "POST", "/auth/mfa/verify", "", MFAVerifyRequest{MFAToken: challenge(), Code: recovery.RecoveryCodes[0]})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = do(t, router, "POST", "/auth/mfa/verify", "", MFAVerifyRequest{MFAToken: challenge(), Code: recovery.RecoveryCodes[0]})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "recovery codes are single use")
+
+	rr = do(t, router, "POST", "/auth/mfa/totp/disable", access, CodeRequest{Code: "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = do(t, router, "POST", "/auth/mfa/totp/disable", access, CodeRequest{Code: recovery.RecoveryCodes[1]})
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	decodeTokens(t, do(t, router, "POST", "/auth/login", "", login))
+}
+
+func TestConcurrentLoginsAreThrottled(t *testing.T) {
+	router := newTestServer(t)
+	require.Equal(t, http.StatusCreated, do(t, router, "POST", "/auth/register", "", validRegistration).Code)
+
+	wrong := LoginRequest{Email: validRegistration.Email, Password: "Password124"}
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	codes := map[int]int{}
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code := do(t, router, "POST", "/auth/login", "", wrong).Code
+			mutex.Lock()
+			codes[code]++
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	config := security.DefaultThrottleConfig()
+	assert.LessOrEqual(t, codes[http.StatusUnauthorized], config.MaxAccountFailures,
+		"no more passwords may be checked than the lockout allows")
+	assert.Equal(t, 40, codes[http.StatusUnauthorized]+codes[http.StatusTooManyRequests], codes)
+}
+
+func TestMFAFailuresAreThrottled(t *testing.T) {
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	require.NoError(t, err)
+	recorder := &audit.MemoryRecorder{}
+	users := storage.NewMemoryUserRepository()
+	config := security.DefaultThrottleConfig()
+	config.MaxAccountFailures, config.FreeAttempts = 3, 3
+	router := NewHandler(users, security.NewPasswordService(), tokens,
+		WithMailer(&mail.MemoryMailer{}),
+		WithAuditRecorder(recorder),
+		WithLoginThrottle(security.NewLoginThrottle(config))).SetupRoutes()
+
+	decodeTokens(t, do(t, router, "POST", "/auth/register", "", validRegistration))
+	user, err := users.GetByEmail(context.Background(), validRegistration.Email)
+	require.NoError(t, err)
+	user.TOTPSecret, err = totp.GenerateSecret()
+	require.NoError(t, err)
+	user.MFAEnabled = true
+	require.NoError(t, users.Update(context.Background(), user))
+
+	rr := do(t, router, "POST", "/auth/login", "", LoginRequest{Email: validRegistration.Email, Password: validRegistration.Password})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var challenge MFAChallengeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&challenge))
+
+	for i := 0; i < 3; i++ {
+		rr = do(t, router, "POST", "/auth/mfa/verify", "", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: "123456x"})
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+	code, err := totp.DefaultConfig("Lab05").Code(user.TOTPSecret, time.Now())
+	require.NoError(t, err)
+	rr = do(t, router, "POST", "/auth/mfa/verify", "", MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: code})
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "the account is locked even for the right code")
+	rr = do(t, router, "POST", "/auth/login", "", LoginRequest{Email: validRegistration.Email, Password: validRegistration.Password})
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "a correct password must not reset MFA failures")
+
+	var types []string
+	for _, event := range recorder.Events() {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{audit.MFAFailed, audit.MFAFailed, audit.MFAFailed, audit.AccountLocked, audit.LoginLocked}, types)
+}
+
+func TestSessions(t *testing.T) {
+	router := newTestServer(t)
+	signIn := func(path, userAgent string, body interface{}) TokenResponse {
+		t.Helper()
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		req := httptest.NewRequest("POST", path, &buf)
+		req.Header.Set("User-Agent", userAgent)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return decodeTokens(t, rr)
+	}
+	phone := signIn("/auth/register", "Phone", validRegistration)
+	laptop := signIn("/auth/login", "Laptop", LoginRequest{Email: validRegistration.Email, Password: validRegistration.Password})
+
+	rr := do(t, router, "GET", "/auth/sessions", laptop.AccessToken, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var sessions []SessionResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&sessions))
+	require.Len(t, sessions, 2)
+	byAgent := map[string]SessionResponse{}
+	for _, session := range sessions {
+		byAgent[session.UserAgent] = session
+	}
+	assert.True(t, byAgent["Laptop"].Current)
+	assert.False(t, byAgent["Phone"].Current)
+	assert.Equal(t, "192.0.2.1", byAgent["Phone"].IP)
+
+	assert.Equal(t, http.StatusNotFound, do(t, router, "DELETE", "/auth/sessions/unknown", laptop.AccessToken, nil).Code)
+
+	rr = do(t, router, "POST", "/auth/sessions/revoke-others", laptop.AccessToken, nil)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", phone.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "POST", "/auth/refresh", "", RefreshRequest{RefreshToken: phone.RefreshToken}).Code)
+	assert.Equal(t, http.StatusOK, do(t, router, "GET", "/auth/me", laptop.AccessToken, nil).Code)
+
+	rr = do(t, router, "DELETE", "/auth/sessions/"+byAgent["Laptop"].ID, laptop.AccessToken, nil)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", laptop.AccessToken, nil).Code)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := oidctest.NewProvider()
+	defer provider.Close()
+	client, err := oidc.NewClient(context.Background(), oidc.Config{
+		Issuer:       provider.Issuer,
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  "http://localhost:8080/auth/oidc/mock/callback",
+	})
+	require.NoError(t, err)
+
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	require.NoError(t, err)
+	users := storage.NewMemoryUserRepository()
+	recorder := &audit.MemoryRecorder{}
+	router := NewHandler(users, security.NewPasswordService(), tokens,
+		WithMailer(&mail.MemoryMailer{}),
+		WithAuditRecorder(recorder),
+		WithOIDCProvider("mock", client)).SetupRoutes()
+
+	// startLogin follows the redirects a browser would and returns the
+	// login response, which sets the state cookie, and the callback URL
+	startLogin := func() (*httptest.ResponseRecorder, string) {
+		t.Helper()
+		rr := do(t, router, "GET", "/auth/oidc/mock/login", "", nil)
+		require.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+		callback, err := provider.Authorize(rr.Header().Get("Location"))
+		require.NoError(t, err)
+		return rr, callback.RequestURI()
+	}
+	// callback opens the callback URL in the browser that got login
+	callback := func(login *httptest.ResponseRecorder, uri string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", uri, nil)
+		for _, cookie := range login.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	signIn := func() *httptest.ResponseRecorder {
+		t.Helper()
+		return callback(startLogin())
+	}
+
+	login, uri := startLogin()
+	cookies := login.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	rr := callback(login, uri)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	alice := decodeTokens(t, rr)
+	assert.Equal(t, "alice@example.com", alice.User.Email)
+	assert.Equal(t, "Alice Example", alice.User.Name)
+	assert.True(t, alice.User.EmailVerified)
+	assert.Equal(t, http.StatusBadRequest, callback(login, uri).Code, "a state works only once")
+
+	// Login CSRF: a callback URL from someone else's login must not work in
+	// a browser that did not start it
+	_, attackerURI := startLogin()
+	victim, _ := startLogin()
+	assert.Equal(t, http.StatusBadRequest, callback(victim, attackerURI).Code, "the state cookie must match")
+	assert.Equal(t, http.StatusBadRequest, do(t, router, "GET", attackerURI, "", nil).Code, "the state cookie is required")
+
+	rr = signIn()
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, alice.User.ID, decodeTokens(t, rr).User.ID, "the linked account signs in again")
+	rr = do(t, router, "POST", "/auth/login", "", LoginRequest{Email: "alice@example.com", Password: ""})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "accounts created by the provider have no password")
+
+	// An unverified local account with the same email is taken over by the
+	// verified owner; the squatter's password and sessions stop working
+	bob := decodeTokens(t, do(t, router, "POST", "/auth/register", "",
+		RegisterRequest{Email: "bob@example.com", Name: "Bob", Password: "Password123"}))
+	squatter, err := users.GetByID(context.Background(), bob.User.ID)
+	require.NoError(t, err)
+	squatter.MFAEnabled, squatter.TOTPSecret = true, "JBSWY3DPEHPK3PXP"
+	squatter.Roles = []string{authz.RoleAdmin}
+	require.NoError(t, users.Update(context.Background(), squatter))
+	provider.SetUser(oidctest.User{Subject: "bob", Email: "Bob@Example.com", EmailVerified: true, Name: "Bob"})
+	rr = signIn()
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	linked := decodeTokens(t, rr)
+	assert.Equal(t, bob.User.ID, linked.User.ID)
+	assert.NotEmpty(t, linked.AccessToken, "the squatter's second factor must not apply to the owner")
+	assert.Equal(t, []string{userdomain.DefaultRole}, linked.User.Roles)
+	owner, err := users.GetByID(context.Background(), bob.User.ID)
+	require.NoError(t, err)
+	assert.False(t, owner.MFAEnabled)
+	assert.Empty(t, owner.TOTPSecret)
+	assert.Equal(t, http.StatusUnauthorized, do(t, router, "GET", "/auth/me", bob.AccessToken, nil).Code)
+	assert.Equal(t, http.StatusOK, do(t, router, "GET", "/auth/me", linked.AccessToken, nil).Code)
+	rr = do(t, router, "POST", "/auth/login", "", LoginRequest{Email: "bob@example.com", Password: "Password123"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	provider.SetUser(oidctest.User{Subject: "carol", Email: "carol@example.com", Name: "Carol"})
+	rr = signIn()
+	assert.Equal(t, http.StatusForbidden, rr.Code, "unverified provider emails must not create or link accounts")
+	_, err = users.GetByEmail(context.Background(), "carol@example.com")
+	assert.ErrorIs(t, err, userdomain.ErrUserNotFound)
+
+	provider.IDTokenHook = func(claims *oidc.IDTokenClaims) { claims.Audience = jwt.ClaimStrings{"someone-else"} }
+	provider.SetUser(oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+	rr = signIn()
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	assert.Equal(t, http.StatusNotFound, do(t, router, "GET", "/auth/oidc/unknown/login", "", nil).Code)
+	var linkedEvents int
+	for _, event := range recorder.Events() {
+		if event.Type == audit.IdentityLinked {
+			linkedEvents++
+		}
+	}
+	assert.Equal(t, 2, linkedEvents)
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lab05/audit"
+	"lab05/jwtservice"
+	"lab05/security"
+	"lab05/totp"
+	"lab05/userdomain"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time
+const recoveryCodeCount = 10
+
+// CodeRequest is the body of the MFA endpoints that need a TOTP or
+// recovery code
+type CodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFAVerifyRequest is the body of POST /auth/mfa/verify
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token"`
+	// Code is a TOTP code or a recovery code
+	Code string `json:"code"`
+}
+
+// MFAChallengeResponse is returned by login instead of tokens when the
+// account has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// TOTPSetupResponse carries the secret to add to an authenticator app
+type TOTPSetupResponse struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI to show as a QR code
+	URI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse carries new recovery codes. They are shown once;
+// only their hashes are stored.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// SetupTOTP handles POST /auth/mfa/totp/setup. It generates a new secret
+// that only takes effect once EnableTOTP confirms a code from it.
+func (h *Handler) SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.MFAEnabled {
+		h.writeError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	user.TOTPSecret = secret
+	user.UpdatedAt = time.Now()
+	if err := h.users.Update(r.Context(), user); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, TOTPSetupResponse{Secret: secret, URI: h.totp.URI(secret, user.Email)})
+}
+
+// EnableTOTP handles POST /auth/mfa/totp/enable, turning on two-factor
+// authentication once the user proves their app produces valid codes
+func (h *Handler) EnableTOTP(w http.ResponseWriter, r *http.Request) {
+	var req CodeRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.MFAEnabled {
+		h.writeError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if user.TOTPSecret == "" {
+		h.writeError(w, http.StatusBadRequest, "Call /auth/mfa/totp/setup first")
+		return
+	}
+
+	step, valid, err := h.totp.Verify(user.TOTPSecret, req.Code, time.Now())
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if !valid {
+		h.writeError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, err := h.newRecoveryCodes(user)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	user.MFAEnabled = true
+	user.TOTPLastStep = step
+	if err := h.users.Update(r.Context(), user); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Event{Type: audit.MFAEnabled, UserID: user.ID, Account: user.Email, IP: clientIP(r), Time: time.Now()})
+
+	h.writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP handles POST /auth/mfa/totp/disable. It takes a TOTP or
+// recovery code so a stolen access token alone cannot turn MFA off.
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var req CodeRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	user, ok := h.currentMFAUser(w, r)
+	if !ok || !h.checkSecondFactor(w, r, user, req.Code) {
+		return
+	}
+
+	user.DisableMFA()
+	if err := h.users.Update(r.Context(), user); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	h.audit.Record(r.Context(), audit.Event{Type: audit.MFADisabled, UserID: user.ID, Account: user.Email, IP: clientIP(r), Time: time.Now()})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes handles POST /auth/mfa/recovery-codes, replacing
+// all recovery codes with new ones
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req CodeRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	user, ok := h.currentMFAUser(w, r)
+	if !ok || !h.checkSecondFactor(w, r, user, req.Code) {
+		return
+	}
+
+	codes, err := h.newRecoveryCodes(user)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if err := h.users.Update(r.Context(), user); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyMFA handles POST /auth/mfa/verify, exchanging the token from login
+// and a second factor for a token pair
+func (h *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req MFAVerifyRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	claims, err := h.tokens.ValidateMFAPendingToken(r.Context(), req.MFAToken)
+	if err != nil {
+		if isTokenError(err) || errors.Is(err, jwtservice.ErrTokenExpired) || errors.Is(err, jwtservice.ErrTokenRevoked) {
+			h.writeError(w, http.StatusUnauthorized, "Invalid or expired MFA token; please sign in again")
+			return
+		}
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), claims.UserID)
+	if errors.Is(err, userdomain.ErrUserNotFound) {
+		h.writeError(w, http.StatusUnauthorized, "Invalid or expired MFA token; please sign in again")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	// If MFA was turned off since login, the password alone suffices
+	if user.MFAEnabled && !h.checkSecondFactor(w, r, user, req.Code) {
+		return
+	}
+
+	// The pending token is single use
+	if err := h.tokens.RevokeToken(r.Context(), claims); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if user.MFAEnabled {
+		if err := h.users.Update(r.Context(), user); err != nil {
+			h.writeInternalError(w, r, err)
+			return
+		}
+	}
+	if err := h.throttle.Success(r.Context(), user.Email); err != nil {
+		log.Printf("Failed to reset login attempts of user %d: %v", user.ID, err)
+	}
+
+	h.issueTokens(w, r, http.StatusOK, user)
+}
+
+// writeMFAChallenge answers a correct password on an account with MFA
+// enabled
+func (h *Handler) writeMFAChallenge(w http.ResponseWriter, r *http.Request, user *userdomain.User) {
+	token, err := h.tokens.GenerateMFAPendingToken(identityOf(user))
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int(jwtservice.DefaultMFAPendingTTL.Seconds()),
+	})
+}
+
+// checkSecondFactor verifies a TOTP or recovery code of user, writing the
+// response if it is rejected. Accepted codes are consumed on user, which the
+// caller must save. Failures count towards the login throttle of the
+// account, so codes cannot be guessed faster than passwords.
+func (h *Handler) checkSecondFactor(w http.ResponseWriter, r *http.Request, user *userdomain.User, code string) bool {
+	ip := clientIP(r)
+	if err := h.throttle.Allow(r.Context(), user.Email, ip); err != nil {
+		var throttled *security.ThrottledError
+		if !errors.As(err, &throttled) {
+			h.writeInternalError(w, r, err)
+			return false
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		h.writeError(w, http.StatusTooManyRequests, "Too many failed attempts; try again later")
+		return false
+	}
+
+	step, valid, err := h.totp.Verify(user.TOTPSecret, code, time.Now())
+	if err != nil {
+		h.releaseAttempt(r, user.Email, ip)
+		h.writeInternalError(w, r, err)
+		return false
+	}
+	// A code from an already used step is a replay
+	if valid && step > user.TOTPLastStep {
+		h.releaseAttempt(r, user.Email, ip)
+		user.TOTPLastStep = step
+		return true
+	}
+	if i := totp.MatchRecoveryCode(user.RecoveryCodes, code); i >= 0 {
+		h.releaseAttempt(r, user.Email, ip)
+		user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+		h.audit.Record(r.Context(), audit.Event{Type: audit.RecoveryCodeUsed, UserID: user.ID, Account: user.Email, IP: ip, Time: time.Now()})
+		return true
+	}
+
+	event := audit.Event{Type: audit.MFAFailed, UserID: user.ID, Account: user.Email, IP: ip, Reason: "wrong code", Time: time.Now()}
+	h.audit.Record(r.Context(), event)
+	locked, err := h.throttle.Failure(r.Context(), user.Email, ip)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return false
+	}
+	if locked {
+		event.Type, event.Reason = audit.AccountLocked, "too many failed attempts"
+		h.audit.Record(r.Context(), event)
+	}
+	h.writeError(w, http.StatusUnauthorized, "Invalid code")
+	return false
+}
+
+// newRecoveryCodes replaces the recovery codes of user and returns them in
+// plain text
+func (h *Handler) newRecoveryCodes(user *userdomain.User) ([]string, error) {
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	user.RecoveryCodes = hashes
+	user.UpdatedAt = time.Now()
+	return codes, nil
+}
+
+// currentUser loads the user of the request's access token
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*userdomain.User, bool) {
+	claims, _ := ClaimsFromContext(r.Context())
+	user, err := h.users.GetByID(r.Context(), claims.UserID)
+	if errors.Is(err, userdomain.ErrUserNotFound) {
+		h.writeError(w, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// currentMFAUser is currentUser for endpoints that need MFA to be enabled
+func (h *Handler) currentMFAUser(w http.ResponseWriter, r *http.Request) (*userdomain.User, bool) {
+	user, ok := h.currentUser(w, r)
+	if ok && !user.MFAEnabled {
+		h.writeError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return nil, false
+	}
+	return user, ok
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"lab05/audit"
+	"lab05/oidc"
+	"lab05/userdomain"
+
+	"github.com/gorilla/mux"
+)
+
+// oidcStateCookie holds the state of the login started by this browser. The
+// callback requires it, so a callback URL made for someone else's login
+// cannot sign the browser in to that account.
+const oidcStateCookie = "oidc_state"
+
+// errUnverifiedEmail means the provider did not vouch for the email, so it
+// cannot be used to find or create an account
+var errUnverifiedEmail = errors.New("email not verified by the provider")
+
+// OIDCLogin handles GET /auth/oidc/{provider}/login by redirecting the
+// browser to the provider
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	client, ok := h.oidc[name]
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	req, err := oidc.NewAuthRequest(name, oidc.DefaultStateTTL)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if err := h.oidcStates.Save(r.Context(), req); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	http.SetCookie(w, h.stateCookie(r, name, req.State, int(oidc.DefaultStateTTL.Seconds())))
+	http.Redirect(w, r, client.AuthCodeURL(req.State, req.Nonce, oidc.S256(req.Verifier)), http.StatusFound)
+}
+
+// OIDCCallback handles GET /auth/oidc/{provider}/callback, where the
+// provider sends the browser back. It signs in the user linked to the
+// provider account, linking or creating one by verified email first.
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	client, ok := h.oidc[name]
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+	query := r.URL.Query()
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		h.writeError(w, http.StatusBadRequest, "Invalid or expired login; please start again")
+		return
+	}
+	http.SetCookie(w, h.stateCookie(r, name, "", -1))
+
+	// The state is consumed even if the provider reports an error
+	req, err := h.oidcStates.Take(r.Context(), query.Get("state"))
+	if errors.Is(err, oidc.ErrStateNotFound) || (err == nil && req.Provider != name) {
+		h.writeError(w, http.StatusBadRequest, "Invalid or expired login; please start again")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		h.writeError(w, http.StatusUnauthorized, "The identity provider refused the sign-in: "+providerErr)
+		return
+	}
+
+	token, err := client.Exchange(r.Context(), query.Get("code"), req.Verifier)
+	if err != nil {
+		log.Printf("OIDC code exchange with %s failed: %v", name, err)
+		h.writeError(w, http.StatusBadGateway, "Could not complete the sign-in with the identity provider")
+		return
+	}
+	claims, err := client.VerifyIDToken(r.Context(), token.IDToken, req.Nonce)
+	if err != nil {
+		log.Printf("OIDC ID token from %s rejected: %v", name, err)
+		if errors.Is(err, oidc.ErrInvalidIDToken) {
+			h.writeError(w, http.StatusUnauthorized, "Invalid ID token")
+			return
+		}
+		h.writeError(w, http.StatusBadGateway, "Could not complete the sign-in with the identity provider")
+		return
+	}
+
+	user, err := h.oidcUser(r, name, claims)
+	if errors.Is(err, errUnverifiedEmail) {
+		h.writeError(w, http.StatusForbidden, "The identity provider has not verified your email address")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+
+	if user.MFAEnabled {
+		h.writeMFAChallenge(w, r, user)
+		return
+	}
+	h.issueTokens(w, r, http.StatusOK, user)
+}
+
+// stateCookie returns the oidcStateCookie for the provider's endpoints. It is
+// sent on the top-level redirect back from the provider, but not on requests
+// other sites make in the background. A negative maxAge deletes it.
+func (h *Handler) stateCookie(r *http.Request, provider, state string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/" + provider + "/",
+		MaxAge:   maxAge,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// oidcUser returns the user linked to the provider account. Unlinked
+// accounts are linked to the user with the same email, or to a new user, but
+// only if the provider verified the email.
+func (h *Handler) oidcUser(r *http.Request, provider string, claims *oidc.IDTokenClaims) (*userdomain.User, error) {
+	ctx := r.Context()
+	link, err := h.identities.GetIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		return h.users.GetByID(ctx, link.UserID)
+	}
+	if !errors.Is(err, userdomain.ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if !claims.EmailVerified || userdomain.ValidateEmail(email) != nil {
+		return nil, errUnverifiedEmail
+	}
+	user, err := h.users.GetByEmail(ctx, email)
+	switch {
+	case errors.Is(err, userdomain.ErrUserNotFound):
+		if user, err = h.createOIDCUser(ctx, email, claims.Name); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !user.EmailVerified:
+		// Whoever registered this address never proved they own it, and may
+		// be waiting for the owner to sign in. Nothing they set up, such as
+		// a password, a second factor or granted roles, may survive the
+		// owner taking over, and neither may their sessions.
+		user.Password = ""
+		user.DisableMFA()
+		user.Roles = []string{userdomain.DefaultRole}
+		user.EmailVerified = true
+		user.UpdatedAt = time.Now()
+		if err := h.users.Update(ctx, user); err != nil {
+			return nil, err
+		}
+		if err := h.tokens.RevokeOtherSessions(ctx, user.ID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	err = h.identities.LinkIdentity(ctx, &userdomain.ExternalIdentity{
+		Provider:  provider,
+		Subject:   claims.Subject,
+		UserID:    user.ID,
+		Email:     email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.audit.Record(ctx, audit.Event{Type: audit.IdentityLinked, UserID: user.ID, Account: email, IP: clientIP(r), Reason: provider, Time: time.Now()})
+	return user, nil
+}
+
+// createOIDCUser creates a verified user without a password. They can set
+// one later through the password reset.
+func (h *Handler) createOIDCUser(ctx context.Context, email, name string) (*userdomain.User, error) {
+	if userdomain.ValidateName(name) != nil {
+		name, _, _ = strings.Cut(email, "@")
+		if userdomain.ValidateName(name) != nil {
+			name = "User"
+		}
+	}
+	now := time.Now()
+	user := &userdomain.User{
+		Email:         email,
+		Name:          strings.TrimSpace(name),
+		Roles:         []string{userdomain.DefaultRole},
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := h.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
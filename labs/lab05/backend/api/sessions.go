@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"lab05/jwtservice"
+
+	"github.com/gorilla/mux"
+)
+
+// SessionResponse describes one signed-in device
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session of the token making the request
+	Current bool `json:"current"`
+}
+
+// ListSessions handles GET /auth/sessions
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, _ := ClaimsFromContext(r.Context())
+
+	sessions, err := h.tokens.Sessions(r.Context(), claims.UserID)
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	resp := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		resp[i] = SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == claims.SessionID,
+		}
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeSession handles DELETE /auth/sessions/{id}, signing that device out
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, _ := ClaimsFromContext(r.Context())
+
+	err := h.tokens.RevokeSession(r.Context(), claims.UserID, mux.Vars(r)["id"])
+	if errors.Is(err, jwtservice.ErrSessionNotFound) {
+		h.writeError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions handles POST /auth/sessions/revoke-others, signing out
+// every device but the one making the request
+func (h *Handler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	claims, _ := ClaimsFromContext(r.Context())
+
+	if err := h.tokens.RevokeOtherSessions(r.Context(), claims.UserID, claims.SessionID); err != nil {
+		h.writeInternalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientOf returns the client a request comes from
+func clientOf(r *http.Request) jwtservice.Client {
+	return jwtservice.Client{UserAgent: r.UserAgent(), IP: clientIP(r)}
+}
@@ -0,0 +1,68 @@
+// Package audit records security-relevant events such as failed logins
+package audit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	LoginFailed   = "login_failed"
+	LoginLocked   = "login_locked"
+	AccountLocked = "account_locked"
+	// MFAFailed is a wrong TOTP or recovery code
+	MFAFailed        = "mfa_failed"
+	MFAEnabled       = "mfa_enabled"
+	MFADisabled      = "mfa_disabled"
+	RecoveryCodeUsed = "recovery_code_used"
+	// IdentityLinked is an external account linked to a user at sign-in
+	IdentityLinked = "identity_linked"
+)
+
+// Event is one audit record. UserID is 0 when the account is unknown.
+type Event struct {
+	Type    string    `json:"type"`
+	UserID  int       `json:"user_id,omitempty"`
+	Account string    `json:"account,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Recorder stores audit events. Record must not block the request for long;
+// failures are the recorder's to report, so it returns nothing.
+type Recorder interface {
+	Record(ctx context.Context, event Event)
+}
+
+// LogRecorder writes events to the standard logger
+type LogRecorder struct{}
+
+// Record logs event
+func (LogRecorder) Record(ctx context.Context, event Event) {
+	log.Printf("audit: %s user=%d account=%q ip=%s reason=%q",
+		event.Type, event.UserID, event.Account, event.IP, event.Reason)
+}
+
+// MemoryRecorder keeps events in memory, mostly for tests
+type MemoryRecorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+// Record appends event
+func (m *MemoryRecorder) Record(ctx context.Context, event Event) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events = append(m.events, event)
+}
+
+// Events returns a copy of the recorded events
+func (m *MemoryRecorder) Events() []Event {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Event(nil), m.events...)
+}
@@ -2,15 +2,36 @@ package jwtservice
 
 import (
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/authz"
 )
 
 // Claims represents JWT token claims
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID int      `json:"user_id"`
+	Email  string   `json:"email"`
+	Roles  []string `json:"roles,omitempty"`
+	// Scopes narrow what the roles allow, see authz.Subject
+	Scopes []string `json:"scopes,omitempty"`
+	// Type is TokenTypeAccess unless the token has a special purpose
+	Type string `json:"token_type,omitempty"`
+	// SessionID is the Session the token was issued to, if any
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Identity is who a token is issued to
+type Identity struct {
+	UserID int
+	Email  string
+	Roles  []string
+	Scopes []string
+}
+
+// Subject returns the claims as an authorization subject
+func (c *Claims) Subject() authz.Subject {
+	return authz.Subject{UserID: c.UserID, Roles: c.Roles, Scopes: c.Scopes}
+}
+
 // Valid validates the claims (required by jwt.Claims interface)
 func (c Claims) Valid() error {
 	return c.RegisteredClaims.Valid()
@@ -14,6 +14,9 @@ var ErrInvalidClaims = fmt.Errorf("invalid token claims")
 // ErrEmptyToken indicates the token string is empty
 var ErrEmptyToken = fmt.Errorf("token string cannot be empty")
 
+// ErrUnknownKey indicates the token names a key that is not in the ring
+var ErrUnknownKey = fmt.Errorf("unknown signing key")
+
 // InvalidSigningMethodError represents an error for invalid signing method
 type InvalidSigningMethodError struct {
 	Method interface{}
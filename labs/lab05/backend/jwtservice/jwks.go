@@ -0,0 +1,116 @@
+package jwtservice
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JWKS is a JSON Web Key Set (RFC 7517) as served at
+// /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public JSON Web Key
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// KeyRing builds a verification-only ring from the set, letting another
+// service validate tokens without holding any private key
+func (s JWKS) KeyRing() (*KeyRing, error) {
+	keys := make([]*Key, 0, len(s.Keys))
+	for _, jwk := range s.Keys {
+		key, err := jwk.Key()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyRing(keys...)
+}
+
+// Key converts the JWK into a verification key
+func (j JWK) Key() (*Key, error) {
+	var key *Key
+	var err error
+
+	switch j.Kty {
+	case "RSA":
+		n, e := decodeBigInt(j.N), decodeBigInt(j.E)
+		if n == nil || e == nil || !e.IsInt64() {
+			return nil, fmt.Errorf("jwk %s: invalid RSA parameters", j.Kid)
+		}
+		key, err = NewPublicKey(j.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())})
+	case "EC":
+		x, y := decodeBigInt(j.X), decodeBigInt(j.Y)
+		if j.Crv != "P-256" || x == nil || y == nil || !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("jwk %s: invalid P-256 point", j.Kid)
+		}
+		key, err = NewPublicKey(j.Kid, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+	case "OKP":
+		x, decodeErr := base64.RawURLEncoding.DecodeString(j.X)
+		if j.Crv != "Ed25519" || decodeErr != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk %s: invalid Ed25519 key", j.Kid)
+		}
+		key, err = NewPublicKey(j.Kid, ed25519.PublicKey(x))
+	default:
+		return nil, fmt.Errorf("jwk %s: unsupported key type %q", j.Kid, j.Kty)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if j.Alg != "" && j.Alg != key.Algorithm {
+		return nil, fmt.Errorf("jwk %s: alg %s does not match key type %s", j.Kid, j.Alg, j.Kty)
+	}
+	return key, nil
+}
+
+// jwk describes the public half of the key
+func (k *Key) jwk() JWK {
+	jwk := JWK{Use: "sig", Alg: k.Algorithm, Kid: k.ID}
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	}
+	return jwk
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBigInt(s string) *big.Int {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(data)
+}
@@ -1,48 +1,240 @@
 package jwtservice
 
 import (
+	"context"
 	"errors"
-	_ "github.com/golang-jwt/jwt/v4"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// DefaultAccessTokenTTL is how long access tokens stay valid; refresh
+	// tokens keep sessions alive beyond that
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL is how long an unused refresh token stays valid
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
 )
 
+// TokenTypeAccess is the Claims.Type of tokens from GenerateToken
+const TokenTypeAccess = "access"
+
 // JWTService handles JWT token operations
 type JWTService struct {
-	secretKey string
-}
-
-// TODO: Implement NewJWTService function
-// NewJWTService creates a new JWT service
-// Requirements:
-// - secretKey must not be empty
-func NewJWTService(secretKey string) (*JWTService, error) {
-	// TODO: Implement this function
-	// Validate secretKey and create service instance
-	return nil, errors.New("not implemented")
-}
-
-// TODO: Implement GenerateToken method
-// GenerateToken creates a new JWT token with user claims
-// Requirements:
-// - userID must be positive
-// - email must not be empty
-// - Token expires in 24 hours
-// - Use HS256 signing method
+	keys       *KeyRing
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	refresh    RefreshStore
+	revoked    RevocationStore
+	now        func() time.Time
+}
+
+// Option configures a JWTService
+type Option func(*JWTService)
+
+// WithAccessTTL sets the lifetime of access tokens
+func WithAccessTTL(ttl time.Duration) Option {
+	return func(j *JWTService) { j.accessTTL = ttl }
+}
+
+// WithRefreshTTL sets the lifetime of refresh tokens
+func WithRefreshTTL(ttl time.Duration) Option {
+	return func(j *JWTService) { j.refreshTTL = ttl }
+}
+
+// WithRefreshStore sets where refresh tokens are kept; the default is a
+// MemoryRefreshStore
+func WithRefreshStore(store RefreshStore) Option {
+	return func(j *JWTService) { j.refresh = store }
+}
+
+// WithRevocationStore sets where revoked access tokens are recorded; the
+// default is a MemoryRevocationStore
+func WithRevocationStore(store RevocationStore) Option {
+	return func(j *JWTService) { j.revoked = store }
+}
+
+// WithClock replaces time.Now, for tests
+func WithClock(now func() time.Time) Option {
+	return func(j *JWTService) { j.now = now }
+}
+
+// NewJWTService creates a JWT service signing HS256 tokens with secretKey
+func NewJWTService(secretKey string, opts ...Option) (*JWTService, error) {
+	if secretKey == "" {
+		return nil, NewValidationError("secretKey", "must not be empty")
+	}
+	key, err := NewHMACKey("", []byte(secretKey))
+	if err != nil {
+		return nil, err
+	}
+	keys, err := NewKeyRing(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewJWTServiceWithKeys(keys, opts...)
+}
+
+// NewJWTServiceWithKeys creates a JWT service that signs with the ring's
+// active key and accepts tokens from any key in the ring. A ring without a
+// signing key gives a service that can only validate tokens.
+func NewJWTServiceWithKeys(keys *KeyRing, opts ...Option) (*JWTService, error) {
+	if keys == nil {
+		return nil, NewValidationError("keys", "must not be nil")
+	}
+
+	j := &JWTService{
+		keys:       keys,
+		accessTTL:  DefaultAccessTokenTTL,
+		refreshTTL: DefaultRefreshTokenTTL,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	if j.refresh == nil {
+		j.refresh = NewMemoryRefreshStore()
+	}
+	if j.revoked == nil {
+		j.revoked = NewMemoryRevocationStore()
+	}
+	return j, nil
+}
+
+// Keys returns the key ring, e.g. to rotate keys or publish the JWKS
+func (j *JWTService) Keys() *KeyRing {
+	return j.keys
+}
+
+// AccessTTL returns how long access tokens stay valid
+func (j *JWTService) AccessTTL() time.Duration {
+	return j.accessTTL
+}
+
+// GenerateToken creates an access token for the user signed with the
+// active key
 func (j *JWTService) GenerateToken(userID int, email string) (string, error) {
-	// TODO: Implement token generation
-	// Create claims with userID, email, and expiration
-	// Sign token with secret key
-	return "", errors.New("not implemented")
-}
-
-// TODO: Implement ValidateToken method
-// ValidateToken parses and validates a JWT token
-// Requirements:
-// - Check token signature with secret key
-// - Verify token is not expired
-// - Return parsed claims on success
+	return j.GenerateAccessToken(Identity{UserID: userID, Email: email})
+}
+
+// GenerateAccessToken creates an access token carrying the identity's roles
+// and scopes
+func (j *JWTService) GenerateAccessToken(identity Identity) (string, error) {
+	return j.generate(identity, TokenTypeAccess, j.accessTTL, "")
+}
+
+// ValidateToken parses and validates an access token, returning its claims
 func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	// TODO: Implement token validation
-	// Parse token and verify signature
-	// Return claims if valid
-	return nil, errors.New("not implemented")
+	return j.ValidateTokenContext(context.Background(), tokenString)
+}
+
+// ValidateTokenContext is ValidateToken with a context for the revocation
+// lookup. Revoked tokens yield ErrTokenRevoked.
+func (j *JWTService) ValidateTokenContext(ctx context.Context, tokenString string) (*Claims, error) {
+	return j.validate(ctx, tokenString, TokenTypeAccess)
+}
+
+// generate signs a token; sessionID is empty for tokens outside a session
+func (j *JWTService) generate(identity Identity, tokenType string, ttl time.Duration, sessionID string) (string, error) {
+	if identity.UserID <= 0 {
+		return "", NewValidationError("userID", "must be positive")
+	}
+	if identity.Email == "" {
+		return "", NewValidationError("email", "must not be empty")
+	}
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := j.now()
+	claims := Claims{
+		UserID:    identity.UserID,
+		Email:     identity.Email,
+		Roles:     identity.Roles,
+		Scopes:    identity.Scopes,
+		Type:      tokenType,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return j.keys.Sign(claims)
+}
+
+func (j *JWTService) validate(ctx context.Context, tokenString, tokenType string) (*Claims, error) {
+	if tokenString == "" {
+		return nil, ErrEmptyToken
+	}
+
+	claims := &Claims{}
+	// Time claims are checked below against the service clock
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(tokenString, claims, j.verificationKey)
+	if err != nil {
+		return nil, mapParseError(err)
+	}
+
+	now := j.now()
+	if !claims.VerifyExpiresAt(now, true) {
+		return nil, ErrTokenExpired
+	}
+	if !claims.VerifyNotBefore(now, false) {
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens without a type predate token types and are access tokens
+	actual := claims.Type
+	if actual == "" {
+		actual = TokenTypeAccess
+	}
+	if actual != tokenType || claims.UserID <= 0 {
+		return nil, ErrInvalidClaims
+	}
+
+	if err := j.checkRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verificationKey picks the key named by the kid header. The alg header must
+// match that key's algorithm exactly, which stops algorithm confusion such as
+// an HS256 token "signed" with a published RSA public key.
+func (j *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	var key *Key
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = j.keys.Key(kid); !ok {
+			return nil, ErrInvalidToken
+		}
+	} else {
+		// Tokens issued before kid headers were added
+		signing, err := j.keys.SigningKey()
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+		key = signing
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, NewInvalidSigningMethodError(token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
+
+// mapParseError converts jwt library errors into this package's errors
+func mapParseError(err error) error {
+	var methodErr InvalidSigningMethodError
+	if errors.As(err, &methodErr) {
+		return methodErr
+	}
+	var validationErr *jwt.ValidationError
+	if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+		return ErrTokenExpired
+	}
+	return ErrInvalidToken
 }
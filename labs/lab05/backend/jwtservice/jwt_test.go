@@ -1,7 +1,11 @@
 package jwtservice
 
 import (
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
 )
 
 func TestNewJWTService(t *testing.T) {
@@ -154,3 +158,59 @@ func TestJWTService_DifferentSecrets(t *testing.T) {
 		t.Error("Claims should not be nil for valid token")
 	}
 }
+
+func TestJWTService_ValidateTokenErrors(t *testing.T) {
+	service, _ := NewJWTService("test-secret")
+
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID: 1,
+		Email:  "test@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+	}).SignedString([]byte("test-secret"))
+	if _, err := service.ValidateToken(expired); err != ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, Claims{UserID: 1, Email: "test@example.com"}).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	var methodErr InvalidSigningMethodError
+	if _, err := service.ValidateToken(unsigned); !errors.As(err, &methodErr) {
+		t.Errorf("Expected InvalidSigningMethodError, got %v", err)
+	}
+
+	otherType, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID: 1,
+		Email:  "test@example.com",
+		Type:   "mfa_pending",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}).SignedString([]byte("test-secret"))
+	if _, err := service.ValidateToken(otherType); err != ErrInvalidClaims {
+		t.Errorf("Expected ErrInvalidClaims for a non-access token, got %v", err)
+	}
+
+	if _, err := service.ValidateToken(""); err != ErrEmptyToken {
+		t.Errorf("Expected ErrEmptyToken, got %v", err)
+	}
+}
+
+func TestJWTService_AccessTTL(t *testing.T) {
+	now := time.Now()
+	service, _ := NewJWTService("test-secret", WithAccessTTL(time.Minute), WithClock(func() time.Time { return now }))
+
+	token, err := service.GenerateToken(1, "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := service.ValidateToken(token); err != nil {
+		t.Errorf("Token should be valid before its TTL: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := service.ValidateToken(token); err != ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired after the TTL, got %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package jwtservice
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing keys
+const minRSABits = 2048
+
+// ErrNoSigningKey indicates the key ring can only verify tokens
+var ErrNoSigningKey = fmt.Errorf("no signing key configured")
+
+// Key is a signing or verification key identified by its kid header
+type Key struct {
+	// ID is sent as the kid header; it defaults to the RFC 7638 thumbprint
+	ID        string
+	Algorithm string
+
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// CanSign reports whether the key holds private material
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, NewValidationError("secret", "must not be empty")
+	}
+	return newKey(id, AlgHS256, secret, secret)
+}
+
+// NewRSAKey creates an RS256 key from a private key of at least 2048 bits
+func NewRSAKey(id string, private *rsa.PrivateKey) (*Key, error) {
+	if private.N.BitLen() < minRSABits {
+		return nil, NewValidationError("key", fmt.Sprintf("RSA keys must have at least %d bits", minRSABits))
+	}
+	return newKey(id, AlgRS256, private, &private.PublicKey)
+}
+
+// NewECDSAKey creates an ES256 key from a P-256 private key
+func NewECDSAKey(id string, private *ecdsa.PrivateKey) (*Key, error) {
+	if private.Curve != elliptic.P256() {
+		return nil, NewValidationError("key", "ES256 requires a P-256 key")
+	}
+	return newKey(id, AlgES256, private, &private.PublicKey)
+}
+
+// NewEd25519Key creates an EdDSA key
+func NewEd25519Key(id string, private ed25519.PrivateKey) (*Key, error) {
+	if len(private) != ed25519.PrivateKeySize {
+		return nil, NewValidationError("key", "invalid Ed25519 private key")
+	}
+	return newKey(id, AlgEdDSA, private, private.Public())
+}
+
+// NewPublicKey creates a verification-only key, e.g. a retired key whose
+// private half has been destroyed
+func NewPublicKey(id string, public crypto.PublicKey) (*Key, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return nil, NewValidationError("key", fmt.Sprintf("RSA keys must have at least %d bits", minRSABits))
+		}
+		return newKey(id, AlgRS256, nil, pub)
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, NewValidationError("key", "ES256 requires a P-256 key")
+		}
+		return newKey(id, AlgES256, nil, pub)
+	case ed25519.PublicKey:
+		return newKey(id, AlgEdDSA, nil, pub)
+	}
+	return nil, NewValidationError("key", fmt.Sprintf("unsupported public key type %T", public))
+}
+
+// GenerateKey creates a new random key for alg
+func GenerateKey(id, alg string) (*Key, error) {
+	switch alg {
+	case AlgHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(id, secret)
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, minRSABits)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(id, private)
+	case AlgES256:
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewECDSAKey(id, private)
+	case AlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewEd25519Key(id, private)
+	}
+	return nil, NewInvalidSigningMethodError(alg)
+}
+
+// ParseKeyPEM loads a PKCS#8, PKCS#1 or SEC 1 private key, or a PKIX public
+// key, from PEM
+func ParseKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %v", err)
+		}
+		switch private := private.(type) {
+		case *rsa.PrivateKey:
+			return NewRSAKey(id, private)
+		case *ecdsa.PrivateKey:
+			return NewECDSAKey(id, private)
+		case ed25519.PrivateKey:
+			return NewEd25519Key(id, private)
+		}
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA key: %v", err)
+		}
+		return NewRSAKey(id, private)
+	case "EC PRIVATE KEY":
+		private, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse EC key: %v", err)
+		}
+		return NewECDSAKey(id, private)
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %v", err)
+		}
+		return NewPublicKey(id, public)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+func newKey(id, alg string, signKey, verifyKey interface{}) (*Key, error) {
+	key := &Key{
+		ID:        id,
+		Algorithm: alg,
+		method:    jwt.GetSigningMethod(alg),
+		signKey:   signKey,
+		verifyKey: verifyKey,
+	}
+	if key.ID == "" {
+		key.ID = key.thumbprint()
+	}
+	return key, nil
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint; for HMAC keys the hash
+// covers the secret, so the kid reveals nothing useful about it
+func (k *Key) thumbprint() string {
+	var members interface{}
+	switch pub := k.verifyKey.(type) {
+	case []byte:
+		members = struct {
+			K   string `json:"k"`
+			Kty string `json:"kty"`
+		}{b64(pub), "oct"}
+	default:
+		jwk := k.jwk()
+		// The field order of these structs is the lexicographic order the
+		// RFC requires
+		switch jwk.Kty {
+		case "RSA":
+			members = struct {
+				E   string `json:"e"`
+				Kty string `json:"kty"`
+				N   string `json:"n"`
+			}{jwk.E, jwk.Kty, jwk.N}
+		case "EC":
+			members = struct {
+				Crv string `json:"crv"`
+				Kty string `json:"kty"`
+				X   string `json:"x"`
+				Y   string `json:"y"`
+			}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+		default:
+			members = struct {
+				Crv string `json:"crv"`
+				Kty string `json:"kty"`
+				X   string `json:"x"`
+			}{jwk.Crv, jwk.Kty, jwk.X}
+		}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return b64(sum[:])
+}
+
+// KeyRing holds the active signing key and the keys still accepted for
+// verification, so tokens signed before a rotation stay valid
+type KeyRing struct {
+	mutex  sync.RWMutex
+	keys   map[string]*Key
+	active string
+}
+
+// NewKeyRing creates a ring from keys; the first key that can sign becomes
+// the active one
+func NewKeyRing(keys ...*Key) (*KeyRing, error) {
+	ring := &KeyRing{keys: make(map[string]*Key)}
+	for _, key := range keys {
+		if err := ring.Add(key); err != nil {
+			return nil, err
+		}
+		if ring.active == "" && key.CanSign() {
+			ring.active = key.ID
+		}
+	}
+	return ring, nil
+}
+
+// Add registers a key for verification
+func (r *KeyRing) Add(key *Key) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.keys[key.ID]; exists {
+		return fmt.Errorf("duplicate key id %q", key.ID)
+	}
+	r.keys[key.ID] = key
+	return nil
+}
+
+// Rotate adds key and makes it the signing key; previous keys keep
+// verifying tokens until they are removed
+func (r *KeyRing) Rotate(key *Key) error {
+	if !key.CanSign() {
+		return ErrNoSigningKey
+	}
+	if err := r.Add(key); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.active = key.ID
+	return nil
+}
+
+// Remove retires a key; tokens it signed stop validating
+func (r *KeyRing) Remove(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if id == r.active {
+		return errors.New("cannot remove the active signing key")
+	}
+	delete(r.keys, id)
+	return nil
+}
+
+// SigningKey returns the active key
+func (r *KeyRing) SigningKey() (*Key, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.active == "" {
+		return nil, ErrNoSigningKey
+	}
+	return r.keys[r.active], nil
+}
+
+// Key returns the key with the given kid
+func (r *KeyRing) Key(id string) (*Key, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	key, ok := r.keys[id]
+	return key, ok
+}
+
+// JWKS returns the public keys of the ring; HMAC keys are never published
+func (r *KeyRing) JWKS() JWKS {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range r.keys {
+		if key.Algorithm == AlgHS256 {
+			continue
+		}
+		set.Keys = append(set.Keys, key.jwk())
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// Sign signs claims with the active key and names it in the kid header. It
+// suits any JWT, e.g. OpenID Connect ID tokens.
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	key, err := r.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// Verify checks the signature of a JWT from another issuer and decodes its
+// payload into claims. The kid header must name a key of the ring, or be
+// absent if the ring holds a single key, and the alg header must match that
+// key. Time and audience claims are left to the caller. A kid that is not
+// in the ring yields ErrUnknownKey so callers can refetch the issuer's JWKS.
+func (r *KeyRing) Verify(tokenString string, claims jwt.Claims) error {
+	if tokenString == "" {
+		return ErrEmptyToken
+	}
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		key, ok := r.keyFor(token)
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, NewInvalidSigningMethodError(token.Header["alg"])
+		}
+		return key.verifyKey, nil
+	})
+	if errors.Is(err, ErrUnknownKey) {
+		return ErrUnknownKey
+	}
+	if err != nil {
+		return mapParseError(err)
+	}
+	return nil
+}
+
+func (r *KeyRing) keyFor(token *jwt.Token) (*Key, bool) {
+	if kid, ok := token.Header["kid"].(string); ok {
+		return r.Key(kid)
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if len(r.keys) != 1 {
+		return nil, false
+	}
+	for _, key := range r.keys {
+		return key, true
+	}
+	return nil, false
+}
@@ -0,0 +1,218 @@
+package jwtservice
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func mustKey(t *testing.T, alg string) *Key {
+	t.Helper()
+	key, err := GenerateKey("", alg)
+	if err != nil {
+		t.Fatalf("Failed to generate %s key: %v", alg, err)
+	}
+	return key
+}
+
+func mustService(t *testing.T, keys ...*Key) *JWTService {
+	t.Helper()
+	ring, err := NewKeyRing(keys...)
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+	service, err := NewJWTServiceWithKeys(ring)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	return service
+}
+
+func TestAlgorithms(t *testing.T) {
+	for _, alg := range []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			key := mustKey(t, alg)
+			service := mustService(t, key)
+
+			token, err := service.GenerateToken(1, "test@example.com")
+			if err != nil {
+				t.Fatalf("GenerateToken failed: %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatalf("Failed to parse header: %v", err)
+			}
+			if parsed.Header["alg"] != alg || parsed.Header["kid"] != key.ID {
+				t.Errorf("Expected alg %s and kid %s, got %v", alg, key.ID, parsed.Header)
+			}
+
+			if _, err := service.ValidateToken(token); err != nil {
+				t.Errorf("ValidateToken failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := mustKey(t, AlgRS256)
+	service := mustService(t, oldKey)
+	oldToken, _ := service.GenerateToken(1, "test@example.com")
+
+	newKey := mustKey(t, AlgEdDSA)
+	if err := service.Keys().Rotate(newKey); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	newToken, _ := service.GenerateToken(1, "test@example.com")
+
+	if _, err := service.ValidateToken(oldToken); err != nil {
+		t.Errorf("Tokens signed before the rotation should stay valid: %v", err)
+	}
+	if _, err := service.ValidateToken(newToken); err != nil {
+		t.Errorf("Tokens signed with the new key should be valid: %v", err)
+	}
+
+	if err := service.Keys().Remove(newKey.ID); err == nil {
+		t.Error("Removing the active key should fail")
+	}
+	if err := service.Keys().Remove(oldKey.ID); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := service.ValidateToken(oldToken); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a removed key, got %v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	hmacKey, rsaKey, ecKey, edKey := mustKey(t, AlgHS256), mustKey(t, AlgRS256), mustKey(t, AlgES256), mustKey(t, AlgEdDSA)
+	issuer := mustService(t, rsaKey, ecKey, edKey, hmacKey)
+
+	data, err := json.Marshal(issuer.Keys().JWKS())
+	if err != nil {
+		t.Fatalf("Failed to encode JWKS: %v", err)
+	}
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatalf("Failed to decode JWKS: %v", err)
+	}
+	if len(set.Keys) != 3 {
+		t.Fatalf("Expected 3 public keys and no HMAC key, got %d", len(set.Keys))
+	}
+
+	ring, err := set.KeyRing()
+	if err != nil {
+		t.Fatalf("Failed to build key ring from JWKS: %v", err)
+	}
+	verifier, _ := NewJWTServiceWithKeys(ring)
+	if _, err := verifier.GenerateToken(1, "test@example.com"); err != ErrNoSigningKey {
+		t.Errorf("Expected ErrNoSigningKey from a verification-only ring, got %v", err)
+	}
+
+	if err := ring.Rotate(&Key{ID: "public-only"}); err != ErrNoSigningKey {
+		t.Errorf("Rotating to a key without private material should fail, got %v", err)
+	}
+
+	for _, key := range []*Key{rsaKey, ecKey, edKey} {
+		signer := mustService(t, key)
+		token, _ := signer.GenerateToken(1, "test@example.com")
+		if _, err := verifier.ValidateToken(token); err != nil {
+			t.Errorf("%s token should validate against the JWKS: %v", key.Algorithm, err)
+		}
+		if jwk, _ := ring.Key(key.ID); jwk == nil || jwk.thumbprint() != key.ID {
+			t.Errorf("%s: kid should be the RFC 7638 thumbprint", key.Algorithm)
+		}
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	rsaKey := mustKey(t, AlgRS256)
+	service := mustService(t, rsaKey)
+
+	// Classic attack: sign HS256 with the published RSA public key as secret
+	publicDER, _ := x509.MarshalPKIXPublicKey(rsaKey.verifyKey)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: 1, Email: "evil@example.com"})
+	forged.Header["kid"] = rsaKey.ID
+	forgedToken, _ := forged.SignedString(publicPEM)
+
+	var methodErr InvalidSigningMethodError
+	if _, err := service.ValidateToken(forgedToken); !errors.As(err, &methodErr) {
+		t.Errorf("Expected InvalidSigningMethodError, got %v", err)
+	}
+
+	unknownKid := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: 1, Email: "evil@example.com"})
+	unknownKid.Header["kid"] = "unknown"
+	unknownToken, _ := unknownKid.SignedString([]byte("secret"))
+	if _, err := service.ValidateToken(unknownToken); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for an unknown kid, got %v", err)
+	}
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(private)
+	key, err := ParseKeyPEM("primary", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	if err != nil {
+		t.Fatalf("ParseKeyPEM failed: %v", err)
+	}
+	if key.ID != "primary" || key.Algorithm != AlgRS256 || !key.CanSign() {
+		t.Errorf("Unexpected key %s %s signing=%v", key.ID, key.Algorithm, key.CanSign())
+	}
+
+	publicDER, _ := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	public, err := ParseKeyPEM("", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatalf("ParseKeyPEM failed for a public key: %v", err)
+	}
+	if public.CanSign() {
+		t.Error("Public keys must not be able to sign")
+	}
+
+	if _, err := ParseKeyPEM("", []byte("not pem")); err == nil {
+		t.Error("Expected an error for invalid PEM")
+	}
+
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := NewRSAKey("", weak); err == nil {
+		t.Error("Expected RSA keys under 2048 bits to be rejected")
+	}
+}
+
+func TestKeyRingSignAndVerify(t *testing.T) {
+	signer, err := NewKeyRing(mustKey(t, AlgES256))
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %v", err)
+	}
+	token, err := signer.Sign(jwt.RegisteredClaims{Subject: "alice", Audience: jwt.ClaimStrings{"app"}})
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	verifier, err := signer.JWKS().KeyRing()
+	if err != nil {
+		t.Fatalf("Failed to build ring from JWKS: %v", err)
+	}
+	var claims jwt.RegisteredClaims
+	if err := verifier.Verify(token, &claims); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if claims.Subject != "alice" || !claims.VerifyAudience("app", true) {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	other, _ := NewKeyRing(mustKey(t, AlgES256), mustKey(t, AlgRS256))
+	if err := other.Verify(token, &jwt.RegisteredClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	if err := verifier.Verify(token[:len(token)-4]+"AAAA", &jwt.RegisteredClaims{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for a bad signature, got %v", err)
+	}
+}
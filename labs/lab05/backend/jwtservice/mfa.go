@@ -0,0 +1,28 @@
+package jwtservice
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// TokenTypeMFAPending marks tokens that prove the password was correct
+	// but the second factor is still missing. They are rejected wherever an
+	// access token is expected.
+	TokenTypeMFAPending = "mfa_pending"
+	// DefaultMFAPendingTTL is how long the user has to enter the second factor
+	DefaultMFAPendingTTL = 5 * time.Minute
+)
+
+// GenerateMFAPendingToken creates a short-lived token to be exchanged for a
+// token pair once the second factor is verified. Roles and scopes are left
+// out so the token grants nothing by itself.
+func (j *JWTService) GenerateMFAPendingToken(identity Identity) (string, error) {
+	return j.generate(Identity{UserID: identity.UserID, Email: identity.Email}, TokenTypeMFAPending, DefaultMFAPendingTTL, "")
+}
+
+// ValidateMFAPendingToken validates a token from GenerateMFAPendingToken.
+// Revoke it with RevokeToken once it has been exchanged.
+func (j *JWTService) ValidateMFAPendingToken(ctx context.Context, tokenString string) (*Claims, error) {
+	return j.validate(ctx, tokenString, TokenTypeMFAPending)
+}
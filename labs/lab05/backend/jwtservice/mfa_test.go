@@ -0,0 +1,47 @@
+package jwtservice
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMFAPendingToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service, _ := NewJWTService("test-secret", WithClock(func() time.Time { return now }))
+
+	pending, err := service.GenerateMFAPendingToken(Identity{UserID: 1, Email: "test@example.com", Roles: []string{"admin"}})
+	if err != nil {
+		t.Fatalf("GenerateMFAPendingToken failed: %v", err)
+	}
+
+	if _, err := service.ValidateTokenContext(ctx, pending); err != ErrInvalidClaims {
+		t.Errorf("Pending tokens must not work as access tokens, got %v", err)
+	}
+	access, _ := service.GenerateToken(1, "test@example.com")
+	if _, err := service.ValidateMFAPendingToken(ctx, access); err != ErrInvalidClaims {
+		t.Errorf("Access tokens must not work as pending tokens, got %v", err)
+	}
+
+	claims, err := service.ValidateMFAPendingToken(ctx, pending)
+	if err != nil {
+		t.Fatalf("ValidateMFAPendingToken failed: %v", err)
+	}
+	if claims.Type != TokenTypeMFAPending || len(claims.Roles) != 0 {
+		t.Errorf("Pending token should carry no roles, got %+v", claims)
+	}
+
+	if err := service.RevokeToken(ctx, claims); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+	if _, err := service.ValidateMFAPendingToken(ctx, pending); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+
+	other, _ := service.GenerateMFAPendingToken(Identity{UserID: 1, Email: "test@example.com"})
+	now = now.Add(DefaultMFAPendingTTL)
+	if _, err := service.ValidateMFAPendingToken(ctx, other); err != ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
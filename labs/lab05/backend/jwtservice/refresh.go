@@ -0,0 +1,203 @@
+package jwtservice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrRefreshTokenReused indicates an already rotated refresh token was
+// presented again. The whole token family is revoked when this happens,
+// since either the client or an attacker holds a stolen copy.
+var ErrRefreshTokenReused = fmt.Errorf("refresh token reused")
+
+// ErrRefreshTokenNotFound is returned by a RefreshStore for unknown tokens
+var ErrRefreshTokenNotFound = fmt.Errorf("refresh token not found")
+
+// RefreshToken is the server-side record of an opaque refresh token. Every
+// login starts a family; each rotation adds a token to it.
+type RefreshToken struct {
+	// Hash is the SHA-256 of the token; the token itself is never stored
+	Hash      string
+	FamilyID  string
+	UserID    int
+	Email     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// UsedAt is set once the token has been rotated
+	UsedAt time.Time
+	// RevokedAt is set once the token's family has been revoked
+	RevokedAt time.Time
+}
+
+// RefreshStore persists refresh tokens
+type RefreshStore interface {
+	// Save stores a new token, creating its family if needed
+	Save(ctx context.Context, token *RefreshToken) error
+	// Get returns ErrRefreshTokenNotFound for unknown hashes
+	Get(ctx context.Context, hash string) (*RefreshToken, error)
+	// MarkUsed sets UsedAt unless it is already set, reporting whether it
+	// did; it must be atomic so two concurrent rotations cannot both win
+	MarkUsed(ctx context.Context, hash string, at time.Time) (bool, error)
+	// RevokeFamily revokes every token in the family
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	// RevokeUser revokes every family of the user
+	RevokeUser(ctx context.Context, userID int, at time.Time) error
+
+	// SaveSession creates the family of a new session before its first
+	// token is saved
+	SaveSession(ctx context.Context, session *Session) error
+	// TouchSession sets the session's LastSeenAt and the non-empty fields
+	// of client
+	TouchSession(ctx context.Context, id string, at time.Time, client Client) error
+	// GetSession returns ErrSessionNotFound for unknown IDs
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// ListSessions returns the user's sessions that are not revoked and
+	// have a token that is unexpired at now, most recently seen first
+	ListSessions(ctx context.Context, userID int, now time.Time) ([]Session, error)
+}
+
+// IdentityLoader returns the current identity of a user when a refresh token
+// is rotated, so new access tokens reflect role changes
+type IdentityLoader func(ctx context.Context, userID int) (*Identity, error)
+
+// TokenPair is the result of a login or refresh
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// ExpiresIn is the lifetime of AccessToken
+	ExpiresIn time.Duration
+	UserID    int
+	FamilyID  string
+}
+
+// IssueTokenPair starts a new refresh token family for the identity, e.g. on
+// login, and returns an access token with its first refresh token
+func (j *JWTService) IssueTokenPair(ctx context.Context, identity Identity) (*TokenPair, error) {
+	return j.StartSession(ctx, identity, Client{})
+}
+
+// Refresh rotates refreshToken: it is marked used and a new pair in the same
+// family is returned for the identity from load. Presenting a used token
+// revokes the family and returns ErrRefreshTokenReused. Errors from load are
+// returned as they are, after revoking the family.
+func (j *JWTService) Refresh(ctx context.Context, refreshToken string, load IdentityLoader) (*TokenPair, error) {
+	record, err := j.lookupRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	now := j.now()
+
+	if !record.UsedAt.IsZero() {
+		return nil, j.revokeReused(ctx, record, now)
+	}
+	if !now.Before(record.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	won, err := j.refresh.MarkUsed(ctx, record.Hash, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %v", err)
+	}
+	if !won {
+		// A concurrent request rotated the same token first
+		return nil, j.revokeReused(ctx, record, now)
+	}
+
+	identity, err := load(ctx, record.UserID)
+	if err != nil {
+		if revokeErr := j.revokeFamily(ctx, record.FamilyID, now); revokeErr != nil {
+			return nil, revokeErr
+		}
+		return nil, err
+	}
+	return j.issue(ctx, *identity, record.FamilyID)
+}
+
+// RevokeRefreshToken revokes the family of refreshToken and the access
+// tokens of its session, e.g. on logout
+func (j *JWTService) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	record, err := j.lookupRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	return j.revokeFamily(ctx, record.FamilyID, j.now())
+}
+
+// lookupRefreshToken returns the live record of refreshToken, or
+// ErrInvalidToken when it is unknown or its family was revoked
+func (j *JWTService) lookupRefreshToken(ctx context.Context, refreshToken string) (*RefreshToken, error) {
+	if refreshToken == "" {
+		return nil, ErrEmptyToken
+	}
+
+	record, err := j.refresh.Get(ctx, hashToken(refreshToken))
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load refresh token: %v", err)
+	}
+	if !record.RevokedAt.IsZero() {
+		return nil, ErrInvalidToken
+	}
+	return record, nil
+}
+
+func (j *JWTService) revokeReused(ctx context.Context, record *RefreshToken, now time.Time) error {
+	if err := j.revokeFamily(ctx, record.FamilyID, now); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func (j *JWTService) issue(ctx context.Context, identity Identity, familyID string) (*TokenPair, error) {
+	access, err := j.generate(identity, TokenTypeAccess, j.accessTTL, familyID)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := j.now()
+	err = j.refresh.Save(ctx, &RefreshToken{
+		Hash:      hashToken(refresh),
+		FamilyID:  familyID,
+		UserID:    identity.UserID,
+		Email:     identity.Email,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(j.refreshTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    j.accessTTL,
+		UserID:       identity.UserID,
+		FamilyID:     familyID,
+	}, nil
+}
+
+// randomToken returns n random bytes encoded as unpadded base64url
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
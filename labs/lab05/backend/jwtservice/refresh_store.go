@@ -0,0 +1,190 @@
+package jwtservice
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryRefreshStore drops expired tokens
+const sweepInterval = time.Minute
+
+// MemoryRefreshStore is a RefreshStore kept in process memory
+type MemoryRefreshStore struct {
+	mutex     sync.Mutex
+	tokens    map[string]RefreshToken
+	sessions  map[string]Session
+	lastSweep time.Time
+}
+
+// NewMemoryRefreshStore creates an empty store
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		tokens:   make(map[string]RefreshToken),
+		sessions: make(map[string]Session),
+	}
+}
+
+// Save stores a copy of token
+func (s *MemoryRefreshStore) Save(ctx context.Context, token *RefreshToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sweep(token.IssuedAt)
+	s.tokens[token.Hash] = *token
+	if _, ok := s.sessions[token.FamilyID]; !ok {
+		s.sessions[token.FamilyID] = Session{ID: token.FamilyID, UserID: token.UserID, CreatedAt: token.IssuedAt, LastSeenAt: token.IssuedAt}
+	}
+	return nil
+}
+
+// Get returns a copy of the token with the given hash
+func (s *MemoryRefreshStore) Get(ctx context.Context, hash string) (*RefreshToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, ok := s.tokens[hash]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+	token.RevokedAt = s.sessions[token.FamilyID].RevokedAt
+	return &token, nil
+}
+
+// MarkUsed sets UsedAt if the token has not been used yet
+func (s *MemoryRefreshStore) MarkUsed(ctx context.Context, hash string, at time.Time) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, ok := s.tokens[hash]
+	if !ok {
+		return false, ErrRefreshTokenNotFound
+	}
+	if !token.UsedAt.IsZero() {
+		return false, nil
+	}
+	token.UsedAt = at
+	s.tokens[hash] = token
+	return true, nil
+}
+
+// RevokeFamily marks the family revoked
+func (s *MemoryRefreshStore) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if session, ok := s.sessions[familyID]; ok && session.RevokedAt.IsZero() {
+		session.RevokedAt = at
+		s.sessions[familyID] = session
+	}
+	return nil
+}
+
+// RevokeUser marks every family of the user revoked
+func (s *MemoryRefreshStore) RevokeUser(ctx context.Context, userID int, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt.IsZero() {
+			session.RevokedAt = at
+			s.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+// SaveSession stores a copy of session
+func (s *MemoryRefreshStore) SaveSession(ctx context.Context, session *Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+// TouchSession updates the session's activity
+func (s *MemoryRefreshStore) TouchSession(ctx context.Context, id string, at time.Time, client Client) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	session.LastSeenAt = at
+	if client.UserAgent != "" {
+		session.UserAgent = client.UserAgent
+	}
+	if client.IP != "" {
+		session.IP = client.IP
+	}
+	s.sessions[id] = session
+	return nil
+}
+
+// GetSession returns a copy of the session with the given ID
+func (s *MemoryRefreshStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	session.ExpiresAt = s.expiresAt(id)
+	return &session, nil
+}
+
+// ListSessions returns copies of the user's live sessions
+func (s *MemoryRefreshStore) ListSessions(ctx context.Context, userID int, now time.Time) ([]Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var sessions []Session
+	for id, session := range s.sessions {
+		session.ExpiresAt = s.expiresAt(id)
+		if session.UserID == userID && session.RevokedAt.IsZero() && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, k int) bool {
+		return sessions[i].LastSeenAt.After(sessions[k].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// expiresAt returns the latest expiry of the family's tokens
+func (s *MemoryRefreshStore) expiresAt(familyID string) time.Time {
+	var latest time.Time
+	for _, token := range s.tokens {
+		if token.FamilyID == familyID && token.ExpiresAt.After(latest) {
+			latest = token.ExpiresAt
+		}
+	}
+	return latest
+}
+
+// sweep drops expired tokens and sessions that no longer have tokens
+func (s *MemoryRefreshStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	live := make(map[string]bool)
+	for hash, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, hash)
+			continue
+		}
+		live[token.FamilyID] = true
+	}
+	for id, session := range s.sessions {
+		// A session saved just now has no token yet
+		if !live[id] && now.Sub(session.CreatedAt) >= sweepInterval {
+			delete(s.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package jwtservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// loadIdentity stands in for a user repository lookup
+func loadIdentity(ctx context.Context, userID int) (*Identity, error) {
+	return &Identity{UserID: userID, Email: "test@example.com"}, nil
+}
+
+func newRefreshTestService(t *testing.T, now *time.Time) *JWTService {
+	t.Helper()
+	service, err := NewJWTService("test-secret",
+		WithRefreshTTL(time.Hour),
+		WithClock(func() time.Time { return *now }))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	return service
+}
+
+func TestRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := newRefreshTestService(t, &now)
+
+	first, err := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to issue tokens: %v", err)
+	}
+	if _, err := service.ValidateToken(first.AccessToken); err != nil {
+		t.Errorf("Access token should be valid: %v", err)
+	}
+
+	second, err := service.Refresh(ctx, first.RefreshToken, loadIdentity)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Refresh should rotate the refresh token")
+	}
+	if second.FamilyID != first.FamilyID {
+		t.Errorf("Expected family %s to continue, got %s", first.FamilyID, second.FamilyID)
+	}
+
+	third, err := service.Refresh(ctx, second.RefreshToken, loadIdentity)
+	if err != nil {
+		t.Fatalf("Second refresh failed: %v", err)
+	}
+	if third.UserID != 1 {
+		t.Errorf("Expected user 1, got %d", third.UserID)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := newRefreshTestService(t, &now)
+
+	first, _ := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"})
+	other, _ := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"})
+	second, err := service.Refresh(ctx, first.RefreshToken, loadIdentity)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	if _, err := service.Refresh(ctx, first.RefreshToken, loadIdentity); err != ErrRefreshTokenReused {
+		t.Errorf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, second.RefreshToken, loadIdentity); err != ErrInvalidToken {
+		t.Errorf("Expected the rest of the family to be revoked, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, other.RefreshToken, loadIdentity); err != nil {
+		t.Errorf("Other logins should be unaffected, got %v", err)
+	}
+}
+
+func TestRefreshConcurrentRotation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := newRefreshTestService(t, &now)
+	pair, _ := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"})
+
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Refresh(ctx, pair.RefreshToken, loadIdentity)
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one rotation to succeed, got %d", succeeded)
+	}
+}
+
+func TestRefreshErrors(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := newRefreshTestService(t, &now)
+
+	if _, err := service.Refresh(ctx, "", loadIdentity); err != ErrEmptyToken {
+		t.Errorf("Expected ErrEmptyToken, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, "unknown", loadIdentity); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+
+	pair, _ := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"})
+	now = now.Add(2 * time.Hour)
+	if _, err := service.Refresh(ctx, pair.RefreshToken, loadIdentity); err != ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := newRefreshTestService(t, &now)
+
+	pair, _ := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"})
+	if err := service.RevokeRefreshToken(ctx, pair.RefreshToken); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := service.Refresh(ctx, pair.RefreshToken, loadIdentity); err != ErrInvalidToken {
+		t.Errorf("Expected revoked token to be rejected, got %v", err)
+	}
+	if err := service.RevokeRefreshToken(ctx, pair.RefreshToken); err != ErrInvalidToken {
+		t.Errorf("Expected second revoke to be rejected, got %v", err)
+	}
+}
+
+func TestRefreshLoadsCurrentIdentity(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := newRefreshTestService(t, &now)
+
+	pair, _ := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com", Roles: []string{"user"}})
+	promoted := func(ctx context.Context, userID int) (*Identity, error) {
+		return &Identity{UserID: userID, Email: "test@example.com", Roles: []string{"user", "editor"}}, nil
+	}
+	refreshed, err := service.Refresh(ctx, pair.RefreshToken, promoted)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	claims, err := service.ValidateToken(refreshed.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if len(claims.Roles) != 2 || claims.Roles[1] != "editor" {
+		t.Errorf("Expected refreshed token to carry the new roles, got %v", claims.Roles)
+	}
+
+	gone := errors.New("user deleted")
+	failing := func(ctx context.Context, userID int) (*Identity, error) { return nil, gone }
+	if _, err := service.Refresh(ctx, refreshed.RefreshToken, failing); err != gone {
+		t.Errorf("Expected the loader error, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, refreshed.RefreshToken, loadIdentity); err != ErrInvalidToken {
+		t.Errorf("Expected the family to be revoked after a failed load, got %v", err)
+	}
+}
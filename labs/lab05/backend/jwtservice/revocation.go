@@ -0,0 +1,183 @@
+package jwtservice
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked indicates the token was revoked before it expired
+var ErrTokenRevoked = fmt.Errorf("token revoked")
+
+// RevocationStore records access tokens that must be rejected before they
+// expire. Entries only need to be kept until expiresAt, after which the
+// token is rejected as expired anyway.
+type RevocationStore interface {
+	// Revoke rejects the token with the given jti. Revoked sessions are
+	// recorded the same way under a key that cannot clash with a jti.
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsRevoked reports whether the jti was revoked
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeUser rejects every token of the user issued outside a session
+	// before the cutoff; a later cutoff replaces an earlier one
+	RevokeUser(ctx context.Context, userID int, issuedBefore, expiresAt time.Time) error
+	// UserCutoff returns the user's cutoff, or the zero time if there is none
+	UserCutoff(ctx context.Context, userID int) (time.Time, error)
+}
+
+// RevokeToken revokes a single access token, e.g. on logout
+func (j *JWTService) RevokeToken(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return ErrInvalidClaims
+	}
+	if err := j.revoked.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return fmt.Errorf("failed to revoke token: %v", err)
+	}
+	return nil
+}
+
+// RevokeUserTokens ends every session of the user, which revokes their
+// refresh tokens and the access tokens issued to them. Access tokens issued
+// outside a session are revoked if issued before the given time. Use it after
+// a password change or when suspending an account.
+//
+// Sessions started after the call are unaffected. Tokens outside a session
+// only carry iat with one-second precision, so those issued within the same
+// second as the cutoff are revoked as well.
+func (j *JWTService) RevokeUserTokens(ctx context.Context, userID int, before time.Time) error {
+	if err := j.RevokeOtherSessions(ctx, userID, ""); err != nil {
+		return err
+	}
+	if err := j.revoked.RevokeUser(ctx, userID, before, before.Add(j.accessTTL)); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %v", err)
+	}
+	if err := j.refresh.RevokeUser(ctx, userID, j.now()); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+	}
+	return nil
+}
+
+// checkRevoked consults the revocation store for validated claims
+func (j *JWTService) checkRevoked(ctx context.Context, claims *Claims) error {
+	// The token is revoked by itself or together with its session
+	var keys []string
+	if claims.ID != "" {
+		keys = append(keys, claims.ID)
+	}
+	if claims.SessionID != "" {
+		keys = append(keys, sessionRevocationKey(claims.SessionID))
+	}
+	for _, key := range keys {
+		revoked, err := j.revoked.IsRevoked(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to check revocation: %v", err)
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	// Session tokens were revoked with their session above; the cutoff
+	// would also reject sessions started in the same second as it
+	if claims.SessionID != "" {
+		return nil
+	}
+	cutoff, err := j.revoked.UserCutoff(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to check revocation: %v", err)
+	}
+	if !cutoff.IsZero() && (claims.IssuedAt == nil || !claims.IssuedAt.Time.After(cutoff.Truncate(time.Second))) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// userCutoff is a RevokeUser entry
+type userCutoff struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// MemoryRevocationStore is a RevocationStore kept in process memory; entries
+// are evicted once the tokens they cover have expired
+type MemoryRevocationStore struct {
+	mutex     sync.Mutex
+	tokens    map[string]time.Time
+	users     map[int]userCutoff
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryRevocationStore creates an empty store
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[int]userCutoff),
+		now:    time.Now,
+	}
+}
+
+// Revoke records the jti until expiresAt
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sweep()
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether the jti is recorded and not yet evicted
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expiresAt, ok := s.tokens[jti]
+	return ok && s.now().Before(expiresAt), nil
+}
+
+// RevokeUser records the cutoff until expiresAt
+func (s *MemoryRevocationStore) RevokeUser(ctx context.Context, userID int, issuedBefore, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sweep()
+	if existing, ok := s.users[userID]; ok && existing.issuedBefore.After(issuedBefore) {
+		return nil
+	}
+	s.users[userID] = userCutoff{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+// UserCutoff returns the user's cutoff while it is still in effect
+func (s *MemoryRevocationStore) UserCutoff(ctx context.Context, userID int) (time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cutoff, ok := s.users[userID]
+	if !ok || !s.now().Before(cutoff.expiresAt) {
+		return time.Time{}, nil
+	}
+	return cutoff.issuedBefore, nil
+}
+
+// sweep evicts entries for tokens that have expired
+func (s *MemoryRevocationStore) sweep() {
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for jti, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for userID, cutoff := range s.users {
+		if !now.Before(cutoff.expiresAt) {
+			delete(s.users, userID)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package jwtservice
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRevokeToken(t *testing.T) {
+	ctx := context.Background()
+	service, _ := NewJWTService("test-secret")
+
+	first, _ := service.GenerateToken(1, "test@example.com")
+	second, _ := service.GenerateToken(1, "test@example.com")
+
+	claims, err := service.ValidateToken(first)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if claims.ID == "" {
+		t.Fatal("Tokens should carry a jti")
+	}
+
+	if err := service.RevokeToken(ctx, claims); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+	if _, err := service.ValidateToken(first); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := service.ValidateToken(second); err != nil {
+		t.Errorf("Other tokens should stay valid: %v", err)
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service, _ := NewJWTService("test-secret", WithClock(func() time.Time { return now }))
+
+	old, _ := service.GenerateToken(1, "test@example.com")
+	otherUser, _ := service.GenerateToken(2, "other@example.com")
+	pair, _ := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"})
+
+	if err := service.RevokeUserTokens(ctx, 1, now); err != nil {
+		t.Fatalf("RevokeUserTokens failed: %v", err)
+	}
+
+	if _, err := service.ValidateToken(old); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked for a token issued before the cutoff, got %v", err)
+	}
+	if _, err := service.ValidateToken(otherUser); err != nil {
+		t.Errorf("Other users should be unaffected: %v", err)
+	}
+	if _, err := service.Refresh(ctx, pair.RefreshToken, loadIdentity); err != ErrInvalidToken {
+		t.Errorf("Expected refresh tokens to be revoked, got %v", err)
+	}
+	if _, err := service.ValidateToken(pair.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked for a session token, got %v", err)
+	}
+
+	// A login right after the revocation, within the same second, must work
+	relogin, _ := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"})
+	if _, err := service.ValidateToken(relogin.AccessToken); err != nil {
+		t.Errorf("Sessions started after the revocation should be valid: %v", err)
+	}
+
+	now = now.Add(time.Second)
+	fresh, _ := service.GenerateToken(1, "test@example.com")
+	if _, err := service.ValidateToken(fresh); err != nil {
+		t.Errorf("Tokens issued after the cutoff should be valid: %v", err)
+	}
+}
+
+func TestMemoryRevocationStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryRevocationStore()
+	store.now = func() time.Time { return now }
+
+	_ = store.Revoke(ctx, "jti", now.Add(time.Minute))
+	_ = store.RevokeUser(ctx, 1, now, now.Add(time.Minute))
+	_ = store.RevokeUser(ctx, 1, now.Add(-time.Hour), now.Add(time.Minute))
+
+	if revoked, _ := store.IsRevoked(ctx, "jti"); !revoked {
+		t.Error("Expected jti to be revoked")
+	}
+	if cutoff, _ := store.UserCutoff(ctx, 1); !cutoff.Equal(now) {
+		t.Errorf("An earlier cutoff must not replace a later one, got %v", cutoff)
+	}
+
+	now = now.Add(2 * time.Minute)
+	_ = store.Revoke(ctx, "other", now.Add(time.Minute))
+
+	if len(store.tokens) != 1 || len(store.users) != 0 {
+		t.Errorf("Expected expired entries to be evicted, got %d tokens and %d users", len(store.tokens), len(store.users))
+	}
+}
@@ -0,0 +1,130 @@
+package jwtservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionNotFound is returned for unknown sessions and for sessions of
+// another user
+var ErrSessionNotFound = fmt.Errorf("session not found")
+
+// Client describes where a session is used from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// Session is a login on one device. It is backed by a refresh token family
+// and shares its ID; access tokens name it in their sid claim.
+type Session struct {
+	ID     string
+	UserID int
+	Client
+	CreatedAt time.Time
+	// LastSeenAt is the last login or refresh, so it lags actual use by up
+	// to the access token lifetime
+	LastSeenAt time.Time
+	// ExpiresAt is when the newest refresh token of the session expires
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+// StartSession is IssueTokenPair recording the client the session was
+// started from
+func (j *JWTService) StartSession(ctx context.Context, identity Identity, client Client) (*TokenPair, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	now := j.now()
+	err = j.refresh.SaveSession(ctx, &Session{
+		ID:         familyID,
+		UserID:     identity.UserID,
+		Client:     client,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store session: %v", err)
+	}
+	return j.issue(ctx, identity, familyID)
+}
+
+// RefreshSession is Refresh updating the session's last-seen time and
+// client. Empty client fields keep their previous values.
+func (j *JWTService) RefreshSession(ctx context.Context, refreshToken string, load IdentityLoader, client Client) (*TokenPair, error) {
+	pair, err := j.Refresh(ctx, refreshToken, load)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.refresh.TouchSession(ctx, pair.FamilyID, j.now(), client); err != nil {
+		return nil, fmt.Errorf("failed to update session: %v", err)
+	}
+	return pair, nil
+}
+
+// Sessions returns the user's sessions that are neither revoked nor expired
+func (j *JWTService) Sessions(ctx context.Context, userID int) ([]Session, error) {
+	sessions, err := j.refresh.ListSessions(ctx, userID, j.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions. Its refresh tokens stop
+// working, and so do access tokens issued to it.
+func (j *JWTService) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	session, err := j.refresh.GetSession(ctx, sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load session: %v", err)
+	}
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return j.revokeFamily(ctx, sessionID, j.now())
+}
+
+// RevokeOtherSessions ends every session of the user except keep, e.g. the
+// one making the request. An empty keep ends them all.
+func (j *JWTService) RevokeOtherSessions(ctx context.Context, userID int, keep string) error {
+	sessions, err := j.Sessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	now := j.now()
+	for _, session := range sessions {
+		if session.ID == keep {
+			continue
+		}
+		if err := j.revokeFamily(ctx, session.ID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeFamily revokes a refresh token family together with the access
+// tokens of its session. Those expire within accessTTL, so the revocation
+// entry is only kept that long.
+func (j *JWTService) revokeFamily(ctx context.Context, familyID string, now time.Time) error {
+	if err := j.refresh.RevokeFamily(ctx, familyID, now); err != nil {
+		return fmt.Errorf("failed to revoke token family: %v", err)
+	}
+	if err := j.revoked.Revoke(ctx, sessionRevocationKey(familyID), now.Add(j.accessTTL)); err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+	return nil
+}
+
+// sessionRevocationKey is the RevocationStore key of a revoked session. The
+// prefix keeps it apart from jtis, which are plain base64url.
+func sessionRevocationKey(sessionID string) string {
+	return "sid:" + sessionID
+}
@@ -0,0 +1,92 @@
+package jwtservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := newRefreshTestService(t, &now)
+	identity := Identity{UserID: 1, Email: "test@example.com"}
+
+	phone, err := service.StartSession(ctx, identity, Client{UserAgent: "Phone", IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	claims, err := service.ValidateToken(phone.AccessToken)
+	if err != nil {
+		t.Fatalf("Access token should be valid: %v", err)
+	}
+	if claims.SessionID != phone.FamilyID {
+		t.Errorf("Expected sid %s, got %q", phone.FamilyID, claims.SessionID)
+	}
+
+	now = now.Add(time.Minute)
+	laptop, err := service.StartSession(ctx, identity, Client{UserAgent: "Laptop", IP: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	now = now.Add(time.Minute)
+	phone, err = service.RefreshSession(ctx, phone.RefreshToken, loadIdentity, Client{IP: "10.0.0.3"})
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	sessions, err := service.Sessions(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != phone.FamilyID || sessions[1].ID != laptop.FamilyID {
+		t.Fatalf("Expected phone then laptop, got %+v", sessions)
+	}
+	if got := sessions[0]; got.UserAgent != "Phone" || got.IP != "10.0.0.3" || !got.LastSeenAt.Equal(now) {
+		t.Errorf("Refresh should update IP and last seen, got %+v", got)
+	}
+	if want := now.Add(time.Hour); !sessions[0].ExpiresAt.Equal(want) {
+		t.Errorf("Expected expiry %v, got %v", want, sessions[0].ExpiresAt)
+	}
+
+	if err := service.RevokeSession(ctx, 2, laptop.FamilyID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Revoking another user's session should fail with ErrSessionNotFound, got %v", err)
+	}
+	if err := service.RevokeOtherSessions(ctx, 1, phone.FamilyID); err != nil {
+		t.Fatalf("Failed to revoke other sessions: %v", err)
+	}
+	if _, err := service.ValidateToken(laptop.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Access tokens of a revoked session should be revoked, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, laptop.RefreshToken, loadIdentity); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh tokens of a revoked session should be invalid, got %v", err)
+	}
+	if _, err := service.ValidateToken(phone.AccessToken); err != nil {
+		t.Errorf("The kept session should stay valid: %v", err)
+	}
+
+	if err := service.RevokeSession(ctx, 1, phone.FamilyID); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+	if sessions, _ := service.Sessions(ctx, 1); len(sessions) != 0 {
+		t.Errorf("Expected no sessions left, got %+v", sessions)
+	}
+	if _, err := service.ValidateToken(phone.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	service := newRefreshTestService(t, &now)
+
+	if _, err := service.IssueTokenPair(ctx, Identity{UserID: 1, Email: "test@example.com"}); err != nil {
+		t.Fatalf("Failed to issue tokens: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if sessions, _ := service.Sessions(ctx, 1); len(sessions) != 0 {
+		t.Errorf("Sessions whose refresh tokens expired should not be listed, got %+v", sessions)
+	}
+}
@@ -0,0 +1,183 @@
+// Package mail sends transactional email such as verification links
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is one email. HTML is optional; when set the message is sent as
+// multipart/alternative with Text as the fallback.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPConfig configures an SMTPMailer. Username may be empty for local relays
+// such as MailHog that need no authentication.
+type SMTPConfig struct {
+	// Addr is host:port
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	config SMTPConfig
+}
+
+// NewSMTPMailer creates a mailer for the server in config
+func NewSMTPMailer(config SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{config: config}
+}
+
+// Send delivers msg. STARTTLS is used when the server offers it.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		host, _, _ := strings.Cut(m.config.Addr, ":")
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, host)
+	}
+	data, err := encode(m.config.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(m.config.Addr, auth, m.config.From, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %v", msg.To, err)
+	}
+	return nil
+}
+
+// FileMailer writes each message as an .eml file to a directory, for
+// development
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a mailer writing to dir, which is created if needed
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %v", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes msg to a new file named after the time and recipient
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := encode(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000"), sanitizeFileName(msg.To))
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %v", err)
+	}
+	return nil
+}
+
+// ConsoleMailer prints the text part of each message, for development
+type ConsoleMailer struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// NewConsoleMailer creates a mailer printing to w
+func NewConsoleMailer(w io.Writer) *ConsoleMailer {
+	return &ConsoleMailer{w: w}
+}
+
+// Send prints msg
+func (m *ConsoleMailer) Send(ctx context.Context, msg Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, err := fmt.Fprintf(m.w, "📧 To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Text)
+	return err
+}
+
+// MemoryMailer keeps sent messages in memory, mostly for tests
+type MemoryMailer struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+// Send records msg
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the sent messages
+func (m *MemoryMailer) Messages() []Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// encode renders msg in RFC 5322 format
+func encode(from string, msg Message, date time.Time) ([]byte, error) {
+	if strings.ContainsAny(msg.To+msg.Subject+from, "\r\n") {
+		return nil, fmt.Errorf("mail headers must not contain line breaks")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n",
+		from, msg.To, msg.Subject, date.Format(time.RFC1123Z))
+
+	if msg.HTML == "" {
+		fmt.Fprintf(&buf, "Content-Type: text/plain; charset=UTF-8\r\n\r\n%s", crlf(msg.Text))
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		io.WriteString(w, crlf(part.body))
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,100 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestDefaultTemplates(t *testing.T) {
+	templates := DefaultTemplates()
+
+	for _, name := range []string{TemplateVerifyEmail, TemplateResetPassword} {
+		msg, err := templates.Render(name, "john@example.com", map[string]string{
+			"Name":      "<John>",
+			"URL":       "https://app.example.com/path?token=abc",
+			"ExpiresIn": "1h0m0s",
+		})
+		if err != nil {
+			t.Fatalf("Render(%s) failed: %v", name, err)
+		}
+		if msg.To != "john@example.com" || msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+			t.Errorf("Unexpected headers %q / %q", msg.To, msg.Subject)
+		}
+		if !strings.Contains(msg.Text, "https://app.example.com/path?token=abc") || !strings.Contains(msg.Text, "<John>") {
+			t.Errorf("Text part should contain the raw link and name:\n%s", msg.Text)
+		}
+		if !strings.Contains(msg.HTML, "&lt;John&gt;") {
+			t.Errorf("HTML part should be escaped:\n%s", msg.HTML)
+		}
+	}
+
+	if _, err := templates.Render("unknown", "john@example.com", nil); err == nil {
+		t.Error("Unknown templates should be rejected")
+	}
+	if _, err := templates.Render(TemplateVerifyEmail, "john@example.com", map[string]string{}); err == nil {
+		t.Error("Missing template data should be an error")
+	}
+}
+
+func TestParseTemplatesRequiresBlocks(t *testing.T) {
+	fsys := fstest.MapFS{"broken.tmpl": {Data: []byte(`{{define "subject"}}Hi{{end}}`)}}
+	if _, err := ParseTemplates(fsys, "*.tmpl"); err == nil {
+		t.Error("Templates without a text block should be rejected")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	date := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	data, err := encode("app@example.com", Message{To: "john@example.com", Subject: "Hi", Text: "line 1\nline 2"}, date)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	for _, want := range []string{"From: app@example.com\r\n", "Subject: Hi\r\n", "text/plain", "line 1\r\nline 2"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Encoded message is missing %q:\n%s", want, data)
+		}
+	}
+
+	data, err = encode("app@example.com", Message{To: "john@example.com", Subject: "Hi", Text: "text", HTML: "<p>html</p>"}, date)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if !strings.Contains(string(data), "multipart/alternative") || !strings.Contains(string(data), "<p>html</p>") {
+		t.Errorf("Expected a multipart message:\n%s", data)
+	}
+
+	if _, err := encode("app@example.com", Message{To: "john@example.com\r\nBcc: eve@example.com", Subject: "Hi"}, date); err == nil {
+		t.Error("Header injection should be rejected")
+	}
+}
+
+func TestFileAndConsoleMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	files, err := NewFileMailer(dir, "app@example.com")
+	if err != nil {
+		t.Fatalf("NewFileMailer failed: %v", err)
+	}
+	msg := Message{To: "john@example.com", Subject: "Hi", Text: "Hello"}
+	if err := files.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), "john@example.com.eml") {
+		t.Fatalf("Expected one .eml file, got %v", entries)
+	}
+
+	var buf bytes.Buffer
+	if err := NewConsoleMailer(&buf).Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "To: john@example.com") || !strings.Contains(buf.String(), "Hello") {
+		t.Errorf("Unexpected console output %q", buf.String())
+	}
+}
@@ -0,0 +1,107 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template names
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplateResetPassword = "reset_password"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Templates renders messages from template files. Each file defines the
+// blocks "subject" and "text", and optionally "html".
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// DefaultTemplates returns the bundled templates. It panics if they do not
+// parse, which the package tests rule out.
+func DefaultTemplates() *Templates {
+	t, err := ParseTemplates(defaultTemplates, "templates/*.tmpl")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// ParseTemplates parses the files matching pattern in fsys. A template is
+// named after its file without the .tmpl extension.
+func ParseTemplates(fsys fs.FS, pattern string) (*Templates, error) {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+
+		text, err := texttemplate.New(name).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		if text.Lookup("subject") == nil || text.Lookup("text") == nil {
+			return nil, fmt.Errorf("%s must define subject and text", file)
+		}
+		t.text[name] = text
+
+		if text.Lookup("html") != nil {
+			html, err := htmltemplate.New(name).Option("missingkey=error").Parse(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+			}
+			t.html[name] = html
+		}
+	}
+	return t, nil
+}
+
+// Render builds the message called name for to, with data available to the
+// template
+func (t *Templates) Render(name, to string, data interface{}) (Message, error) {
+	text, ok := t.text[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	msg := Message{To: to}
+	var buf bytes.Buffer
+	if err := text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s: %v", name, err)
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := text.ExecuteTemplate(&buf, "text", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s: %v", name, err)
+	}
+	msg.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	if html, ok := t.html[name]; ok {
+		buf.Reset()
+		if err := html.ExecuteTemplate(&buf, "html", data); err != nil {
+			return Message{}, fmt.Errorf("failed to render %s: %v", name, err)
+		}
+		msg.HTML = strings.TrimSpace(buf.String()) + "\n"
+	}
+	return msg, nil
+}
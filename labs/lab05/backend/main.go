@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"lab05/actiontoken"
+	"lab05/api"
+	"lab05/jwtservice"
+	"lab05/mail"
+	"lab05/oidc"
+	"lab05/oidc/oidctest"
+	"lab05/security"
+	"lab05/storage"
+	"lab05/userdomain"
+)
+
+func main() {
+	keys, err := loadKeys()
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	var users userdomain.Repository
+	var refreshStore jwtservice.RefreshStore
+	var revocationStore jwtservice.RevocationStore
+	var actionStore actiontoken.Store
+	var identities userdomain.IdentityRepository
+	if path := os.Getenv("DATABASE_PATH"); path != "" {
+		db, err := storage.OpenSQLite(path)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+		users = storage.NewSQLiteUserRepository(db)
+		identities = storage.NewSQLiteIdentityRepository(db)
+		refreshTokens := storage.NewSQLiteRefreshStore(db)
+		revocations := storage.NewSQLiteRevocationStore(db)
+		actionTokens := storage.NewSQLiteActionTokenStore(db)
+		go deleteExpired(refreshTokens, revocations, actionTokens)
+		refreshStore, revocationStore, actionStore = refreshTokens, revocations, actionTokens
+		log.Printf("Using SQLite database %s", path)
+	} else {
+		users = storage.NewMemoryUserRepository()
+		identities = storage.NewMemoryIdentityRepository()
+		refreshStore = jwtservice.NewMemoryRefreshStore()
+		revocationStore = jwtservice.NewMemoryRevocationStore()
+		actionStore = actiontoken.NewMemoryStore()
+		log.Println("DATABASE_PATH is not set, users are kept in memory")
+	}
+
+	tokens, err := jwtservice.NewJWTServiceWithKeys(keys,
+		jwtservice.WithRefreshStore(refreshStore),
+		jwtservice.WithRevocationStore(revocationStore))
+	if err != nil {
+		log.Fatalf("Failed to create JWT service: %v", err)
+	}
+
+	// New hashes use Argon2id; bcrypt hashes are upgraded on login
+	passwords := security.NewPasswordService(security.WithHasher(security.NewArgon2idHasher(security.DefaultArgon2Params)))
+	mailer, err := newMailer()
+	if err != nil {
+		log.Fatalf("Failed to set up mail: %v", err)
+	}
+	opts := []api.Option{
+		api.WithActionTokens(actiontoken.NewService(actiontoken.WithStore(actionStore))),
+		api.WithMailer(mailer),
+		api.WithIdentityRepository(identities),
+	}
+	if appURL := os.Getenv("APP_URL"); appURL != "" {
+		opts = append(opts, api.WithAppURL(appURL))
+	}
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		name, client, err := newOIDCClient(issuer)
+		if err != nil {
+			log.Fatalf("Failed to set up OIDC: %v", err)
+		}
+		opts = append(opts, api.WithOIDCProvider(name, client))
+	}
+	handler := api.NewHandler(users, passwords, tokens, opts...)
+	router := handler.SetupRoutes()
+
+	addr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      router,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	log.Printf("Starting server on %s", addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
+
+// expiringStore is a SQLite store that needs periodic pruning
+type expiringStore interface {
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// deleteExpired prunes expired tokens and revocations once an hour
+func deleteExpired(stores ...expiringStore) {
+	for range time.Tick(time.Hour) {
+		for _, store := range stores {
+			if err := store.DeleteExpired(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to delete expired entries: %v", err)
+			}
+		}
+	}
+}
+
+// newMailer sends mail through SMTP_ADDR if set, e.g. localhost:1025 for
+// MailHog, with optional SMTP_USERNAME and SMTP_PASSWORD. Otherwise it writes
+// .eml files to MAIL_DIR, or prints messages to the console.
+func newMailer() (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		log.Printf("Sending mail through %s", addr)
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}), nil
+	}
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		log.Printf("Writing mail to %s", dir)
+		return mail.NewFileMailer(dir, from)
+	}
+	log.Println("SMTP_ADDR and MAIL_DIR are not set, printing mail to the console")
+	return mail.NewConsoleMailer(os.Stdout), nil
+}
+
+// newOIDCClient configures sign-in with the provider at issuer, named by
+// OIDC_PROVIDER (default "oidc"), using OIDC_CLIENT_ID, OIDC_CLIENT_SECRET and
+// OIDC_REDIRECT_URL. The issuer "mock" starts the in-process mock provider,
+// which signs in alice@example.com without asking.
+func newOIDCClient(issuer string) (string, *oidc.Client, error) {
+	name := os.Getenv("OIDC_PROVIDER")
+	if name == "" {
+		name = "oidc"
+	}
+	config := oidc.Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+	}
+	if config.RedirectURL == "" {
+		config.RedirectURL = "http://localhost:8080/auth/oidc/" + name + "/callback"
+	}
+	if issuer == "mock" {
+		provider := oidctest.NewProvider()
+		config.Issuer, config.ClientID, config.ClientSecret = provider.Issuer, provider.ClientID, provider.ClientSecret
+		log.Printf("Started mock OIDC provider at %s", provider.Issuer)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := oidc.NewClient(ctx, config)
+	if err != nil {
+		return "", nil, err
+	}
+	log.Printf("Sign-in with %s enabled at /auth/oidc/%s/login", config.Issuer, name)
+	return name, client, nil
+}
+
+// loadKeys builds the key ring from JWT_SIGNING_KEY, a PEM private key, and
+// JWT_VERIFY_KEYS, comma-separated PEM files of retired keys that should
+// still validate tokens. Without a signing key it falls back to HS256 with
+// JWT_SECRET.
+func loadKeys() (*jwtservice.KeyRing, error) {
+	path := os.Getenv("JWT_SIGNING_KEY")
+	if path == "" {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			secret = "dev-secret-change-me"
+			log.Println("⚠️  JWT_SIGNING_KEY and JWT_SECRET are not set, using an insecure development secret")
+		}
+		key, err := jwtservice.NewHMACKey("", []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		return jwtservice.NewKeyRing(key)
+	}
+
+	keys := make([]*jwtservice.Key, 0)
+	for _, file := range append([]string{path}, strings.Split(os.Getenv("JWT_VERIFY_KEYS"), ",")...) {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwtservice.ParseKeyPEM("", data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		keys = append(keys, key)
+	}
+	if !keys[0].CanSign() {
+		return nil, fmt.Errorf("%s: a private key is required for signing", path)
+	}
+	log.Printf("Signing tokens with %s key %s", keys[0].Algorithm, keys[0].ID)
+	return jwtservice.NewKeyRing(keys...)
+}
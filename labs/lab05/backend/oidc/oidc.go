@@ -0,0 +1,320 @@
+// Package oidc is an OpenID Connect relying party for the authorization code
+// flow with PKCE (RFC 7636). It discovers the provider's endpoints, exchanges
+// codes for tokens and validates ID tokens against the provider's JWKS.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"lab05/jwtservice"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrInvalidIDToken matches every ID token validation failure
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+const (
+	// clockSkew is how far the provider's clock may be off from ours
+	clockSkew = time.Minute
+	// jwksRefreshInterval limits how often an unknown kid refetches the JWKS
+	jwksRefreshInterval = time.Minute
+	// maxResponseSize caps what is read from the provider
+	maxResponseSize = 1 << 20
+)
+
+// Config describes the client registration at a provider
+type Config struct {
+	// Issuer is the provider's issuer URL; discovery reads
+	// Issuer + "/.well-known/openid-configuration"
+	Issuer   string
+	ClientID string
+	// ClientSecret is empty for public clients, which rely on PKCE alone
+	ClientSecret string
+	RedirectURL  string
+	// Scopes defaults to openid, email and profile
+	Scopes []string
+}
+
+// Discovery is the subset of the provider metadata the client uses
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint,omitempty"`
+	ScopesSupported       []string `json:"scopes_supported,omitempty"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported,omitempty"`
+}
+
+// Token is the token endpoint's response
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+}
+
+// IDTokenClaims are the claims of a validated ID token
+type IDTokenClaims struct {
+	Nonce         string `json:"nonce,omitempty"`
+	AuthorizedBy  string `json:"azp,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Client talks to one provider
+type Client struct {
+	config    Config
+	discovery Discovery
+	http      *http.Client
+	now       func() time.Time
+
+	mutex     sync.Mutex
+	keys      *jwtservice.KeyRing
+	keysFetch time.Time
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the client used to reach the provider. The default
+// is an http.Client with a 10 second timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) { c.http = client }
+}
+
+// WithClock replaces time.Now, for tests
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) { c.now = now }
+}
+
+// NewClient fetches the provider's discovery document and returns a client
+// for it. The document must name config.Issuer as its issuer.
+func NewClient(ctx context.Context, config Config, opts ...Option) (*Client, error) {
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("oidc: issuer, client ID and redirect URL are required")
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	c := &Client{config: config, http: &http.Client{Timeout: 10 * time.Second}, now: time.Now}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.getJSON(ctx, config.Issuer+"/.well-known/openid-configuration", &c.discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %v", err)
+	}
+	if c.discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("failed to discover provider: issuer %q does not match %q", c.discovery.Issuer, config.Issuer)
+	}
+	if c.discovery.AuthorizationEndpoint == "" || c.discovery.TokenEndpoint == "" || c.discovery.JWKSURI == "" {
+		return nil, errors.New("failed to discover provider: endpoints missing")
+	}
+	return c, nil
+}
+
+// Discovery returns the provider metadata
+func (c *Client) Discovery() Discovery {
+	return c.discovery
+}
+
+// AuthCodeURL returns the provider URL to send the user to. state and nonce
+// must be random per login; challenge is from NewPKCE.
+func (c *Client) AuthCodeURL(state, nonce, challenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.config.ClientID},
+		"redirect_uri":          {c.config.RedirectURL},
+		"scope":                 {strings.Join(c.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(c.discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return c.discovery.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange trades an authorization code and its PKCE verifier for tokens.
+// The ID token is not validated; pass it to VerifyIDToken.
+func (c *Client) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if c.config.ClientSecret == "" {
+		form.Set("client_id", c.config.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	}
+
+	var token Token
+	if err := c.do(req, &token); err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %v", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("failed to exchange code: no ID token in response")
+	}
+	return &token, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, lifetime and nonce
+// of an ID token and returns its claims
+func (c *Client) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	keys, err := c.jwks(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	claims := &IDTokenClaims{}
+	err = keys.Verify(rawIDToken, claims)
+	if errors.Is(err, jwtservice.ErrUnknownKey) {
+		// The provider may have rotated its keys
+		if keys, err = c.jwks(ctx, true); err != nil {
+			return nil, err
+		}
+		err = keys.Verify(rawIDToken, claims)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	now := c.now()
+	switch {
+	case claims.Issuer != c.config.Issuer:
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !claims.VerifyAudience(c.config.ClientID, true):
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedBy != c.config.ClientID:
+		return nil, fmt.Errorf("%w: azp mismatch", ErrInvalidIDToken)
+	case claims.ExpiresAt == nil || !now.Before(claims.ExpiresAt.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case claims.IssuedAt != nil && claims.IssuedAt.After(now.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	case nonce == "" || claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// jwks returns the provider's keys, fetching them on first use. refresh
+// refetches them unless that happened within jwksRefreshInterval.
+func (c *Client) jwks(ctx context.Context, refresh bool) (*jwtservice.KeyRing, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.keys != nil && (!refresh || c.now().Sub(c.keysFetch) < jwksRefreshInterval) {
+		return c.keys, nil
+	}
+	var set jwtservice.JWKS
+	if err := c.getJSON(ctx, c.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %v", err)
+	}
+	// Keys of types we cannot use, such as encryption keys, are skipped
+	var keys []*jwtservice.Key
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.Key(); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	ring, err := jwtservice.NewKeyRing(keys...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider keys: %v", err)
+	}
+	c.keys, c.keysFetch = ring, c.now()
+	return ring, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return c.do(req, dst)
+}
+
+// do sends req and decodes a JSON response, turning OAuth error responses
+// into errors
+func (c *Client) do(req *http.Request, dst interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			return fmt.Errorf("%s: %s %s", req.URL.Path, oauthErr.Error, oauthErr.Description)
+		}
+		return fmt.Errorf("%s: unexpected status %d", req.URL.Path, resp.StatusCode)
+	}
+	return json.Unmarshal(body, dst)
+}
+
+// NewPKCE returns a random code verifier and its S256 challenge
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, S256(verifier), nil
+}
+
+// S256 is the PKCE challenge of verifier
+func S256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomString returns n random bytes as unpadded base64url, for states,
+// nonces and verifiers
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random string: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,203 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"lab05/oidc"
+	"lab05/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const redirectURL = "http://localhost:8080/auth/oidc/test/callback"
+
+func newClient(t *testing.T, provider *oidctest.Provider) *oidc.Client {
+	t.Helper()
+	client, err := oidc.NewClient(context.Background(), oidc.Config{
+		Issuer:       provider.Issuer,
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  redirectURL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+// login runs the browser part of the flow and exchanges the code
+func login(t *testing.T, provider *oidctest.Provider, client *oidc.Client) (*oidc.AuthRequest, *oidc.Token, error) {
+	t.Helper()
+	req, err := oidc.NewAuthRequest("test", oidc.DefaultStateTTL)
+	if err != nil {
+		t.Fatalf("Failed to create auth request: %v", err)
+	}
+	callback, err := provider.Authorize(client.AuthCodeURL(req.State, req.Nonce, oidc.S256(req.Verifier)))
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if !strings.HasPrefix(callback.String(), redirectURL+"?") || callback.Query().Get("state") != req.State {
+		t.Fatalf("Unexpected callback %s", callback)
+	}
+	token, err := client.Exchange(context.Background(), callback.Query().Get("code"), req.Verifier)
+	return req, token, err
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	provider := oidctest.NewProvider()
+	defer provider.Close()
+	client := newClient(t, provider)
+
+	req, token, err := login(t, provider, client)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	claims, err := client.VerifyIDToken(context.Background(), token.IDToken, req.Nonce)
+	if err != nil {
+		t.Fatalf("ID token should be valid: %v", err)
+	}
+	if claims.Subject != "alice" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	if _, err := client.VerifyIDToken(context.Background(), token.IDToken, "other-nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Expected a nonce mismatch, got %v", err)
+	}
+}
+
+func TestExchangeRequiresVerifier(t *testing.T) {
+	provider := oidctest.NewProvider()
+	defer provider.Close()
+	client := newClient(t, provider)
+
+	req, err := oidc.NewAuthRequest("test", oidc.DefaultStateTTL)
+	if err != nil {
+		t.Fatalf("Failed to create auth request: %v", err)
+	}
+	callback, err := provider.Authorize(client.AuthCodeURL(req.State, req.Nonce, oidc.S256(req.Verifier)))
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	code := callback.Query().Get("code")
+	if _, err := client.Exchange(context.Background(), code, "wrong-verifier"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected invalid_grant for a wrong verifier, got %v", err)
+	}
+	if _, err := client.Exchange(context.Background(), code, req.Verifier); err == nil {
+		t.Error("A code must not be usable after a failed exchange")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		hook func(*oidc.IDTokenClaims)
+	}{
+		{"wrong issuer", func(c *oidc.IDTokenClaims) { c.Issuer = "https://evil.example.com" }},
+		{"wrong audience", func(c *oidc.IDTokenClaims) { c.Audience = jwt.ClaimStrings{"other-client"} }},
+		{"foreign azp", func(c *oidc.IDTokenClaims) {
+			c.Audience = append(c.Audience, "other-client")
+			c.AuthorizedBy = "other-client"
+		}},
+		{"expired", func(c *oidc.IDTokenClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }},
+		{"issued in the future", func(c *oidc.IDTokenClaims) { c.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour)) }},
+		{"no subject", func(c *oidc.IDTokenClaims) { c.Subject = "" }},
+	}
+
+	provider := oidctest.NewProvider()
+	defer provider.Close()
+	client := newClient(t, provider)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.IDTokenHook = tt.hook
+			req, token, err := login(t, provider, client)
+			if err != nil {
+				t.Fatalf("Exchange failed: %v", err)
+			}
+			if _, err := client.VerifyIDToken(context.Background(), token.IDToken, req.Nonce); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("Expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenAfterKeyRotation(t *testing.T) {
+	provider := oidctest.NewProvider()
+	defer provider.Close()
+	client := newClient(t, provider)
+
+	// Fetch and cache the original key set
+	req, token, err := login(t, provider, client)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if _, err := client.VerifyIDToken(context.Background(), token.IDToken, req.Nonce); err != nil {
+		t.Fatalf("ID token should be valid: %v", err)
+	}
+
+	if err := provider.RotateKey(); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	req, token, err = login(t, provider, client)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	// The cache is younger than the refresh interval, so the new key is not
+	// fetched yet
+	if _, err := client.VerifyIDToken(context.Background(), token.IDToken, req.Nonce); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Expected the unknown key to be rejected within the refresh interval, got %v", err)
+	}
+
+	later := oidc.WithClock(func() time.Time { return time.Now().Add(2 * time.Minute) })
+	client, err = oidc.NewClient(context.Background(), oidc.Config{
+		Issuer: provider.Issuer, ClientID: provider.ClientID, ClientSecret: provider.ClientSecret, RedirectURL: redirectURL,
+	}, later)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.VerifyIDToken(context.Background(), token.IDToken, req.Nonce); err != nil {
+		t.Errorf("Tokens signed with the rotated key should verify: %v", err)
+	}
+}
+
+func TestDiscoveryChecksIssuer(t *testing.T) {
+	provider := oidctest.NewProvider()
+	defer provider.Close()
+
+	_, err := oidc.NewClient(context.Background(), oidc.Config{
+		Issuer:      strings.Replace(provider.Issuer, "127.0.0.1", "localhost", 1),
+		ClientID:    provider.ClientID,
+		RedirectURL: redirectURL,
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Expected an issuer mismatch, got %v", err)
+	}
+}
+
+func TestStateStore(t *testing.T) {
+	ctx := context.Background()
+	store := oidc.NewMemoryStateStore()
+	req, err := oidc.NewAuthRequest("test", oidc.DefaultStateTTL)
+	if err != nil {
+		t.Fatalf("Failed to create auth request: %v", err)
+	}
+	if err := store.Save(ctx, req); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	got, err := store.Take(ctx, req.State)
+	if err != nil || got.Nonce != req.Nonce {
+		t.Fatalf("Expected the saved request, got %+v, %v", got, err)
+	}
+	if _, err := store.Take(ctx, req.State); !errors.Is(err, oidc.ErrStateNotFound) {
+		t.Errorf("A state must work only once, got %v", err)
+	}
+
+	expired, _ := oidc.NewAuthRequest("test", -time.Second)
+	store.Save(ctx, expired)
+	if _, err := store.Take(ctx, expired.State); !errors.Is(err, oidc.ErrStateNotFound) {
+		t.Errorf("Expected expired state to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+// Package oidctest runs an in-process OpenID Connect provider, in the spirit
+// of net/http/httptest, so login flows can be tested offline. It signs in a
+// preset user without prompting and enforces PKCE.
+package oidctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"lab05/jwtservice"
+	"lab05/oidc"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// codeTTL is how long an authorization code can be exchanged
+const codeTTL = time.Minute
+
+// User is who the provider signs in
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is a running mock provider
+type Provider struct {
+	// Issuer is the provider's base URL
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// IDTokenHook, if set, may change the claims of each ID token before it
+	// is signed, e.g. to test how clients handle bad tokens
+	IDTokenHook func(*oidc.IDTokenClaims)
+
+	server *httptest.Server
+	keys   *jwtservice.KeyRing
+
+	mutex sync.Mutex
+	user  User
+	codes map[string]grant
+}
+
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        User
+	expiresAt   time.Time
+}
+
+// NewProvider starts a provider with client "test-client" and secret
+// "test-secret" that signs in alice@example.com. Call Close when done.
+func NewProvider() *Provider {
+	key, err := jwtservice.GenerateKey("", jwtservice.AlgRS256)
+	if err != nil {
+		panic(fmt.Sprintf("oidctest: failed to generate key: %v", err))
+	}
+	keys, err := jwtservice.NewKeyRing(key)
+	if err != nil {
+		panic(fmt.Sprintf("oidctest: failed to create key ring: %v", err))
+	}
+
+	p := &Provider{
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		keys:         keys,
+		user:         User{Subject: "alice", Email: "alice@example.com", EmailVerified: true, Name: "Alice Example"},
+		codes:        make(map[string]grant),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	p.Issuer = p.server.URL
+	return p
+}
+
+// Close shuts the provider down
+func (p *Provider) Close() {
+	p.server.Close()
+}
+
+// SetUser changes who the next login signs in
+func (p *Provider) SetUser(user User) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.user = user
+}
+
+// RotateKey makes a new key sign ID tokens; the old one stays in the JWKS
+func (p *Provider) RotateKey() error {
+	key, err := jwtservice.GenerateKey("", jwtservice.AlgRS256)
+	if err != nil {
+		return err
+	}
+	return p.keys.Rotate(key)
+}
+
+// SignIDToken signs arbitrary claims with the provider's key
+func (p *Provider) SignIDToken(claims *oidc.IDTokenClaims) (string, error) {
+	return p.keys.Sign(claims)
+}
+
+// Authorize opens authURL as a browser would and returns the redirect back
+// to the client, which carries the code and state or an error
+func (p *Provider) Authorize(authURL string) (*url.URL, error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("oidctest: authorize returned status %d", resp.StatusCode)
+	}
+	return url.Parse(resp.Header.Get("Location"))
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Discovery{
+		Issuer:                p.Issuer,
+		AuthorizationEndpoint: p.Issuer + "/authorize",
+		TokenEndpoint:         p.Issuer + "/token",
+		JWKSURI:               p.Issuer + "/jwks",
+		ScopesSupported:       []string{"openid", "email", "profile"},
+		CodeChallengeMethods:  []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, p.keys.JWKS())
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() || query.Get("client_id") != p.ClientID {
+		// Errors are not sent to unverified redirect URIs
+		http.Error(w, "unknown client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	values := url.Values{"state": {query.Get("state")}}
+	switch {
+	case query.Get("response_type") != "code":
+		values.Set("error", "unsupported_response_type")
+	case !strings.Contains(" "+query.Get("scope")+" ", " openid "):
+		values.Set("error", "invalid_scope")
+	case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+		values.Set("error", "invalid_request")
+		values.Set("error_description", "PKCE with S256 is required")
+	default:
+		code, err := oidc.RandomString(16)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p.mutex.Lock()
+		p.codes[code] = grant{
+			clientID:    p.ClientID,
+			redirectURI: redirectURI.String(),
+			challenge:   query.Get("code_challenge"),
+			nonce:       query.Get("nonce"),
+			user:        p.user,
+			expiresAt:   time.Now().Add(codeTTL),
+		}
+		p.mutex.Unlock()
+		values.Set("code", code)
+	}
+
+	target := *redirectURI
+	target.RawQuery = values.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	if !p.authenticateClient(r) {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mutex.Lock()
+	g, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mutex.Unlock()
+	if !ok || time.Now().After(g.expiresAt) ||
+		g.redirectURI != r.PostForm.Get("redirect_uri") ||
+		g.challenge != oidc.S256(r.PostForm.Get("code_verifier")) {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	idToken, err := p.issueIDToken(g)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	accessToken, err := oidc.RandomString(32)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, oidc.Token{AccessToken: accessToken, TokenType: "Bearer", ExpiresIn: 3600, IDToken: idToken})
+}
+
+// authenticateClient accepts client_secret_basic, client_secret_post, or
+// just the client ID for public clients when no secret is configured
+func (p *Provider) authenticateClient(r *http.Request) bool {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	return id == p.ClientID && (p.ClientSecret == "" || secret == p.ClientSecret)
+}
+
+func (p *Provider) issueIDToken(g grant) (string, error) {
+	now := time.Now()
+	claims := &oidc.IDTokenClaims{
+		Nonce:         g.nonce,
+		AuthorizedBy:  g.clientID,
+		Email:         g.user.Email,
+		EmailVerified: g.user.EmailVerified,
+		Name:          g.user.Name,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.Issuer,
+			Subject:   g.user.Subject,
+			Audience:  jwt.ClaimStrings{g.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	if p.IDTokenHook != nil {
+		p.IDTokenHook(claims)
+	}
+	return p.keys.Sign(claims)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
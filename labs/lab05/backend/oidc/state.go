@@ -0,0 +1,104 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrStateNotFound is returned for unknown, used or expired states
+var ErrStateNotFound = errors.New("login state not found")
+
+// DefaultStateTTL is how long the user has to complete a login at the
+// provider
+const DefaultStateTTL = 10 * time.Minute
+
+// AuthRequest is what is remembered between sending the user to the
+// provider and the callback. State is echoed back by the provider; the
+// other values never leave the server.
+type AuthRequest struct {
+	State    string
+	Nonce    string
+	Verifier string
+	// Provider is the name of the provider the login was started with
+	Provider  string
+	ExpiresAt time.Time
+}
+
+// NewAuthRequest creates a login at provider with fresh random values
+func NewAuthRequest(provider string, ttl time.Duration) (*AuthRequest, error) {
+	var values [3]string
+	for i := range values {
+		value, err := RandomString(32)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return &AuthRequest{
+		State:     values[0],
+		Nonce:     values[1],
+		Verifier:  values[2],
+		Provider:  provider,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// StateStore keeps pending logins
+type StateStore interface {
+	Save(ctx context.Context, req *AuthRequest) error
+	// Take removes and returns the request, so a state works only once
+	Take(ctx context.Context, state string) (*AuthRequest, error)
+}
+
+// MemoryStateStore is a StateStore kept in process memory. Logins started
+// on one instance must then finish on the same one.
+type MemoryStateStore struct {
+	mutex     sync.Mutex
+	requests  map[string]AuthRequest
+	lastSweep time.Time
+}
+
+// NewMemoryStateStore creates an empty store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{requests: make(map[string]AuthRequest)}
+}
+
+// Save stores a copy of req
+func (s *MemoryStateStore) Save(ctx context.Context, req *AuthRequest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sweep()
+	s.requests[req.State] = *req
+	return nil
+}
+
+// Take removes the request with the given state
+func (s *MemoryStateStore) Take(ctx context.Context, state string) (*AuthRequest, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	req, ok := s.requests[state]
+	delete(s.requests, state)
+	if !ok || !time.Now().Before(req.ExpiresAt) {
+		return nil, ErrStateNotFound
+	}
+	return &req, nil
+}
+
+// sweep drops expired requests at most once a minute
+func (s *MemoryStateStore) sweep() {
+	now := time.Now()
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for state, req := range s.requests {
+		if !now.Before(req.ExpiresAt) {
+			delete(s.requests, state)
+		}
+	}
+}
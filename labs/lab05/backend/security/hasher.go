@@ -0,0 +1,181 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUnknownHash is returned for hashes no configured hasher recognizes
+	ErrUnknownHash = errors.New("unknown password hash format")
+	// ErrMalformedHash is returned for hashes that look like a known format
+	// but cannot be decoded
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// Hasher hashes passwords with one algorithm. Hashes are self-describing
+// strings that record the algorithm and its parameters, so a hasher can
+// verify hashes made with other parameters than its own.
+type Hasher interface {
+	// Hash returns the encoded hash of password
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash
+	Verify(password, hash string) (bool, error)
+	// Identifies reports whether hash was produced by this algorithm
+	Identifies(hash string) bool
+	// NeedsRehash reports whether hash was made with other parameters than
+	// the hasher's current ones
+	NeedsRehash(hash string) bool
+}
+
+// BcryptHasher hashes passwords with bcrypt
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a bcrypt hasher with the given cost
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{cost: cost}
+}
+
+// Hash returns a bcrypt hash in the usual $2a$ format
+func (b *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify reports whether password matches the bcrypt hash
+func (b *BcryptHasher) Verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+}
+
+// Identifies reports whether hash is a bcrypt hash
+func (b *BcryptHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// NeedsRehash reports whether hash uses a different cost
+func (b *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.cost
+}
+
+// Argon2Params are the Argon2id cost parameters
+type Argon2Params struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the second recommended option of RFC 9106
+// with a reduced lane count
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+const argon2Prefix = "$argon2id$"
+
+// Argon2idHasher hashes passwords with Argon2id. Hashes use the PHC string
+// format, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+// NewArgon2idHasher creates an Argon2id hasher with the given parameters
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+// Hash returns an Argon2id hash with a random salt
+func (a *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, a.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %v", err)
+	}
+	p := a.params
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version,
+		p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether password matches the Argon2id hash, using the
+// parameters recorded in it
+func (a *Argon2idHasher) Verify(password, hash string) (bool, error) {
+	p, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// Identifies reports whether hash is an Argon2id hash
+func (a *Argon2idHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, argon2Prefix)
+}
+
+// NeedsRehash reports whether hash was made with other parameters
+func (a *Argon2idHasher) NeedsRehash(hash string) bool {
+	p, _, _, err := decodeArgon2(hash)
+	return err != nil || p != a.params
+}
+
+// decodeArgon2 parses a PHC formatted Argon2id hash
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("%w: unsupported argon2 version %d", ErrMalformedHash, version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,129 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testArgon2Params keep the tests fast
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2Params)
+
+	hash, err := hasher.Hash("password123")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Unexpected hash format: %s", hash)
+	}
+	if !hasher.Identifies(hash) {
+		t.Error("Hasher should identify its own hashes")
+	}
+
+	other, _ := hasher.Hash("password123")
+	if other == hash {
+		t.Error("Hashes of the same password should use different salts")
+	}
+
+	if ok, err := hasher.Verify("password123", hash); !ok || err != nil {
+		t.Errorf("Verify(correct) = %v, %v", ok, err)
+	}
+	if ok, err := hasher.Verify("password124", hash); ok || err != nil {
+		t.Errorf("Verify(wrong) = %v, %v", ok, err)
+	}
+
+	if hasher.NeedsRehash(hash) {
+		t.Error("Hash with current parameters should not need a rehash")
+	}
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	if !NewArgon2idHasher(stronger).NeedsRehash(hash) {
+		t.Error("Hash with weaker parameters should need a rehash")
+	}
+	if ok, err := NewArgon2idHasher(stronger).Verify("password123", hash); !ok || err != nil {
+		t.Error("Verify should use the parameters stored in the hash")
+	}
+}
+
+func TestArgon2idHasherMalformed(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2Params)
+
+	for _, hash := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5",
+	} {
+		if _, err := hasher.Verify("password123", hash); !errors.Is(err, ErrMalformedHash) {
+			t.Errorf("Verify(%q) error = %v, want ErrMalformedHash", hash, err)
+		}
+		if !hasher.NeedsRehash(hash) {
+			t.Errorf("Malformed hash %q should need a rehash", hash)
+		}
+	}
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher := NewBcryptHasher(4)
+
+	hash, err := hasher.Hash("password123")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !hasher.Identifies(hash) || hasher.Identifies("$argon2id$v=19") {
+		t.Error("Identifies should only accept bcrypt hashes")
+	}
+	if ok, err := hasher.Verify("password123", hash); !ok || err != nil {
+		t.Errorf("Verify(correct) = %v, %v", ok, err)
+	}
+	if ok, err := hasher.Verify("wrong", hash); ok || err != nil {
+		t.Errorf("Verify(wrong) = %v, %v", ok, err)
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Error("Hash with current cost should not need a rehash")
+	}
+	if !NewBcryptHasher(5).NeedsRehash(hash) {
+		t.Error("Hash with a lower cost should need a rehash")
+	}
+}
+
+func TestPasswordService_Rehash(t *testing.T) {
+	legacy := NewPasswordService(WithHasher(NewBcryptHasher(4)))
+	service := NewPasswordService(WithHasher(NewArgon2idHasher(testArgon2Params)))
+
+	bcryptHash, err := legacy.HashPassword("password123")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if !service.NeedsRehash(bcryptHash) {
+		t.Error("bcrypt hash should need a rehash when Argon2id is configured")
+	}
+
+	ok, newHash, err := service.VerifyAndRehash("wrong", bcryptHash)
+	if ok || newHash != "" || err != nil {
+		t.Errorf("VerifyAndRehash(wrong) = %v, %q, %v", ok, newHash, err)
+	}
+
+	ok, newHash, err = service.VerifyAndRehash("password123", bcryptHash)
+	if !ok || err != nil {
+		t.Fatalf("VerifyAndRehash(correct) = %v, %v", ok, err)
+	}
+	if !strings.HasPrefix(newHash, "$argon2id$") {
+		t.Fatalf("Expected an Argon2id hash, got %q", newHash)
+	}
+	if !service.VerifyPassword("password123", newHash) {
+		t.Error("Upgraded hash should verify")
+	}
+
+	ok, again, err := service.VerifyAndRehash("password123", newHash)
+	if !ok || again != "" || err != nil {
+		t.Errorf("Current hash should not be rehashed, got %v, %q, %v", ok, again, err)
+	}
+
+	if _, _, err := service.VerifyAndRehash("password123", "plaintext"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("Expected ErrUnknownHash, got %v", err)
+	}
+}
@@ -1,55 +1,110 @@
 package security
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
-	_ "regexp"
-
-	_ "golang.org/x/crypto/bcrypt"
+	"sync"
 )
 
+// bcryptCost is the work factor used for new hashes by default
+const bcryptCost = 10
+
 // PasswordService handles password operations
-type PasswordService struct{}
-
-// TODO: Implement NewPasswordService function
-// NewPasswordService creates a new password service
-func NewPasswordService() *PasswordService {
-	// TODO: Implement this function
-	// Return a new PasswordService instance
-	return nil
-}
-
-// TODO: Implement HashPassword method
-// HashPassword hashes a password using bcrypt
-// Requirements:
-// - password must not be empty
-// - use bcrypt with cost 10
-// - return the hashed password as string
+type PasswordService struct {
+	// hasher makes new hashes; hashers verify existing ones
+	hasher  Hasher
+	hashers []Hasher
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// Option configures a PasswordService
+type Option func(*PasswordService)
+
+// WithHasher sets the hasher used for new hashes. Hashes made by the other
+// supported algorithms still verify and are reported by NeedsRehash.
+func WithHasher(hasher Hasher) Option {
+	return func(p *PasswordService) { p.hasher = hasher }
+}
+
+// NewPasswordService creates a new password service. New hashes use bcrypt
+// with cost 10 unless WithHasher is given; bcrypt and Argon2id hashes both
+// verify.
+func NewPasswordService(opts ...Option) *PasswordService {
+	p := &PasswordService{hasher: NewBcryptHasher(bcryptCost)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.hashers = []Hasher{p.hasher, NewBcryptHasher(bcryptCost), NewArgon2idHasher(DefaultArgon2Params)}
+	return p
+}
+
+// HashPassword hashes a password with the configured hasher
 func (p *PasswordService) HashPassword(password string) (string, error) {
-	// TODO: Implement password hashing
-	// Use golang.org/x/crypto/bcrypt.GenerateFromPassword
-	return "", errors.New("not implemented")
+	if password == "" {
+		return "", errors.New("password cannot be empty")
+	}
+	return p.hasher.Hash(password)
 }
 
-// TODO: Implement VerifyPassword method
-// VerifyPassword checks if password matches hash
-// Requirements:
-// - password and hash must not be empty
-// - return true if password matches hash
-// - return false if password doesn't match
+// VerifyPassword reports whether password matches hash
 func (p *PasswordService) VerifyPassword(password, hash string) bool {
-	// TODO: Implement password verification
-	// Use bcrypt.CompareHashAndPassword
-	// Return true only if passwords match exactly
-	return false
+	ok, err := p.verify(password, hash)
+	return ok && err == nil
+}
+
+// NeedsRehash reports whether hash was made by another algorithm or with
+// other parameters than the configured hasher uses
+func (p *PasswordService) NeedsRehash(hash string) bool {
+	return !p.hasher.Identifies(hash) || p.hasher.NeedsRehash(hash)
+}
+
+// VerifyAndRehash checks password against hash like VerifyPassword. When it
+// matches and hash is outdated, it also returns a fresh hash of password
+// that the caller should store; otherwise newHash is empty.
+func (p *PasswordService) VerifyAndRehash(password, hash string) (ok bool, newHash string, err error) {
+	ok, err = p.verify(password, hash)
+	if !ok || err != nil || !p.NeedsRehash(hash) {
+		return ok, "", err
+	}
+	newHash, err = p.HashPassword(password)
+	if err != nil {
+		return true, "", err
+	}
+	return true, newHash, nil
+}
+
+// DummyHash returns the hash of a random password, made once by the
+// configured hasher. Verifying against it when an account does not exist
+// takes as long as a real check, so response times do not reveal which
+// accounts exist.
+func (p *PasswordService) DummyHash() string {
+	p.dummyOnce.Do(func() {
+		random := make([]byte, 18)
+		if _, err := rand.Read(random); err != nil {
+			return
+		}
+		p.dummyHash, _ = p.HashPassword(base64.RawURLEncoding.EncodeToString(random))
+	})
+	return p.dummyHash
+}
+
+func (p *PasswordService) verify(password, hash string) (bool, error) {
+	if password == "" || hash == "" {
+		return false, nil
+	}
+	for _, hasher := range p.hashers {
+		if hasher.Identifies(hash) {
+			return hasher.Verify(password, hash)
+		}
+	}
+	return false, ErrUnknownHash
 }
 
-// TODO: Implement ValidatePassword function
-// ValidatePassword checks if password meets basic requirements
-// Requirements:
-// - At least 6 characters
-// - Contains at least one letter and one number
+// ValidatePassword checks password against BasicPolicy: at least 6
+// characters including a letter and a number
 func ValidatePassword(password string) error {
-	// TODO: Implement password validation
-	// Check length and basic complexity requirements
-	return errors.New("not implemented")
+	return BasicPolicy().Validate(password)
 }
@@ -0,0 +1,254 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules reported in violations. Clients can key their messages on these.
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleLetter       = "letter"
+	RuleUpper        = "upper"
+	RuleLower        = "lower"
+	RuleDigit        = "digit"
+	RuleSymbol       = "symbol"
+	RulePersonalInfo = "personal_info"
+	RuleCommon       = "common"
+)
+
+// minPersonalLength is the shortest part of an email or name that counts as
+// personal information; shorter fragments like "jo" would reject too much
+const minPersonalLength = 3
+
+// Violation is one rule a password breaks
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password breaks
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// RangeSource looks up password hashes by prefix in the style of the Pwned
+// Passwords range API: given the first 5 hex characters of a SHA-1 hash it
+// returns the remaining 35 of every known hash with that prefix. Only the
+// prefix ever leaves the caller, so a remote source learns nothing useful.
+type RangeSource interface {
+	Range(prefix string) ([]string, error)
+}
+
+// PasswordPolicy describes what a password must look like. The zero value
+// accepts any password.
+type PasswordPolicy struct {
+	MinLength int `json:"min_length"`
+	// MaxLength of 0 means no limit. Lengths are counted in characters.
+	MaxLength     int  `json:"max_length"`
+	RequireLetter bool `json:"require_letter"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	// RejectPersonalInfo rejects passwords containing the user's name or the
+	// local part of their email
+	RejectPersonalInfo bool `json:"reject_personal_info"`
+	// Common rejects passwords found in it when set
+	Common RangeSource `json:"-"`
+}
+
+// BasicPolicy requires 6 characters with a letter and a number
+func BasicPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 6, RequireLetter: true, RequireDigit: true}
+}
+
+// DefaultPolicy is used for account passwords: 8 to 128 characters with
+// upper and lower case letters and a number, not based on the user's name or
+// email and not in the bundled list of common passwords
+func DefaultPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:          8,
+		MaxLength:          128,
+		RequireUpper:       true,
+		RequireLower:       true,
+		RequireDigit:       true,
+		RejectPersonalInfo: true,
+		Common:             CommonPasswords(),
+	}
+}
+
+// Validate returns a *PolicyError listing every violated rule, or nil.
+// personal holds the user's email, name and similar values that the
+// password must not contain.
+func (p PasswordPolicy) Validate(password string, personal ...string) error {
+	violations, err := p.Check(password, personal...)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// Check returns every rule password violates. The error is only set when
+// the common password source fails.
+func (p PasswordPolicy) Check(password string, personal ...string) ([]Violation, error) {
+	var violations []Violation
+	add := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(RuleMinLength, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(RuleMaxLength, fmt.Sprintf("password must be at most %d characters long", p.MaxLength))
+	}
+
+	classes := characterClasses(password)
+	if p.RequireLetter && !classes.letter {
+		add(RuleLetter, "password must contain a letter")
+	}
+	if p.RequireUpper && !classes.upper {
+		add(RuleUpper, "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !classes.lower {
+		add(RuleLower, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !classes.digit {
+		add(RuleDigit, "password must contain a number")
+	}
+	if p.RequireSymbol && !classes.symbol {
+		add(RuleSymbol, "password must contain a symbol")
+	}
+
+	if p.RejectPersonalInfo && containsPersonalInfo(password, personal) {
+		add(RulePersonalInfo, "password must not contain your name or email")
+	}
+
+	if p.Common != nil && password != "" {
+		common, err := isCommon(p.Common, password)
+		if err != nil {
+			return nil, err
+		}
+		if common {
+			add(RuleCommon, "password is too common")
+		}
+	}
+	return violations, nil
+}
+
+type classes struct {
+	letter, upper, lower, digit, symbol bool
+}
+
+func characterClasses(password string) classes {
+	var c classes
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			c.letter = true
+			if unicode.IsUpper(r) {
+				c.upper = true
+			}
+			if unicode.IsLower(r) {
+				c.lower = true
+			}
+		case unicode.IsDigit(r):
+			c.digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			c.symbol = true
+		}
+	}
+	return c
+}
+
+// containsPersonalInfo reports whether password contains, ignoring case, a
+// word of one of the personal values. Emails contribute their local part.
+func containsPersonalInfo(password string, personal []string) bool {
+	lower := strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, found := strings.Cut(value, "@"); found {
+			value = local
+		}
+		words := strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range append(words, value) {
+			if utf8.RuneCountInString(word) >= minPersonalLength && strings.Contains(lower, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isCommon(source RangeSource, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := source.Range(hash[:5])
+	if err != nil {
+		return false, fmt.Errorf("failed to check common passwords: %v", err)
+	}
+	for _, suffix := range suffixes {
+		if strings.EqualFold(suffix, hash[5:]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//go:embed common_passwords.sha1
+var commonPasswordHashes []byte
+
+// commonPasswords is the parsed bundled list, built once at startup
+var commonPasswords = parseHashList(commonPasswordHashes)
+
+// CommonPasswords returns the bundled list of frequently used passwords
+func CommonPasswords() RangeSource {
+	return commonPasswords
+}
+
+// HashList is an in-memory RangeSource of full SHA-1 hashes
+type HashList map[string][]string
+
+// Range returns the suffixes of the hashes starting with prefix
+func (l HashList) Range(prefix string) ([]string, error) {
+	return l[strings.ToUpper(prefix)], nil
+}
+
+// parseHashList reads one upper-case SHA-1 hash per line, skipping blank
+// lines and # comments
+func parseHashList(data []byte) HashList {
+	list := HashList{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || len(line) != sha1.Size*2 {
+			continue
+		}
+		line = strings.ToUpper(line)
+		list[line[:5]] = append(list[line[:5]], line[5:])
+	}
+	return list
+}
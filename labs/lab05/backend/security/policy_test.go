@@ -0,0 +1,84 @@
+package security
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func rules(violations []Violation) []string {
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.RequireSymbol = true
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     []string
+	}{
+		{"valid", "Tr0ub4dor&3", nil, nil},
+		{"empty reports every rule", "", nil, []string{RuleMinLength, RuleUpper, RuleLower, RuleDigit, RuleSymbol}},
+		{"short and missing classes", "abc", nil, []string{RuleMinLength, RuleUpper, RuleDigit, RuleSymbol}},
+		{"too long", "Aa1!" + strings.Repeat("x", 125), nil, []string{RuleMaxLength}},
+		{"length counts characters", "Äöü1!äöü", nil, nil},
+		{"contains name", "Johnson#2024", []string{"john@example.com", "Jane Johnson"}, []string{RulePersonalInfo}},
+		{"contains email local part", "xMaria.Lopez9!", []string{"maria.lopez@example.com"}, []string{RulePersonalInfo}},
+		{"short name fragments are ignored", "Al-gebra#2024", []string{"al@example.com", "Al"}, nil},
+		{"common", "P@ssw0rd", nil, []string{RuleCommon}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := policy.Check(tt.password, tt.personal...)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if got := rules(violations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	err := DefaultPolicy().Validate("password")
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Expected *PolicyError, got %v", err)
+	}
+	if got := rules(policyErr.Violations); !reflect.DeepEqual(got, []string{RuleUpper, RuleDigit, RuleCommon}) {
+		t.Errorf("Unexpected violations %v", got)
+	}
+	if !strings.Contains(err.Error(), "uppercase") || !strings.Contains(err.Error(), "too common") {
+		t.Errorf("Error should mention every violation, got %q", err.Error())
+	}
+
+	if err := (PasswordPolicy{}).Validate(""); err != nil {
+		t.Errorf("Zero policy should accept anything, got %v", err)
+	}
+}
+
+type failingSource struct{}
+
+func (failingSource) Range(string) ([]string, error) { return nil, errors.New("unavailable") }
+
+func TestPasswordPolicy_CommonSource(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	if suffixes, _ := CommonPasswords().Range("5baa6"); len(suffixes) == 0 {
+		t.Error("Bundled list should contain \"password\" and match prefixes case-insensitively")
+	}
+
+	policy := PasswordPolicy{Common: failingSource{}}
+	if _, err := policy.Check("anything"); err == nil {
+		t.Error("Expected an error when the common password source fails")
+	}
+}
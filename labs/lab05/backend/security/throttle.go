@@ -0,0 +1,376 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrThrottled matches every *ThrottledError
+var ErrThrottled = errors.New("too many failed login attempts")
+
+// maxAttemptDuration is how long an allowed attempt may stay in flight.
+// Attempts that never end, e.g. because the request crashed, are forgotten
+// after it so they cannot lock the account.
+const maxAttemptDuration = time.Minute
+
+// ThrottledError is returned while an account or IP has to wait before the
+// next login attempt
+type ThrottledError struct {
+	RetryAfter time.Duration
+	// Locked is set when the wait is a lockout rather than a backoff delay
+	Locked bool
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%v; retry in %s", ErrThrottled, e.RetryAfter.Round(time.Second))
+}
+
+// Is makes errors.Is(err, ErrThrottled) work
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// ThrottleConfig tunes a LoginThrottle
+type ThrottleConfig struct {
+	// MaxAccountFailures consecutive failures lock the account for
+	// LockoutDuration. It unlocks by itself afterwards.
+	MaxAccountFailures int
+	// MaxIPFailures failures lock the IP. It is higher than the account limit
+	// because one address may serve many users.
+	MaxIPFailures   int
+	LockoutDuration time.Duration
+	// The first FreeAttempts failures of an account (FreeIPAttempts of an IP)
+	// carry no delay; each further one doubles the delay, starting at
+	// BaseDelay and capped at MaxDelay
+	FreeAttempts   int
+	FreeIPAttempts int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+	// ResetAfter forgets failures this long after the last one
+	ResetAfter time.Duration
+}
+
+// DefaultThrottleConfig locks an account for 15 minutes after 5 failures and
+// an IP after 50
+func DefaultThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		MaxAccountFailures: 5,
+		MaxIPFailures:      50,
+		LockoutDuration:    15 * time.Minute,
+		FreeAttempts:       2,
+		FreeIPAttempts:     10,
+		BaseDelay:          time.Second,
+		MaxDelay:           time.Minute,
+		ResetAfter:         time.Hour,
+	}
+}
+
+// PasswordResetThrottleConfig limits password reset requests, each counted
+// as a failure. An IP gets 20 requests an hour. Mails are counted per
+// account: two go out right away and a third a minute later, then none for
+// an hour.
+func PasswordResetThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		MaxAccountFailures: 3,
+		MaxIPFailures:      20,
+		LockoutDuration:    time.Hour,
+		FreeAttempts:       1,
+		FreeIPAttempts:     10,
+		BaseDelay:          time.Minute,
+		MaxDelay:           15 * time.Minute,
+		ResetAfter:         time.Hour,
+	}
+}
+
+// Attempts is the failure record of one account or IP
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+	// Pending counts attempts that were allowed but have not failed or
+	// succeeded yet; PendingSince is when the latest was allowed
+	Pending      int
+	PendingSince time.Time
+	// ExpiresAt is when the store may drop the record
+	ExpiresAt time.Time
+}
+
+// AttemptStore persists Attempts by key
+type AttemptStore interface {
+	// Get returns the zero value for unknown keys
+	Get(ctx context.Context, key string) (Attempts, error)
+	// Update replaces the record with fn's result; it must be atomic so
+	// concurrent failures are all counted
+	Update(ctx context.Context, key string, fn func(Attempts) Attempts) (Attempts, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// LoginThrottle tracks failed logins per account and per IP, slowing down
+// and eventually locking out guessing attacks
+type LoginThrottle struct {
+	config ThrottleConfig
+	store  AttemptStore
+	now    func() time.Time
+}
+
+// ThrottleOption configures a LoginThrottle
+type ThrottleOption func(*LoginThrottle)
+
+// WithAttemptStore sets where failures are recorded. The default is a
+// MemoryAttemptStore.
+func WithAttemptStore(store AttemptStore) ThrottleOption {
+	return func(t *LoginThrottle) { t.store = store }
+}
+
+// WithThrottleClock sets the time source, for tests
+func WithThrottleClock(now func() time.Time) ThrottleOption {
+	return func(t *LoginThrottle) { t.now = now }
+}
+
+// NewLoginThrottle creates a throttle with config
+func NewLoginThrottle(config ThrottleConfig, opts ...ThrottleOption) *LoginThrottle {
+	t := &LoginThrottle{config: config, store: NewMemoryAttemptStore(), now: time.Now}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Allow returns a *ThrottledError if the account or the IP must wait before
+// trying again. Call it before verifying the password. Either account or
+// ip may be empty to check only the other.
+//
+// An allowed attempt is reserved, so concurrent attempts cannot all slip
+// through before the first failure is recorded. End it with Failure, or with
+// Release if it did not fail.
+func (t *LoginThrottle) Allow(ctx context.Context, account, ip string) error {
+	now := t.now()
+	var reserved []throttleKey
+	for _, k := range t.keys(account, ip) {
+		k := k
+		var wait *ThrottledError
+		_, err := t.store.Update(ctx, k.key, func(a Attempts) Attempts {
+			a = t.current(a, now)
+			if wait = t.blocked(a, k, now); wait != nil {
+				return a
+			}
+			a.Pending++
+			a.PendingSince = now
+			if expiresAt := now.Add(maxAttemptDuration); a.ExpiresAt.Before(expiresAt) {
+				a.ExpiresAt = expiresAt
+			}
+			return a
+		})
+		if err != nil {
+			// Best effort; unreleased attempts are forgotten after a while
+			t.release(ctx, reserved)
+			return fmt.Errorf("failed to record login attempt: %v", err)
+		}
+		if wait != nil {
+			if err := t.release(ctx, reserved); err != nil {
+				return err
+			}
+			return wait
+		}
+		reserved = append(reserved, k)
+	}
+	return nil
+}
+
+// Failure ends an attempt as failed and reports whether it locked the
+// account
+func (t *LoginThrottle) Failure(ctx context.Context, account, ip string) (bool, error) {
+	now := t.now()
+	var accountLocked bool
+	for _, k := range t.keys(account, ip) {
+		k := k
+		var locked bool
+		_, err := t.store.Update(ctx, k.key, func(a Attempts) Attempts {
+			a = t.current(a, now)
+			if a.Pending > 0 {
+				a.Pending--
+			}
+			a.Failures++
+			a.LastFailure = now
+			if a.Failures >= k.max && a.LockedUntil.IsZero() {
+				a.LockedUntil = now.Add(t.config.LockoutDuration)
+				locked = true
+			}
+			a.ExpiresAt = now.Add(t.config.ResetAfter)
+			if a.LockedUntil.After(a.ExpiresAt) {
+				a.ExpiresAt = a.LockedUntil
+			}
+			return a
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to record login attempt: %v", err)
+		}
+		if k.account && locked {
+			accountLocked = true
+		}
+	}
+	return accountLocked, nil
+}
+
+// Release ends an attempt that did not fail without counting it, e.g. a
+// correct password that still needs a second factor
+func (t *LoginThrottle) Release(ctx context.Context, account, ip string) error {
+	return t.release(ctx, t.keys(account, ip))
+}
+
+// Success clears the account's failures. The IP's are kept so that one
+// valid credential does not reset a credential-stuffing run. It does not end
+// the attempt; call Release as well.
+func (t *LoginThrottle) Success(ctx context.Context, account string) error {
+	if err := t.store.Delete(ctx, accountKey(account)); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %v", err)
+	}
+	return nil
+}
+
+func (t *LoginThrottle) release(ctx context.Context, keys []throttleKey) error {
+	for _, k := range keys {
+		_, err := t.store.Update(ctx, k.key, func(a Attempts) Attempts {
+			if a.Pending > 0 {
+				a.Pending--
+			}
+			return a
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record login attempt: %v", err)
+		}
+	}
+	return nil
+}
+
+type throttleKey struct {
+	key       string
+	free, max int
+	account   bool
+}
+
+func (t *LoginThrottle) keys(account, ip string) []throttleKey {
+	var keys []throttleKey
+	if account != "" {
+		keys = append(keys, throttleKey{key: accountKey(account), free: t.config.FreeAttempts, max: t.config.MaxAccountFailures, account: true})
+	}
+	if ip != "" {
+		keys = append(keys, throttleKey{key: "ip:" + ip, free: t.config.FreeIPAttempts, max: t.config.MaxIPFailures})
+	}
+	return keys
+}
+
+// accountKey normalizes the email the same way the user repositories do
+func accountKey(account string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(account))
+}
+
+// current drops failures that are too old, lockouts that have ended and
+// attempts that stayed in flight too long
+func (t *LoginThrottle) current(a Attempts, now time.Time) Attempts {
+	pending, since, expiresAt := a.Pending, a.PendingSince, a.ExpiresAt
+	if pending == 0 || now.Sub(since) >= maxAttemptDuration {
+		pending, since = 0, time.Time{}
+	}
+	if !a.LockedUntil.IsZero() && !now.Before(a.LockedUntil) {
+		a = Attempts{ExpiresAt: expiresAt}
+	}
+	if a.LockedUntil.IsZero() && now.Sub(a.LastFailure) >= t.config.ResetAfter {
+		a = Attempts{ExpiresAt: expiresAt}
+	}
+	a.Pending, a.PendingSince = pending, since
+	return a
+}
+
+// blocked decides whether one more attempt may start. Attempts in flight
+// are counted as if they had failed just now.
+func (t *LoginThrottle) blocked(a Attempts, k throttleKey, now time.Time) *ThrottledError {
+	if now.Before(a.LockedUntil) {
+		return &ThrottledError{RetryAfter: a.LockedUntil.Sub(now), Locked: true}
+	}
+	failures := a.Failures + a.Pending
+	if a.Pending > 0 && failures >= k.max {
+		// The attempts in flight could lock the account themselves
+		return &ThrottledError{RetryAfter: t.config.BaseDelay}
+	}
+	if failures <= k.free {
+		return nil
+	}
+	last := a.LastFailure
+	if a.Pending > 0 {
+		last = now
+	}
+	if until := last.Add(t.delay(failures - k.free)); now.Before(until) {
+		return &ThrottledError{RetryAfter: until.Sub(now)}
+	}
+	return nil
+}
+
+// delay returns BaseDelay doubled for every excess failure after the first
+func (t *LoginThrottle) delay(excess int) time.Duration {
+	delay := t.config.BaseDelay
+	for i := 1; i < excess && delay < t.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+	return delay
+}
+
+// MemoryAttemptStore is an AttemptStore kept in process memory
+type MemoryAttemptStore struct {
+	mutex     sync.Mutex
+	attempts  map[string]Attempts
+	lastSweep time.Time
+}
+
+// NewMemoryAttemptStore creates an empty store
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: make(map[string]Attempts)}
+}
+
+// Get returns the record of key
+func (s *MemoryAttemptStore) Get(ctx context.Context, key string) (Attempts, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.attempts[key], nil
+}
+
+// Update applies fn under the store's lock
+func (s *MemoryAttemptStore) Update(ctx context.Context, key string, fn func(Attempts) Attempts) (Attempts, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sweep()
+	attempts := fn(s.attempts[key])
+	s.attempts[key] = attempts
+	return attempts, nil
+}
+
+// Delete removes the record of key
+func (s *MemoryAttemptStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// sweep drops expired records at most once a minute
+func (s *MemoryAttemptStore) sweep() {
+	now := time.Now()
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, attempts := range s.attempts {
+		if now.After(attempts.ExpiresAt) {
+			delete(s.attempts, key)
+		}
+	}
+}
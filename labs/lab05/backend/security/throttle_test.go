@@ -0,0 +1,218 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestThrottle() (*LoginThrottle, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	config := DefaultThrottleConfig()
+	config.MaxIPFailures = 8
+	return NewLoginThrottle(config, WithThrottleClock(clock.Now)), clock
+}
+
+func retryAfter(t *testing.T, err error) *ThrottledError {
+	t.Helper()
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) || !errors.Is(err, ErrThrottled) {
+		t.Fatalf("Expected *ThrottledError, got %v", err)
+	}
+	return throttled
+}
+
+func TestLoginThrottleBackoffAndLockout(t *testing.T) {
+	throttle, clock := newTestThrottle()
+	ctx := context.Background()
+
+	fail := func() bool {
+		t.Helper()
+		locked, err := throttle.Failure(ctx, "John@Example.com", "")
+		if err != nil {
+			t.Fatalf("Failure failed: %v", err)
+		}
+		return locked
+	}
+
+	fail()
+	fail()
+	if err := throttle.Allow(ctx, "john@example.com", ""); err != nil {
+		t.Fatalf("Free attempts should not be delayed, got %v", err)
+	}
+
+	fail()
+	if wait := retryAfter(t, throttle.Allow(ctx, "john@example.com", "")); wait.RetryAfter != time.Second || wait.Locked {
+		t.Errorf("Third failure should delay 1s, got %+v", wait)
+	}
+	clock.Advance(time.Second)
+	if err := throttle.Allow(ctx, "john@example.com", ""); err != nil {
+		t.Errorf("Delay should have passed, got %v", err)
+	}
+
+	fail()
+	if wait := retryAfter(t, throttle.Allow(ctx, "john@example.com", "")); wait.RetryAfter != 2*time.Second {
+		t.Errorf("Delay should double, got %s", wait.RetryAfter)
+	}
+
+	if !fail() {
+		t.Fatal("Fifth failure should lock the account")
+	}
+	wait := retryAfter(t, throttle.Allow(ctx, "john@example.com", ""))
+	if !wait.Locked || wait.RetryAfter != 15*time.Minute {
+		t.Errorf("Expected a 15 minute lockout, got %+v", wait)
+	}
+	if err := throttle.Allow(ctx, "jane@example.com", ""); err != nil {
+		t.Errorf("Other accounts should not be affected, got %v", err)
+	}
+
+	clock.Advance(15 * time.Minute)
+	if err := throttle.Allow(ctx, "john@example.com", ""); err != nil {
+		t.Errorf("Lockout should end by itself, got %v", err)
+	}
+	if fail() {
+		t.Error("Failures should start over after a lockout")
+	}
+	if err := throttle.Allow(ctx, "john@example.com", ""); err != nil {
+		t.Errorf("First failure after a lockout should not be delayed, got %v", err)
+	}
+}
+
+func TestLoginThrottleResetAndSuccess(t *testing.T) {
+	throttle, clock := newTestThrottle()
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		throttle.Failure(ctx, "john@example.com", "")
+	}
+	clock.Advance(time.Hour)
+	if err := throttle.Allow(ctx, "john@example.com", ""); err != nil {
+		t.Errorf("Old failures should be forgotten, got %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		throttle.Failure(ctx, "john@example.com", "")
+	}
+	if err := throttle.Success(ctx, "JOHN@example.com"); err != nil {
+		t.Fatalf("Success failed: %v", err)
+	}
+	if err := throttle.Allow(ctx, "john@example.com", ""); err != nil {
+		t.Errorf("Success should clear the account's failures, got %v", err)
+	}
+}
+
+func TestLoginThrottleByIP(t *testing.T) {
+	throttle, clock := newTestThrottle()
+	ctx := context.Background()
+
+	// Credential stuffing: one failure each on many accounts from one IP
+	for i := 0; i < 8; i++ {
+		clock.Advance(time.Minute)
+		if locked, _ := throttle.Failure(ctx, string(rune('a'+i))+"@example.com", "192.0.2.1"); locked {
+			t.Fatal("IP lockouts should not be reported as account lockouts")
+		}
+	}
+
+	wait := retryAfter(t, throttle.Allow(ctx, "new@example.com", "192.0.2.1"))
+	if !wait.Locked {
+		t.Errorf("IP should be locked, got %+v", wait)
+	}
+	if err := throttle.Allow(ctx, "new@example.com", "198.51.100.7"); err != nil {
+		t.Errorf("Other IPs should not be affected, got %v", err)
+	}
+	throttle.Success(ctx, "a@example.com")
+	if err := throttle.Allow(ctx, "new@example.com", "192.0.2.1"); err == nil {
+		t.Error("A successful login must not clear the IP's failures")
+	}
+}
+
+func TestLoginThrottleSingleKey(t *testing.T) {
+	throttle, _ := newTestThrottle()
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		throttle.Failure(ctx, "", "192.0.2.1")
+	}
+	if err := throttle.Allow(ctx, "victim@example.com", ""); err != nil {
+		t.Errorf("Failures counted for the IP alone must not reach accounts, got %v", err)
+	}
+	retryAfter(t, throttle.Allow(ctx, "", "192.0.2.1"))
+
+	for i := 0; i < 5; i++ {
+		throttle.Failure(ctx, "victim@example.com", "")
+	}
+	if err := throttle.Allow(ctx, "", "198.51.100.7"); err != nil {
+		t.Errorf("Failures counted for the account alone must not reach IPs, got %v", err)
+	}
+	retryAfter(t, throttle.Allow(ctx, "victim@example.com", ""))
+}
+
+func TestLoginThrottleConcurrentAttempts(t *testing.T) {
+	throttle, clock := newTestThrottle()
+	ctx := context.Background()
+
+	// Guesses that start together are all allowed before any of them fails,
+	// unless Allow reserves them
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	allowed := 0
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if throttle.Allow(ctx, "john@example.com", "") == nil {
+				mutex.Lock()
+				allowed++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if want := DefaultThrottleConfig().FreeAttempts + 1; allowed != want {
+		t.Fatalf("Expected %d concurrent attempts to be allowed, got %d", want, allowed)
+	}
+	for i := 0; i < allowed; i++ {
+		throttle.Failure(ctx, "john@example.com", "")
+	}
+	if wait := retryAfter(t, throttle.Allow(ctx, "john@example.com", "")); wait.RetryAfter != time.Second {
+		t.Errorf("Expected the usual delay once the attempts failed, got %+v", wait)
+	}
+
+	// Released attempts are not counted
+	for i := 0; i < 10; i++ {
+		if err := throttle.Allow(ctx, "jane@example.com", "192.0.2.1"); err != nil {
+			t.Fatalf("Attempt %d should be allowed, got %v", i, err)
+		}
+		if err := throttle.Release(ctx, "jane@example.com", "192.0.2.1"); err != nil {
+			t.Fatalf("Release failed: %v", err)
+		}
+	}
+
+	// Attempts that never end are forgotten
+	for i := 0; i < 3; i++ {
+		throttle.Allow(ctx, "joe@example.com", "")
+	}
+	retryAfter(t, throttle.Allow(ctx, "joe@example.com", ""))
+	clock.Advance(maxAttemptDuration)
+	if err := throttle.Allow(ctx, "joe@example.com", ""); err != nil {
+		t.Errorf("Stale attempts should be forgotten, got %v", err)
+	}
+}
+
+func TestPasswordService_DummyHash(t *testing.T) {
+	service := NewPasswordService(WithHasher(NewBcryptHasher(4)))
+	hash := service.DummyHash()
+	if hash == "" || hash != service.DummyHash() {
+		t.Fatal("DummyHash should return the same non-empty hash every time")
+	}
+	if service.VerifyPassword("", hash) || service.VerifyPassword("password123", hash) {
+		t.Error("DummyHash should not match guessable passwords")
+	}
+}
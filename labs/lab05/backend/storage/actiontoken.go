@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lab05/actiontoken"
+)
+
+// SQLiteActionTokenStore is an actiontoken.Store backed by SQLite
+type SQLiteActionTokenStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteActionTokenStore creates a store using a database opened with
+// OpenSQLite
+func NewSQLiteActionTokenStore(db *sql.DB) *SQLiteActionTokenStore {
+	return &SQLiteActionTokenStore{db: db}
+}
+
+// Save inserts token
+func (s *SQLiteActionTokenStore) Save(ctx context.Context, token *actiontoken.Token) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO action_tokens (hash, purpose, user_id, email, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		token.Hash, string(token.Purpose), token.UserID, token.Email, token.CreatedAt.UTC(), token.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save action token: %v", err)
+	}
+	return nil
+}
+
+// Get loads the token with the given hash
+func (s *SQLiteActionTokenStore) Get(ctx context.Context, hash string) (*actiontoken.Token, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT hash, purpose, user_id, email, created_at, expires_at, used_at FROM action_tokens WHERE hash = ?`, hash)
+
+	var token actiontoken.Token
+	var purpose string
+	var usedAt sql.NullTime
+	err := row.Scan(&token.Hash, &purpose, &token.UserID, &token.Email, &token.CreatedAt, &token.ExpiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, actiontoken.ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load action token: %v", err)
+	}
+	token.Purpose = actiontoken.Purpose(purpose)
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return &token, nil
+}
+
+// MarkUsed sets used_at unless it is already set
+func (s *SQLiteActionTokenStore) MarkUsed(ctx context.Context, hash string, at time.Time) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE action_tokens SET used_at = ? WHERE hash = ? AND used_at IS NULL`, at.UTC(), hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark action token used: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark action token used: %v", err)
+	}
+	return rows == 1, nil
+}
+
+// DeleteUser removes the user's unused tokens for purpose
+func (s *SQLiteActionTokenStore) DeleteUser(ctx context.Context, userID int, purpose actiontoken.Purpose) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM action_tokens WHERE user_id = ? AND purpose = ? AND used_at IS NULL`, userID, string(purpose))
+	if err != nil {
+		return fmt.Errorf("failed to delete action tokens: %v", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes tokens that expired before the given time
+func (s *SQLiteActionTokenStore) DeleteExpired(ctx context.Context, before time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM action_tokens WHERE expires_at <= ?`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired action tokens: %v", err)
+	}
+	return nil
+}
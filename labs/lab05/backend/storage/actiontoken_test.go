@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lab05/actiontoken"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteActionTokenStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "tokens.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	user := newUser("john@example.com")
+	require.NoError(t, NewSQLiteUserRepository(db).Create(ctx, user))
+
+	store := NewSQLiteActionTokenStore(db)
+	service := actiontoken.NewService(actiontoken.WithStore(store))
+
+	old, err := service.Issue(ctx, actiontoken.ResetPassword, user.ID, user.Email)
+	require.NoError(t, err)
+	token, err := service.Issue(ctx, actiontoken.ResetPassword, user.ID, user.Email)
+	require.NoError(t, err)
+	_, err = service.Lookup(ctx, actiontoken.ResetPassword, old)
+	assert.ErrorIs(t, err, actiontoken.ErrInvalidToken, "issuing a token must replace the previous one")
+
+	redeemed, err := service.Redeem(ctx, actiontoken.ResetPassword, token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, redeemed.UserID)
+	_, err = service.Redeem(ctx, actiontoken.ResetPassword, token)
+	assert.ErrorIs(t, err, actiontoken.ErrInvalidToken)
+
+	stored, err := store.Get(ctx, redeemed.Hash)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.UsedAt)
+	assert.Equal(t, actiontoken.ResetPassword, stored.Purpose)
+
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, actiontoken.ErrTokenNotFound)
+
+	require.NoError(t, store.DeleteExpired(ctx, time.Now().Add(2*time.Hour)))
+	_, err = store.Get(ctx, redeemed.Hash)
+	assert.ErrorIs(t, err, actiontoken.ErrTokenNotFound)
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"lab05/userdomain"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteIdentityRepository is a userdomain.IdentityRepository backed by
+// SQLite
+type SQLiteIdentityRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteIdentityRepository creates a repository using a database opened
+// with OpenSQLite
+func NewSQLiteIdentityRepository(db *sql.DB) *SQLiteIdentityRepository {
+	return &SQLiteIdentityRepository{db: db}
+}
+
+// GetIdentity loads the link of the provider account
+func (r *SQLiteIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*userdomain.ExternalIdentity, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT provider, subject, user_id, email, created_at FROM user_identities WHERE provider = ? AND subject = ?`,
+		provider, subject)
+
+	var identity userdomain.ExternalIdentity
+	err := row.Scan(&identity.Provider, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identity: %v", err)
+	}
+	return &identity, nil
+}
+
+// LinkIdentity inserts the link
+func (r *SQLiteIdentityRepository) LinkIdentity(ctx context.Context, identity *userdomain.ExternalIdentity) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_identities (provider, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, ?)`,
+		identity.Provider, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt.UTC())
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		return userdomain.ErrIdentityTaken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %v", err)
+	}
+	return nil
+}
+
+// MemoryIdentityRepository is an in-memory userdomain.IdentityRepository
+type MemoryIdentityRepository struct {
+	mutex      sync.RWMutex
+	identities map[[2]string]userdomain.ExternalIdentity
+}
+
+// NewMemoryIdentityRepository creates an empty repository
+func NewMemoryIdentityRepository() *MemoryIdentityRepository {
+	return &MemoryIdentityRepository{identities: make(map[[2]string]userdomain.ExternalIdentity)}
+}
+
+// GetIdentity returns a copy of the link of the provider account
+func (r *MemoryIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*userdomain.ExternalIdentity, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	identity, ok := r.identities[[2]string{provider, subject}]
+	if !ok {
+		return nil, userdomain.ErrIdentityNotFound
+	}
+	return &identity, nil
+}
+
+// LinkIdentity stores a copy of identity
+func (r *MemoryIdentityRepository) LinkIdentity(ctx context.Context, identity *userdomain.ExternalIdentity) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := [2]string{identity.Provider, identity.Subject}
+	if _, exists := r.identities[key]; exists {
+		return userdomain.ErrIdentityTaken
+	}
+	r.identities[key] = *identity
+	return nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lab05/userdomain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityRepository(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "identities.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	users := NewSQLiteUserRepository(db)
+	user := newUser("john@example.com")
+	require.NoError(t, users.Create(context.Background(), user))
+
+	repos := map[string]userdomain.IdentityRepository{
+		"memory": NewMemoryIdentityRepository(),
+		"sqlite": NewSQLiteIdentityRepository(db),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := repo.GetIdentity(ctx, "google", "123")
+			assert.ErrorIs(t, err, userdomain.ErrIdentityNotFound)
+
+			identity := &userdomain.ExternalIdentity{Provider: "google", Subject: "123", UserID: user.ID, Email: "john@example.com", CreatedAt: time.Now()}
+			require.NoError(t, repo.LinkIdentity(ctx, identity))
+			assert.ErrorIs(t, repo.LinkIdentity(ctx, identity), userdomain.ErrIdentityTaken)
+
+			found, err := repo.GetIdentity(ctx, "google", "123")
+			require.NoError(t, err)
+			assert.Equal(t, user.ID, found.UserID)
+			assert.Equal(t, "john@example.com", found.Email)
+			_, err = repo.GetIdentity(ctx, "github", "123")
+			assert.ErrorIs(t, err, userdomain.ErrIdentityNotFound)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"lab05/userdomain"
+)
+
+// MemoryUserRepository is an in-memory userdomain.Repository for tests and
+// local development
+type MemoryUserRepository struct {
+	mutex   sync.RWMutex
+	users   map[int]userdomain.User
+	byEmail map[string]int
+	nextID  int
+}
+
+// NewMemoryUserRepository creates an empty repository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:   make(map[int]userdomain.User),
+		byEmail: make(map[string]int),
+		nextID:  1,
+	}
+}
+
+// Create stores a copy of user and assigns its ID
+func (r *MemoryUserRepository) Create(ctx context.Context, user *userdomain.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := emailKey(user.Email)
+	if _, exists := r.byEmail[key]; exists {
+		return userdomain.ErrEmailTaken
+	}
+
+	user.ID = r.nextID
+	r.nextID++
+	r.users[user.ID] = cloneUser(user)
+	r.byEmail[key] = user.ID
+	return nil
+}
+
+// GetByID returns a copy of the user with the given ID
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id int) (*userdomain.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return nil, userdomain.ErrUserNotFound
+	}
+	user = cloneUser(&user)
+	return &user, nil
+}
+
+// GetByEmail returns a copy of the user with the given email
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*userdomain.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	id, exists := r.byEmail[emailKey(email)]
+	if !exists {
+		return nil, userdomain.ErrUserNotFound
+	}
+	user := r.users[id]
+	user = cloneUser(&user)
+	return &user, nil
+}
+
+// Update replaces the stored user
+func (r *MemoryUserRepository) Update(ctx context.Context, user *userdomain.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.users[user.ID]
+	if !exists {
+		return userdomain.ErrUserNotFound
+	}
+
+	oldKey, newKey := emailKey(existing.Email), emailKey(user.Email)
+	if oldKey != newKey {
+		if _, taken := r.byEmail[newKey]; taken {
+			return userdomain.ErrEmailTaken
+		}
+		delete(r.byEmail, oldKey)
+		r.byEmail[newKey] = user.ID
+	}
+	r.users[user.ID] = cloneUser(user)
+	return nil
+}
+
+// cloneUser copies user so callers never share the stored slices
+func cloneUser(user *userdomain.User) userdomain.User {
+	clone := *user
+	clone.Roles = append([]string{}, user.Roles...)
+	clone.RecoveryCodes = append([]string(nil), user.RecoveryCodes...)
+	return clone
+}
+
+func emailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
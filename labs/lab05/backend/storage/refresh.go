@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lab05/jwtservice"
+)
+
+// emptyFamilyGrace keeps families without tokens for a while, since a
+// session is saved before its first token
+const emptyFamilyGrace = time.Minute
+
+// SQLiteRefreshStore is a jwtservice.RefreshStore backed by SQLite
+type SQLiteRefreshStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteRefreshStore creates a store using a database opened with
+// OpenSQLite
+func NewSQLiteRefreshStore(db *sql.DB) *SQLiteRefreshStore {
+	return &SQLiteRefreshStore{db: db}
+}
+
+// Save inserts token and its family if the family is new, extending the
+// family's expiry to the token's
+func (s *SQLiteRefreshStore) Save(ctx context.Context, token *jwtservice.RefreshToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR IGNORE INTO refresh_families (id, user_id, created_at, last_seen_at) VALUES (?, ?, ?, ?)`,
+		token.FamilyID, token.UserID, token.IssuedAt.UTC(), token.IssuedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save token family: %v", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE refresh_families SET expires_at = ? WHERE id = ? AND (expires_at IS NULL OR expires_at < ?)`,
+		token.ExpiresAt.UTC(), token.FamilyID, token.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to update token family: %v", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (hash, family_id, user_id, email, issued_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		token.Hash, token.FamilyID, token.UserID, token.Email, token.IssuedAt.UTC(), token.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save refresh token: %v", err)
+	}
+	return tx.Commit()
+}
+
+// Get loads the token with the given hash along with its family's status
+func (s *SQLiteRefreshStore) Get(ctx context.Context, hash string) (*jwtservice.RefreshToken, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT t.hash, t.family_id, t.user_id, t.email, t.issued_at, t.expires_at, t.used_at, f.revoked_at
+		FROM refresh_tokens t JOIN refresh_families f ON f.id = t.family_id
+		WHERE t.hash = ?`, hash)
+
+	var token jwtservice.RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	err := row.Scan(&token.Hash, &token.FamilyID, &token.UserID, &token.Email,
+		&token.IssuedAt, &token.ExpiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, jwtservice.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load refresh token: %v", err)
+	}
+	token.UsedAt, token.RevokedAt = usedAt.Time, revokedAt.Time
+	return &token, nil
+}
+
+// MarkUsed sets used_at if it is still empty
+func (s *SQLiteRefreshStore) MarkUsed(ctx context.Context, hash string, at time.Time) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET used_at = ? WHERE hash = ? AND used_at IS NULL`, at.UTC(), hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark refresh token used: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark refresh token used: %v", err)
+	}
+	return rows == 1, nil
+}
+
+// RevokeFamily sets revoked_at on the family if it is still active
+func (s *SQLiteRefreshStore) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_families SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, at.UTC(), familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token family: %v", err)
+	}
+	return nil
+}
+
+// RevokeUser sets revoked_at on every active family of the user
+func (s *SQLiteRefreshStore) RevokeUser(ctx context.Context, userID int, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_families SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`, at.UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user token families: %v", err)
+	}
+	return nil
+}
+
+// SaveSession inserts the family of a new session
+func (s *SQLiteRefreshStore) SaveSession(ctx context.Context, session *jwtservice.Session) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO refresh_families (id, user_id, user_agent, ip, created_at, last_seen_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.UserAgent, session.IP, session.CreatedAt.UTC(), session.LastSeenAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save session: %v", err)
+	}
+	return nil
+}
+
+// TouchSession sets last_seen_at and the non-empty client fields
+func (s *SQLiteRefreshStore) TouchSession(ctx context.Context, id string, at time.Time, client jwtservice.Client) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE refresh_families
+		SET last_seen_at = ?, user_agent = COALESCE(NULLIF(?, ''), user_agent), ip = COALESCE(NULLIF(?, ''), ip)
+		WHERE id = ?`, at.UTC(), client.UserAgent, client.IP, id)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update session: %v", err)
+	}
+	if rows == 0 {
+		return jwtservice.ErrSessionNotFound
+	}
+	return nil
+}
+
+const sessionColumns = `
+	SELECT f.id, f.user_id, f.user_agent, f.ip, f.created_at, f.last_seen_at, f.revoked_at, f.expires_at
+	FROM refresh_families f `
+
+// GetSession loads the session with the given ID
+func (s *SQLiteRefreshStore) GetSession(ctx context.Context, id string) (*jwtservice.Session, error) {
+	session, err := scanSession(s.db.QueryRowContext(ctx, sessionColumns+`WHERE f.id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, jwtservice.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %v", err)
+	}
+	return session, nil
+}
+
+// ListSessions loads the user's live sessions, most recently seen first
+func (s *SQLiteRefreshStore) ListSessions(ctx context.Context, userID int, now time.Time) ([]jwtservice.Session, error) {
+	rows, err := s.db.QueryContext(ctx, sessionColumns+`
+		WHERE f.user_id = ? AND f.revoked_at IS NULL AND f.expires_at > ?
+		ORDER BY f.last_seen_at DESC`, userID, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []jwtservice.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %v", err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	return sessions, nil
+}
+
+func scanSession(row interface{ Scan(...interface{}) error }) (*jwtservice.Session, error) {
+	var session jwtservice.Session
+	var lastSeenAt, revokedAt, expiresAt sql.NullTime
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP,
+		&session.CreatedAt, &lastSeenAt, &revokedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	session.LastSeenAt, session.RevokedAt, session.ExpiresAt = lastSeenAt.Time, revokedAt.Time, expiresAt.Time
+	return &session, nil
+}
+
+// DeleteExpired removes tokens that expired before the given time and
+// families left without tokens, except those created within the grace period
+func (s *SQLiteRefreshStore) DeleteExpired(ctx context.Context, before time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < ?`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %v", err)
+	}
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM refresh_families WHERE id NOT IN (SELECT family_id FROM refresh_tokens) AND created_at < ?`,
+		before.Add(-emptyFamilyGrace).UTC())
+	if err != nil {
+		return fmt.Errorf("failed to delete empty token families: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lab05/jwtservice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadIdentity(ctx context.Context, userID int) (*jwtservice.Identity, error) {
+	return &jwtservice.Identity{UserID: userID, Email: "test@example.com"}, nil
+}
+
+func TestSQLiteRefreshStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "tokens.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewSQLiteRefreshStore(db)
+	service, err := jwtservice.NewJWTService("test-secret", jwtservice.WithRefreshStore(store))
+	require.NoError(t, err)
+
+	first, err := service.IssueTokenPair(ctx, jwtservice.Identity{UserID: 1, Email: "test@example.com"})
+	require.NoError(t, err)
+	second, err := service.Refresh(ctx, first.RefreshToken, loadIdentity)
+	require.NoError(t, err)
+	assert.Equal(t, first.FamilyID, second.FamilyID)
+
+	_, err = service.Refresh(ctx, first.RefreshToken, loadIdentity)
+	assert.ErrorIs(t, err, jwtservice.ErrRefreshTokenReused)
+	_, err = service.Refresh(ctx, second.RefreshToken, loadIdentity)
+	assert.ErrorIs(t, err, jwtservice.ErrInvalidToken)
+
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, jwtservice.ErrRefreshTokenNotFound)
+
+	// A session is saved before its first token and must survive a sweep
+	now := time.Now()
+	require.NoError(t, store.SaveSession(ctx, &jwtservice.Session{ID: "new", UserID: 1, CreatedAt: now, LastSeenAt: now}))
+	require.NoError(t, store.DeleteExpired(ctx, now))
+	_, err = store.GetSession(ctx, "new")
+	assert.NoError(t, err, "a session without tokens yet must not be swept")
+
+	require.NoError(t, store.DeleteExpired(ctx, time.Now().Add(jwtservice.DefaultRefreshTokenTTL+time.Hour)))
+	var families int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM refresh_families`).Scan(&families))
+	assert.Zero(t, families)
+}
+
+func TestSQLiteRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "revocations.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	store := NewSQLiteRevocationStore(db)
+	store.now = func() time.Time { return now }
+	service, err := jwtservice.NewJWTService("test-secret",
+		jwtservice.WithRevocationStore(store),
+		jwtservice.WithRefreshStore(NewSQLiteRefreshStore(db)))
+	require.NoError(t, err)
+
+	token, _ := service.GenerateToken(1, "test@example.com")
+	claims, err := service.ValidateToken(token)
+	require.NoError(t, err)
+	require.NoError(t, service.RevokeToken(ctx, claims))
+	_, err = service.ValidateToken(token)
+	assert.ErrorIs(t, err, jwtservice.ErrTokenRevoked)
+
+	pair, err := service.IssueTokenPair(ctx, jwtservice.Identity{UserID: 2, Email: "user@example.com"})
+	require.NoError(t, err)
+	require.NoError(t, service.RevokeUserTokens(ctx, 2, time.Now()))
+	require.NoError(t, store.RevokeUser(ctx, 2, time.Now().Add(-time.Hour), time.Now().Add(time.Hour)))
+	_, err = service.ValidateToken(pair.AccessToken)
+	assert.ErrorIs(t, err, jwtservice.ErrTokenRevoked, "an earlier cutoff must not replace a later one")
+	_, err = service.Refresh(ctx, pair.RefreshToken, loadIdentity)
+	assert.ErrorIs(t, err, jwtservice.ErrInvalidToken)
+
+	now = now.Add(jwtservice.DefaultAccessTokenTTL + time.Minute)
+	revoked, err := store.IsRevoked(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.False(t, revoked, "entries should lapse once the token has expired")
+
+	require.NoError(t, store.DeleteExpired(ctx, now))
+	var remaining int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM revoked_tokens`).Scan(&remaining))
+	assert.Zero(t, remaining)
+}
+
+func TestSQLiteSessions(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	service, err := jwtservice.NewJWTService("test-secret", jwtservice.WithRefreshStore(NewSQLiteRefreshStore(db)))
+	require.NoError(t, err)
+	identity := jwtservice.Identity{UserID: 1, Email: "test@example.com"}
+
+	phone, err := service.StartSession(ctx, identity, jwtservice.Client{UserAgent: "Phone", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	laptop, err := service.StartSession(ctx, identity, jwtservice.Client{UserAgent: "Laptop", IP: "10.0.0.2"})
+	require.NoError(t, err)
+	_, err = service.RefreshSession(ctx, phone.RefreshToken, loadIdentity, jwtservice.Client{IP: "10.0.0.3"})
+	require.NoError(t, err)
+
+	sessions, err := service.Sessions(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, phone.FamilyID, sessions[0].ID, "the refreshed session was seen last")
+	assert.Equal(t, jwtservice.Client{UserAgent: "Phone", IP: "10.0.0.3"}, sessions[0].Client)
+	assert.Equal(t, "Laptop", sessions[1].UserAgent)
+	assert.WithinDuration(t, time.Now().Add(jwtservice.DefaultRefreshTokenTTL), sessions[0].ExpiresAt, time.Minute)
+	assert.False(t, sessions[0].CreatedAt.IsZero())
+
+	assert.ErrorIs(t, service.RevokeSession(ctx, 2, laptop.FamilyID), jwtservice.ErrSessionNotFound)
+	require.NoError(t, service.RevokeSession(ctx, 1, laptop.FamilyID))
+	sessions, err = service.Sessions(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, phone.FamilyID, sessions[0].ID)
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLiteRevocationStore is a jwtservice.RevocationStore backed by SQLite
+type SQLiteRevocationStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewSQLiteRevocationStore creates a store using a database opened with
+// OpenSQLite
+func NewSQLiteRevocationStore(db *sql.DB) *SQLiteRevocationStore {
+	return &SQLiteRevocationStore{db: db, now: time.Now}
+}
+
+// Revoke records the jti until expiresAt
+func (s *SQLiteRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)`, jti, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %v", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether an unexpired entry exists for the jti
+func (s *SQLiteRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var found int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT 1 FROM revoked_tokens WHERE jti = ? AND expires_at > ?`, jti, s.now().UTC()).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %v", err)
+	}
+	return true, nil
+}
+
+// RevokeUser records the cutoff unless a later one already exists
+func (s *SQLiteRevocationStore) RevokeUser(ctx context.Context, userID int, issuedBefore, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO revoked_users (user_id, issued_before, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET issued_before = excluded.issued_before, expires_at = excluded.expires_at
+		WHERE excluded.issued_before > revoked_users.issued_before`,
+		userID, issuedBefore.UTC(), expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %v", err)
+	}
+	return nil
+}
+
+// UserCutoff returns the user's cutoff while it is still in effect
+func (s *SQLiteRevocationStore) UserCutoff(ctx context.Context, userID int) (time.Time, error) {
+	var cutoff time.Time
+	err := s.db.QueryRowContext(ctx,
+		`SELECT issued_before FROM revoked_users WHERE user_id = ? AND expires_at > ?`,
+		userID, s.now().UTC()).Scan(&cutoff)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load user cutoff: %v", err)
+	}
+	return cutoff, nil
+}
+
+// DeleteExpired removes entries whose tokens expired before the given time
+func (s *SQLiteRevocationStore) DeleteExpired(ctx context.Context, before time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= ?`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired revocations: %v", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM revoked_users WHERE expires_at <= ?`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired user cutoffs: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"lab05/userdomain"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// migrations are applied in order by OpenSQLite, which records progress in
+// PRAGMA user_version. Only append to this list. The first entries predate
+// the version tracking and therefore must stay idempotent.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		name TEXT NOT NULL,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS refresh_families (
+		id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		hash TEXT PRIMARY KEY,
+		family_id TEXT NOT NULL REFERENCES refresh_families(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		issued_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id)`,
+	`CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		expires_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS revoked_users (
+		user_id INTEGER PRIMARY KEY,
+		issued_before DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL
+	)`,
+	`ALTER TABLE users ADD COLUMN roles TEXT NOT NULL DEFAULT 'user'`,
+	`ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0`,
+	`CREATE TABLE action_tokens (
+		hash TEXT PRIMARY KEY,
+		purpose TEXT NOT NULL,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		email TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME
+	)`,
+	`CREATE INDEX idx_action_tokens_user ON action_tokens(user_id, purpose)`,
+	`ALTER TABLE users ADD COLUMN mfa_enabled INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN recovery_codes TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE refresh_families ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE refresh_families ADD COLUMN ip TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE refresh_families ADD COLUMN last_seen_at DATETIME`,
+	`ALTER TABLE refresh_families ADD COLUMN expires_at DATETIME`,
+	`UPDATE refresh_families SET last_seen_at = created_at,
+		expires_at = (SELECT MAX(t.expires_at) FROM refresh_tokens t WHERE t.family_id = refresh_families.id)`,
+	`CREATE INDEX idx_refresh_families_user ON refresh_families(user_id)`,
+	`CREATE TABLE user_identities (
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		email TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (provider, subject)
+	)`,
+	`CREATE INDEX idx_user_identities_user ON user_identities(user_id)`,
+}
+
+// OpenSQLite opens the SQLite database at path and applies pending migrations
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if path == ":memory:" {
+		// Every connection would otherwise get its own empty database
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration: %v", err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %v", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %v", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// SQLiteUserRepository is a userdomain.Repository backed by SQLite
+type SQLiteUserRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteUserRepository creates a repository using a database opened with
+// OpenSQLite
+func NewSQLiteUserRepository(db *sql.DB) *SQLiteUserRepository {
+	return &SQLiteUserRepository{db: db}
+}
+
+// Create inserts user and sets its ID
+func (r *SQLiteUserRepository) Create(ctx context.Context, user *userdomain.User) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO users (email, name, password_hash, roles, email_verified, mfa_enabled, totp_secret, totp_last_step, recovery_codes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		strings.TrimSpace(user.Email), user.Name, user.Password, joinRoles(user.Roles), user.EmailVerified,
+		user.MFAEnabled, user.TOTPSecret, user.TOTPLastStep, strings.Join(user.RecoveryCodes, ","),
+		user.CreatedAt.UTC(), user.UpdatedAt.UTC())
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to read user id: %v", err)
+	}
+	user.ID = int(id)
+	return nil
+}
+
+// GetByID loads the user with the given ID
+func (r *SQLiteUserRepository) GetByID(ctx context.Context, id int) (*userdomain.User, error) {
+	return r.getOne(ctx, `WHERE id = ?`, id)
+}
+
+// GetByEmail loads the user with the given email
+func (r *SQLiteUserRepository) GetByEmail(ctx context.Context, email string) (*userdomain.User, error) {
+	return r.getOne(ctx, `WHERE email = ?`, strings.TrimSpace(email))
+}
+
+// Update saves every field of user except ID and CreatedAt
+func (r *SQLiteUserRepository) Update(ctx context.Context, user *userdomain.User) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE users SET email = ?, name = ?, password_hash = ?, roles = ?, email_verified = ?,
+		mfa_enabled = ?, totp_secret = ?, totp_last_step = ?, recovery_codes = ?, updated_at = ? WHERE id = ?`,
+		strings.TrimSpace(user.Email), user.Name, user.Password, joinRoles(user.Roles), user.EmailVerified,
+		user.MFAEnabled, user.TOTPSecret, user.TOTPLastStep, strings.Join(user.RecoveryCodes, ","),
+		user.UpdatedAt.UTC(), user.ID)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+	if rows == 0 {
+		return userdomain.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteUserRepository) getOne(ctx context.Context, where string, args ...interface{}) (*userdomain.User, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT id, email, name, password_hash, roles, email_verified, mfa_enabled, totp_secret, totp_last_step, recovery_codes, created_at, updated_at
+		FROM users `+where, args...)
+
+	var user userdomain.User
+	var roles, recoveryCodes string
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &roles, &user.EmailVerified,
+		&user.MFAEnabled, &user.TOTPSecret, &user.TOTPLastStep, &recoveryCodes, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %v", err)
+	}
+	user.Roles = splitRoles(roles)
+	if recoveryCodes != "" {
+		user.RecoveryCodes = strings.Split(recoveryCodes, ",")
+	}
+	user.CreatedAt, user.UpdatedAt = createdAt.Local(), updatedAt.Local()
+	return &user, nil
+}
+
+// Roles are stored as a comma-separated list
+func joinRoles(roles []string) string {
+	return strings.Join(roles, ",")
+}
+
+func splitRoles(roles string) []string {
+	if roles == "" {
+		return []string{}
+	}
+	return strings.Split(roles, ",")
+}
+
+// mapSQLiteError turns constraint violations into domain errors
+func mapSQLiteError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return userdomain.ErrEmailTaken
+	}
+	return fmt.Errorf("database error: %v", err)
+}
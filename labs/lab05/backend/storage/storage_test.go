@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lab05/userdomain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func repositories(t *testing.T) map[string]userdomain.Repository {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return map[string]userdomain.Repository{
+		"memory": NewMemoryUserRepository(),
+		"sqlite": NewSQLiteUserRepository(db),
+	}
+}
+
+func newUser(email string) *userdomain.User {
+	now := time.Now()
+	return &userdomain.User{Email: email, Name: "John Doe", Password: "hash", Roles: []string{"user"}, CreatedAt: now, UpdatedAt: now}
+}
+
+func TestUserRepository(t *testing.T) {
+	for name, repo := range repositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			user := newUser("john@example.com")
+			require.NoError(t, repo.Create(ctx, user))
+			assert.Positive(t, user.ID)
+
+			assert.ErrorIs(t, repo.Create(ctx, newUser("JOHN@example.com")), userdomain.ErrEmailTaken)
+
+			found, err := repo.GetByEmail(ctx, "John@Example.com")
+			require.NoError(t, err)
+			assert.Equal(t, user.ID, found.ID)
+			assert.Equal(t, "hash", found.Password)
+			assert.WithinDuration(t, user.CreatedAt, found.CreatedAt, time.Second)
+			assert.Equal(t, []string{"user"}, found.Roles)
+
+			found.Roles[0] = "mutated"
+			again, err := repo.GetByID(ctx, user.ID)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"user"}, again.Roles, "returned roles must not alias the stored user")
+
+			assert.False(t, found.EmailVerified)
+
+			found.Roles = []string{"user", "editor"}
+			found.EmailVerified = true
+			found.MFAEnabled, found.TOTPSecret, found.TOTPLastStep = true, "JBSWY3DPEHPK3PXP", 42
+			found.RecoveryCodes = []string{"hash1", "hash2"}
+			found.Name = "Jane Doe"
+			found.Email = "jane@example.com"
+			require.NoError(t, repo.Update(ctx, found))
+
+			byID, err := repo.GetByID(ctx, user.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "Jane Doe", byID.Name)
+			assert.Equal(t, "jane@example.com", byID.Email)
+			assert.Equal(t, []string{"user", "editor"}, byID.Roles)
+			assert.True(t, byID.EmailVerified)
+			assert.True(t, byID.MFAEnabled)
+			assert.Equal(t, "JBSWY3DPEHPK3PXP", byID.TOTPSecret)
+			assert.Equal(t, int64(42), byID.TOTPLastStep)
+			assert.Equal(t, []string{"hash1", "hash2"}, byID.RecoveryCodes)
+
+			_, err = repo.GetByEmail(ctx, "john@example.com")
+			assert.ErrorIs(t, err, userdomain.ErrUserNotFound)
+			_, err = repo.GetByID(ctx, 999)
+			assert.ErrorIs(t, err, userdomain.ErrUserNotFound)
+
+			other := newUser("other@example.com")
+			require.NoError(t, repo.Create(ctx, other))
+			other.Email = "jane@example.com"
+			assert.ErrorIs(t, repo.Update(ctx, other), userdomain.ErrEmailTaken)
+
+			missing := newUser("missing@example.com")
+			missing.ID = 999
+			assert.ErrorIs(t, repo.Update(ctx, missing), userdomain.ErrUserNotFound)
+		})
+	}
+}
+
+func TestOpenSQLiteMigratesExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := OpenSQLite(path)
+	require.NoError(t, err)
+	require.NoError(t, NewSQLiteUserRepository(db).Create(context.Background(), newUser("john@example.com")))
+	require.NoError(t, db.Close())
+
+	db, err = OpenSQLite(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var version int
+	require.NoError(t, db.QueryRow(`PRAGMA user_version`).Scan(&version))
+	assert.Equal(t, len(migrations), version)
+
+	user, err := NewSQLiteUserRepository(db).GetByEmail(context.Background(), "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user"}, user.Roles)
+}
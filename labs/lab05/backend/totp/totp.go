@@ -0,0 +1,182 @@
+// Package totp implements RFC 6238 time-based one-time passwords as used by
+// authenticator apps, plus recovery codes for when the device is lost
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSecret is returned for secrets that are not valid base32
+var ErrInvalidSecret = errors.New("invalid TOTP secret")
+
+// secretSize is 160 bits, the HMAC-SHA1 block recommendation of RFC 4226
+const secretSize = 20
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Config holds the TOTP parameters. Authenticator apps widely support only
+// the defaults, so change them with care.
+type Config struct {
+	Issuer string
+	Digits int
+	Period time.Duration
+	// Skew is how many periods before and after the current one are
+	// accepted, to allow for clock drift
+	Skew int
+}
+
+// DefaultConfig uses 6 digits, 30-second periods and accepts one period of
+// drift either way
+func DefaultConfig(issuer string) Config {
+	return Config{Issuer: issuer, Digits: 6, Period: 30 * time.Second, Skew: 1}
+}
+
+// GenerateSecret returns a random base32 secret
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %v", err)
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI returns the otpauth:// URI that authenticator apps read from a QR code
+func (c Config) URI(secret, account string) string {
+	label := url.PathEscape(account)
+	if c.Issuer != "" {
+		label = url.PathEscape(c.Issuer) + ":" + label
+	}
+	query := url.Values{}
+	query.Set("secret", secret)
+	if c.Issuer != "" {
+		query.Set("issuer", c.Issuer)
+	}
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(c.Digits))
+	query.Set("period", fmt.Sprint(int(c.Period/time.Second)))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Step returns the time step t falls in
+func (c Config) Step(t time.Time) int64 {
+	return t.Unix() / int64(c.Period/time.Second)
+}
+
+// Code returns the code for the time step containing t
+func (c Config) Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return c.code(key, c.Step(t)), nil
+}
+
+// Verify checks code against the steps around t and returns the matching
+// step. Callers should reject steps at or before the last one they accepted,
+// so that an observed code cannot be replayed.
+func (c Config) Verify(secret, code string, t time.Time) (int64, bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != c.Digits {
+		return 0, false, nil
+	}
+
+	current := c.Step(t)
+	var matched int64
+	var ok bool
+	// Every step in the window is checked so timing does not reveal which
+	// one matched
+	for i := -c.Skew; i <= c.Skew; i++ {
+		step := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(c.code(key, step)), []byte(code)) == 1 && !ok {
+			matched, ok = step, true
+		}
+	}
+	return matched, ok, nil
+}
+
+// code implements the HOTP truncation of RFC 4226
+func (c Config) code(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < c.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", c.Digits, value%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := encoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// recoveryAlphabet leaves out characters that are easily confused
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateRecoveryCodes returns n random codes like "k7m2p-x9qhd"
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	buf := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery codes: %v", err)
+		}
+		var code strings.Builder
+		for j, b := range buf {
+			if j == 5 {
+				code.WriteByte('-')
+			}
+			// 256 is not a multiple of the alphabet size; the slight bias
+			// costs well under a bit of the code's 49 bits of entropy
+			code.WriteByte(recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+		}
+		codes[i] = code.String()
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the form of code to store. Codes are random, so a
+// fast hash is enough. Case, spaces and dashes are ignored.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchRecoveryCode returns the index of code's hash in hashes, or -1
+func MatchRecoveryCode(hashes []string, code string) int {
+	hash := []byte(HashRecoveryCode(code))
+	match := -1
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), hash) == 1 && match < 0 {
+			match = i
+		}
+	}
+	return match
+}
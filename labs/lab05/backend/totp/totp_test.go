@@ -0,0 +1,117 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA-1 rows
+func TestCodeRFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	config := Config{Digits: 8, Period: 30 * time.Second}
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := config.Code(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifySkew(t *testing.T) {
+	config := DefaultConfig("Wellness")
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	code, _ := config.Code(secret, now)
+
+	for _, tt := range []struct {
+		offset time.Duration
+		want   bool
+	}{
+		{0, true},
+		{-30 * time.Second, true},
+		{30 * time.Second, true},
+		{-60 * time.Second, false},
+		{60 * time.Second, false},
+	} {
+		step, ok, err := config.Verify(secret, code, now.Add(tt.offset))
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+		if ok != tt.want {
+			t.Errorf("Verify at %s = %v, want %v", tt.offset, ok, tt.want)
+		}
+		if ok && step != config.Step(now) {
+			t.Errorf("Verify should return the matching step %d, got %d", config.Step(now), step)
+		}
+	}
+
+	if _, ok, _ := config.Verify(secret, "12345", now); ok {
+		t.Error("Codes of the wrong length should be rejected")
+	}
+	if _, _, err := config.Verify("not base32!", code, now); err != ErrInvalidSecret {
+		t.Errorf("Expected ErrInvalidSecret, got %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := DefaultConfig("Wellness App").URI("JBSWY3DPEHPK3PXP", "john@example.com")
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("URI does not parse: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+	if parsed.Path != "/Wellness App:john@example.com" {
+		t.Errorf("Unexpected label %q", parsed.Path)
+	}
+	query := parsed.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "Wellness App" ||
+		query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("Unexpected parameters %v", query)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	seen := map[string]bool{}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Errorf("Unexpected or duplicate code %q", code)
+		}
+		seen[code] = true
+		hashes[i] = HashRecoveryCode(code)
+	}
+
+	if got := MatchRecoveryCode(hashes, strings.ToUpper(strings.Replace(codes[3], "-", " ", 1))); got != 3 {
+		t.Errorf("Codes should match ignoring case and separators, got index %d", got)
+	}
+	if got := MatchRecoveryCode(hashes, "aaaaa-aaaaa"); got != -1 {
+		t.Errorf("Unknown code matched index %d", got)
+	}
+}
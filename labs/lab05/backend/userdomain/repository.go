@@ -0,0 +1,53 @@
+package userdomain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUserNotFound is returned when no user matches the lookup
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrEmailTaken is returned when another user already has the email
+var ErrEmailTaken = errors.New("email already registered")
+
+// ErrIdentityNotFound is returned when an external account is not linked
+var ErrIdentityNotFound = errors.New("external identity not linked")
+
+// ErrIdentityTaken is returned when an external account is already linked
+var ErrIdentityTaken = errors.New("external identity already linked")
+
+// Repository persists users. Implementations live outside the domain so the
+// business logic does not depend on a particular database.
+//
+// Emails are compared case-insensitively; Password holds the password hash.
+type Repository interface {
+	// Create stores a new user and sets its ID
+	Create(ctx context.Context, user *User) error
+	// GetByID returns ErrUserNotFound when the user does not exist
+	GetByID(ctx context.Context, id int) (*User, error)
+	// GetByEmail returns ErrUserNotFound when no user has the email
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	// Update saves the email, name and password of an existing user
+	Update(ctx context.Context, user *User) error
+}
+
+// ExternalIdentity links a user to their account at an identity provider.
+// Subject is the provider's stable ID for the account; the email may change.
+type ExternalIdentity struct {
+	Provider  string
+	Subject   string
+	UserID    int
+	Email     string
+	CreatedAt time.Time
+}
+
+// IdentityRepository persists links to external accounts
+type IdentityRepository interface {
+	// GetIdentity returns ErrIdentityNotFound when the account is not linked
+	GetIdentity(ctx context.Context, provider, subject string) (*ExternalIdentity, error)
+	// LinkIdentity returns ErrIdentityTaken when the account is already
+	// linked
+	LinkIdentity(ctx context.Context, identity *ExternalIdentity) error
+}
@@ -2,67 +2,110 @@ package userdomain
 
 import (
 	"errors"
-	_ "regexp"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"lab05/security"
 )
 
 // User represents a user entity in the domain
 type User struct {
-	ID        int       `json:"id"`
-	Email     string    `json:"email"`
-	Name      string    `json:"name"`
-	Password  string    `json:"-"` // Never serialize password
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int       `json:"id"`
+	Email         string    `json:"email"`
+	Name          string    `json:"name"`
+	Password      string    `json:"-"` // Never serialize password
+	Roles         []string  `json:"roles"`
+	EmailVerified bool      `json:"email_verified"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// TOTPSecret is set during enrollment and kept while MFAEnabled
+	TOTPSecret string `json:"-"`
+	// TOTPLastStep is the time step of the last accepted code; codes from it
+	// or earlier steps are replays
+	TOTPLastStep int64 `json:"-"`
+	// RecoveryCodes holds hashes of the unused recovery codes
+	RecoveryCodes []string `json:"-"`
 }
 
-// TODO: Implement NewUser function
-// NewUser creates a new user with validation
-// Requirements:
-// - Email must be valid format
-// - Name must be 2-51 characters
-// - Password must be at least 8 characters
-// - CreatedAt and UpdatedAt should be set to current time
+// DefaultRole is given to newly registered users
+const DefaultRole = "user"
+
+// emailPattern is a pragmatic check for local@domain.tld
+var emailPattern = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+
+// NewUser creates a new user with validation. Password holds the plain
+// password until the caller replaces it with a hash.
 func NewUser(email, name, password string) (*User, error) {
-	// TODO: Implement this function
-	// Hint: Use ValidateEmail, ValidateName, ValidatePassword helper functions
-	return nil, errors.New("not implemented")
+	now := time.Now()
+	user := &User{
+		Email:     email,
+		Name:      name,
+		Password:  password,
+		Roles:     []string{DefaultRole},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
-// TODO: Implement Validate method
 // Validate checks if the user data is valid
 func (u *User) Validate() error {
-	// TODO: Implement validation logic
-	// Check email, name, and password validity
-	return errors.New("not implemented")
+	if err := ValidateEmail(u.Email); err != nil {
+		return err
+	}
+	if err := ValidateName(u.Name); err != nil {
+		return err
+	}
+	return ValidatePassword(u.Password, u.Email, u.Name)
 }
 
-// TODO: Implement ValidateEmail function
 // ValidateEmail checks if email format is valid
 func ValidateEmail(email string) error {
-	// TODO: Implement email validation
-	// Use regex pattern to validate email format
-	// Email should not be empty and should match standard email pattern
-	return errors.New("not implemented")
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return errors.New("email cannot be empty")
+	}
+	if !emailPattern.MatchString(email) {
+		return errors.New("invalid email format")
+	}
+	return nil
 }
 
-// TODO: Implement ValidateName function
-// ValidateName checks if name is valid
+// ValidateName checks that the trimmed name is 2-50 characters long
 func ValidateName(name string) error {
-	// TODO: Implement name validation
-	// Name should be 2-50 characters, trimmed of whitespace
-	// Should not be empty after trimming
-	return errors.New("not implemented")
+	length := utf8.RuneCountInString(strings.TrimSpace(name))
+	if length == 0 {
+		return errors.New("name cannot be empty")
+	}
+	if length < 2 || length > 50 {
+		return errors.New("name must be between 2 and 50 characters")
+	}
+	return nil
 }
 
-// TODO: Implement ValidatePassword function
-// ValidatePassword checks if password meets security requirements
-func ValidatePassword(password string) error {
-	// TODO: Implement password validation
-	// Password should be at least 8 characters
-	// Should contain at least one uppercase, lowercase, and number
-	return errors.New("not implemented")
+// PasswordPolicy is applied to account passwords
+var PasswordPolicy = security.DefaultPolicy()
+
+// ValidatePassword checks password against PasswordPolicy. The error is a
+// *security.PolicyError listing every violated rule.
+func ValidatePassword(password string, personal ...string) error {
+	return PasswordPolicy.Validate(password, personal...)
+}
+
+// DisableMFA removes the second factor and its recovery codes
+func (u *User) DisableMFA() {
+	u.MFAEnabled = false
+	u.TOTPSecret = ""
+	u.TOTPLastStep = 0
+	u.RecoveryCodes = nil
+	u.UpdatedAt = time.Now()
 }
 
 // UpdateName updates the user's name with validation
@@ -75,12 +118,17 @@ func (u *User) UpdateName(name string) error {
 	return nil
 }
 
-// UpdateEmail updates the user's email with validation
+// UpdateEmail updates the user's email with validation. A new address has
+// to be verified again.
 func (u *User) UpdateEmail(email string) error {
 	if err := ValidateEmail(email); err != nil {
 		return err
 	}
-	u.Email = strings.ToLower(strings.TrimSpace(email))
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email != u.Email {
+		u.EmailVerified = false
+	}
+	u.Email = email
 	u.UpdatedAt = time.Now()
 	return nil
 }
@@ -45,6 +45,20 @@ func TestNewUser(t *testing.T) {
 			password:  "short",
 			wantError: true,
 		},
+		{
+			name:      "password contains name",
+			email:     "test@example.com",
+			userName:  "John Doe",
+			password:  "JohnDoe2024",
+			wantError: true,
+		},
+		{
+			name:      "common password",
+			email:     "test@example.com",
+			userName:  "John Doe",
+			password:  "Welcome123",
+			wantError: true,
+		},
 		{
 			name:      "password without uppercase",
 			email:     "test@example.com",